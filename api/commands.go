@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const commandsUsage = `Usage: bodytracker_api [command]
+Without command, starts the API server.
+Commands:
+  import-fdc [--batch-size N] [--restart] <path>
+      Import an FDC dataset download: a JSON file, or the directory of an
+      unzipped CSV download. An interrupted import resumes when run again.
+  purge-users [--older-than DAYS]
+      Delete for good the users deleted more than DAYS ago (USER_RETENTION_DAYS,
+      30 by default) with all their data. The server also does it daily.`
+
+// errUsage marks the errors of a command called with wrong arguments
+var errUsage = errors.New("usage")
+
+// runCommand runs a maintenance command and returns the process exit code
+func runCommand(db *gorm.DB, args []string) int {
+	var err error
+	switch args[0] {
+	case "import-fdc":
+		err = importFDC(db, args[1:])
+	case "purge-users":
+		err = purgeUsers(db, args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(commandsUsage)
+		return 0
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, commandsUsage)
+		return 2
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+}
+
+// importFDC runs `import-fdc`, stopping after the batch in progress on
+// interrupt
+func importFDC(db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("import-fdc", flag.ContinueOnError)
+	batchSize := fs.Int("batch-size", 500, "number of foods saved per transaction")
+	restart := fs.Bool("restart", false, "import the download from the start")
+	// Accept the flags after the path too
+	err := fs.Parse(args)
+	path := fs.Arg(0)
+	if err == nil {
+		err = fs.Parse(fs.Args()[min(1, fs.NArg()):])
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if err != nil || path == "" || fs.NArg() > 0 {
+		return fmt.Errorf("%w: import-fdc takes the path of a single download", errUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Batches are slow by design, don't report them as slow queries
+	db = db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Error)})
+	progress, err := services.ImportFDC(ctx, db, path, services.FDCImportOptions{
+		BatchSize: *batchSize,
+		Restart:   *restart,
+		Progress: func(progress models.FDCImport) {
+			log.Printf("Imported %d foods", progress.Foods)
+		},
+	})
+	switch {
+	case errors.Is(err, services.ErrFDCImportCompleted):
+		log.Printf("%s was already imported on %s, use --restart to import it again",
+			progress.Source, progress.CompletedAt.Format("2006-01-02 15:04"))
+		return nil
+	case errors.Is(err, context.Canceled):
+		log.Printf("Import interrupted after %d foods, run the command again to resume", progress.Foods)
+		return nil
+	case err != nil:
+		return err
+	}
+	log.Printf("Imported %d foods from %s", progress.Foods, progress.Source)
+	return nil
+}
+
+// purgeUsers runs `purge-users`
+func purgeUsers(db *gorm.DB, args []string) error {
+	retention, err := services.UserRetention()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("purge-users", flag.ContinueOnError)
+	days := fs.Int("older-than", int(retention.Hours()/24), "purge the users deleted more than this number of days ago")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() > 0 || *days < 0 {
+		return fmt.Errorf("%w: purge-users takes a positive --older-than only", errUsage)
+	}
+
+	purged, err := services.PurgeDeletedUsers(db, time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		return err
+	}
+	log.Printf("Purged %d user(s) deleted more than %d day(s) ago", purged, *days)
+	return nil
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxDailyExportDays caps the range of the daily totals CSV, which has a row
+// per day
+const maxDailyExportDays = 3660
+
+// ExportMealsCSV answers the foods the user ate between the optional from
+// and to days as CSV, a row per food
+func (h *UserHandler) ExportMealsCSV(c *gin.Context) {
+	var user models.User
+	from, to, ok := h.csvExportRange(c, &user)
+	if !ok {
+		return
+	}
+
+	entries, err := services.MealEntries(h.db, user.ID, from, to)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondCSV(c, csvFilename("meals", user.ID, from, to), func(w io.Writer) error {
+		return services.WriteMealsCSV(w, entries)
+	})
+}
+
+// ExportDailyCSV answers the nutrients the user ate each day between the
+// optional from and to days, against their targets, as CSV
+func (h *UserHandler) ExportDailyCSV(c *gin.Context) {
+	var user models.User
+	from, to, ok := h.csvExportRange(c, &user)
+	if !ok {
+		return
+	}
+
+	entries, err := services.MealEntries(h.db, user.ID, from, to)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	// An open bound is the first or last day logged
+	first, last := services.DailyRange(entries, from, to)
+	if !first.IsZero() && !last.IsZero() && last.Sub(first) >= maxDailyExportDays*24*time.Hour {
+		field, message := "from", fmt.Sprintf("must be less than %d days before to", maxDailyExportDays)
+		switch {
+		case from.IsZero():
+			field, message = "to", fmt.Sprintf("must be less than %d days after the first day logged", maxDailyExportDays)
+		case to.IsZero():
+			message = fmt.Sprintf("must be less than %d days before the last day logged", maxDailyExportDays)
+		}
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid date range", models.FieldError{Field: field, Message: message})
+		return
+	}
+	days := services.DailyTotals(entries, first, last)
+
+	var targets []models.Target
+	if err := h.db.Where("user_id = ?", user.ID).Limit(1).Find(&targets).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	var target *models.Target
+	if len(targets) > 0 {
+		target = &targets[0]
+	}
+	respondCSV(c, csvFilename("daily", user.ID, from, to), func(w io.Writer) error {
+		return services.WriteDailyCSV(w, days, target)
+	})
+}
+
+// ExportWeightCSV answers the user's weight records between the optional
+// from and to days as CSV, oldest first
+func (h *UserHandler) ExportWeightCSV(c *gin.Context) {
+	var user models.User
+	from, to, ok := h.csvExportRange(c, &user)
+	if !ok {
+		return
+	}
+
+	query := h.db.Where("user_id = ?", user.ID)
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("date < ?", to.AddDate(0, 0, 1))
+	}
+	var records []models.WeightRecord
+	if err := query.Order("date, id").Find(&records).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	respondCSV(c, csvFilename("weight", user.ID, from, to), func(w io.Writer) error {
+		return services.WriteWeightCSV(w, records)
+	})
+}
+
+// csvExportRange loads the user of the route and parses the date range of a
+// CSV export, answering 400 or 404 when they are invalid
+func (h *UserHandler) csvExportRange(c *gin.Context, user *models.User) (time.Time, time.Time, bool) {
+	if !h.findUser(c, user) {
+		return time.Time{}, time.Time{}, false
+	}
+	return dateRangeQuery(c)
+}
+
+// csvFilename names a CSV export after its content, user and range, e.g.
+// meals-1-2024-01-01-2024-01-31.csv
+func csvFilename(name string, userID uint, from, to time.Time) string {
+	parts := []string{name, fmt.Sprint(userID)}
+	for _, day := range []time.Time{from, to} {
+		if !day.IsZero() {
+			parts = append(parts, day.Format("2006-01-02"))
+		}
+	}
+	return strings.Join(parts, "-") + ".csv"
+}
+
+// respondCSV answers the CSV written by write as an attachment
+func respondCSV(c *gin.Context, filename string, write func(io.Writer) error) {
+	var body bytes.Buffer
+	if err := write(&body); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", body.Bytes())
+}
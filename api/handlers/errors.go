@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/middleware"
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+func init() {
+	// Report invalid fields under their JSON name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// respondError aborts the request with the API's error body
+func respondError(c *gin.Context, status int, code models.ErrorCode, message string, details ...models.FieldError) {
+	c.AbortWithStatusJSON(status, models.ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: middleware.GetRequestID(c),
+	})
+}
+
+// respondInternalError logs err and answers with a generic message, keeping
+// database and driver details out of the response
+func respondInternalError(c *gin.Context, err error) {
+	log.Printf("request %s: %s %s: %v", middleware.GetRequestID(c), c.Request.Method, c.FullPath(), err)
+	respondError(c, http.StatusInternalServerError, models.ErrInternal, "Internal server error")
+}
+
+// respondDBError answers 404 with notFound when err is gorm.ErrRecordNotFound
+// and 500 otherwise
+func respondDBError(c *gin.Context, err error, notFound string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusNotFound, models.ErrNotFound, notFound)
+		return
+	}
+	respondInternalError(c, err)
+}
+
+// bindJSON decodes and validates the request body into obj, answering 400
+// for a malformed body and 422 for invalid fields
+func bindJSON(c *gin.Context, obj any) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid request body", validationDetails(validationErrs)...)
+	case errors.As(err, &typeErr):
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid request body",
+			models.FieldError{Field: typeErr.Field, Message: "must be a " + jsonTypeName(typeErr.Type)})
+	default:
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Malformed request body: "+err.Error())
+	}
+	return false
+}
+
+// validationDetails describes the invalid fields of a body by their path
+// from its root, e.g. "meals[0].type" for a nested one
+func validationDetails(validationErrs validator.ValidationErrors) []models.FieldError {
+	details := make([]models.FieldError, len(validationErrs))
+	for i, fe := range validationErrs {
+		field := fe.Field()
+		if _, path, ok := strings.Cut(fe.Namespace(), "."); ok {
+			field = path
+		}
+		details[i] = models.FieldError{Field: field, Message: validationMessage(fe)}
+	}
+	return details
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte", "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "lte", "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		return "is invalid (" + fe.Tag() + ")"
+	}
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Uint, reflect.Uint64, reflect.Uint32:
+		return "whole number"
+	case reflect.Float64, reflect.Float32:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	default:
+		return t.String()
+	}
+}
+
+// respondProviderError answers a request the food provider failed: 503 when
+// it is down, rate limits the API or isn't configured, 504 when it didn't answer in time, 502
+// otherwise
+func respondProviderError(c *gin.Context, err error) {
+	var upstream *services.UpstreamError
+	switch {
+	case errors.Is(err, services.ErrProviderOffline):
+		respondError(c, http.StatusServiceUnavailable, models.ErrUpstreamUnavailable, "Food database is not configured on this server")
+	case errors.Is(err, services.ErrCircuitOpen),
+		errors.As(err, &upstream) && (upstream.StatusCode == http.StatusTooManyRequests || upstream.StatusCode == http.StatusServiceUnavailable):
+		respondError(c, http.StatusServiceUnavailable, models.ErrUpstreamUnavailable, "Food database is unavailable, please try again later")
+	case services.IsTimeout(err):
+		respondError(c, http.StatusGatewayTimeout, models.ErrUpstreamTimeout, "Food database did not answer in time")
+	default:
+		respondError(c, http.StatusBadGateway, models.ErrUpstream, "Food database is unavailable")
+	}
+}
+
+// parseIDParam reads a numeric ID from the route, answering 400 when it is
+// not one
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil || id == 0 {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, fmt.Sprintf("Invalid %s: must be a positive integer", name))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// NoRoute answers requests to unknown routes with the API's error body
+func NoRoute(c *gin.Context) {
+	respondError(c, http.StatusNotFound, models.ErrNotFound, "Route not found")
+}
+
+// Recovery turns panics into 500 responses with the API's error body
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		respondInternalError(c, fmt.Errorf("panic: %v", recovered))
+	})
+}
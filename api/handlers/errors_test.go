@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondProviderError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   models.ErrorCode
+	}{
+		{"offline", services.ErrProviderOffline, http.StatusServiceUnavailable, models.ErrUpstreamUnavailable},
+		{"circuit open", fmt.Errorf("%w, retry in 30s", services.ErrCircuitOpen), http.StatusServiceUnavailable, models.ErrUpstreamUnavailable},
+		{"rate limited", &services.UpstreamError{StatusCode: http.StatusTooManyRequests}, http.StatusServiceUnavailable, models.ErrUpstreamUnavailable},
+		{"unavailable", &services.UpstreamError{StatusCode: http.StatusServiceUnavailable}, http.StatusServiceUnavailable, models.ErrUpstreamUnavailable},
+		{"deadline", fmt.Errorf("failed to fetch FDC data: %w", &url.Error{Op: "Get", Err: context.DeadlineExceeded}), http.StatusGatewayTimeout, models.ErrUpstreamTimeout},
+		{"server error", &services.UpstreamError{StatusCode: http.StatusInternalServerError}, http.StatusBadGateway, models.ErrUpstream},
+		{"unexpected status", &services.UpstreamError{StatusCode: http.StatusForbidden}, http.StatusBadGateway, models.ErrUpstream},
+		{"network", errors.New("connection refused"), http.StatusBadGateway, models.ErrUpstream},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodGet, "/foods/search?q=rice", nil)
+			respondProviderError(c, test.err)
+
+			var body models.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			if rec.Code != test.status || body.Code != test.code {
+				t.Errorf("got %d %s, want %d %s", rec.Code, body.Code, test.status, test.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ExportUser answers all the data of the user, as JSON or, with
+// format=zip, as a ZIP archive of the JSON export and of CSV files
+func (h *UserHandler) ExportUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid format",
+			models.FieldError{Field: "format", Message: "must be one of: json, zip"})
+		return
+	}
+
+	export, err := services.ExportUser(h.db, userID)
+	if err != nil {
+		respondDBError(c, err, "User not found")
+		return
+	}
+
+	filename := fmt.Sprintf("bodytracker-user-%d-%s.%s", userID, export.ExportedAt.Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "json" {
+		c.JSON(http.StatusOK, export)
+		return
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	if err := services.WriteUserExportZip(c.Writer, export); err != nil {
+		// The archive is partly sent, the client sees a broken one
+		_ = c.Error(err)
+	}
+}
+
+// ImportUser restores an export of ExportUser, JSON or ZIP, sent as the
+// request body, as a new user
+func (h *UserHandler) ImportUser(c *gin.Context) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxUserExportSize))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		respondError(c, http.StatusRequestEntityTooLarge, models.ErrBadRequest,
+			fmt.Sprintf("Export larger than %d MB", services.MaxUserExportSize>>20))
+		return
+	case err != nil:
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Malformed request body: "+err.Error())
+		return
+	}
+
+	export, err := services.ReadUserExport(data)
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid export",
+			models.FieldError{Field: typeErr.Field, Message: "must be a " + jsonTypeName(typeErr.Type)})
+		return
+	case err != nil:
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest,
+			"Malformed export: "+strings.TrimPrefix(err.Error(), services.ErrMalformedUserExport.Error()+": "))
+		return
+	}
+
+	var validationErrs validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(export); errors.As(err, &validationErrs) {
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid export", validationDetails(validationErrs)...)
+		return
+	} else if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	user, err := services.ImportUser(h.db, export)
+	var invalid *services.InvalidExportError
+	switch {
+	case errors.As(err, &invalid):
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid export",
+			models.FieldError{Field: invalid.Field, Message: invalid.Message})
+		return
+	case err != nil:
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ZUHOWKS/my-body-tracker/api/middleware"
 	"github.com/ZUHOWKS/my-body-tracker/api/models"
 	"github.com/ZUHOWKS/my-body-tracker/api/services"
 	"github.com/gin-gonic/gin"
@@ -10,46 +17,262 @@ import (
 )
 
 type FoodHandler struct {
-	db         *gorm.DB
-	httpClient *http.Client
+	db       *gorm.DB
+	provider services.FoodProvider
 }
 
-func NewFoodHandler(db *gorm.DB, client *http.Client) *FoodHandler {
-	return &FoodHandler{db: db, httpClient: client}
+func NewFoodHandler(db *gorm.DB, provider services.FoodProvider) *FoodHandler {
+	return &FoodHandler{db: db, provider: provider}
 }
 
+const (
+	defaultFoodSearchLimit = 10
+	maxFoodSearchLimit     = 50
+
+	// foodCacheTTL is how long the results of a query sent to the food
+	// provider, and the nutrients of the foods it returned, are trusted
+	foodCacheTTL = 30 * 24 * time.Hour
+)
+
+// foodSorts lists the values accepted by the sort parameter of the search
+var foodSorts = []string{services.FoodSortRelevance, services.FoodSortName, services.FoodSortDataType}
+
+// SearchFood searches the local food database first, and the food provider
+// only when the local page isn't full and the same search wasn't sent to it
+// recently, or when refresh is set. The provider's page then replaces the
+// local one, without the local matches: custom foods show up again in the
+// next searches, answered locally with the provider's foods stored.
 func (h *FoodHandler) SearchFood(c *gin.Context) {
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+	query, userID, refresh, ok := foodSearchQuery(c)
+	if !ok {
 		return
 	}
 
-	foods, err := services.SearchFood(query, *h.httpClient)
+	foods, total, err := services.SearchLocalFoods(h.db, query, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalError(c, err)
 		return
 	}
 
-	// Enregistrer les aliments dans la base de données locale
-	for i := range foods {
-		// Vérifier si l'aliment existe déjà
-		var existingFood models.Food
-		result := h.db.Where("fdc_id = ?", foods[i].FdcID).First(&existingFood)
+	key := query.Key()
+	if !refresh && len(foods) >= query.PageSize {
+		h.respondFoods(c, query, foods, total, userID, models.FoodSourceLocal)
+		return
+	}
+	if !refresh {
+		var fetched models.FoodQuery
+		err := h.db.Where("query = ?", key).First(&fetched).Error
+		if err == nil && time.Since(fetched.FetchedAt) < foodCacheTTL {
+			h.respondFoods(c, query, foods, total, userID, models.FoodSourceLocal)
+			return
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			respondInternalError(c, err)
+			return
+		}
+	}
 
-		if result.Error == gorm.ErrRecordNotFound {
-			// L'aliment n'existe pas, le créer
-			if err := h.db.Create(&foods[i]).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save food: " + err.Error()})
+	found, err := h.provider.SearchFoods(c.Request.Context(), query)
+	if err != nil {
+		log.Printf("request %s: food search: %v", middleware.GetRequestID(c), err)
+		// Stale local results are better than none, and the only ones a server
+		// without food provider has
+		if len(foods) > 0 || errors.Is(err, services.ErrProviderOffline) {
+			h.respondFoods(c, query, foods, total, userID, models.FoodSourceLocal)
+			return
+		}
+		respondProviderError(c, err)
+		return
+	}
+
+	stored, err := h.storeFoods(found.Foods)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if err := h.db.Save(&models.FoodQuery{Query: key, FetchedAt: time.Now()}).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	// The provider's page is returned as is, local matches left out: its
+	// pages don't line up with the local ones
+	h.respondFoods(c, query, stored, found.TotalHits, userID, models.FoodSourceProvider)
+}
+
+// foodSearchQuery parses the parameters of a food search. It answers 400 and
+// returns false when one is invalid.
+func foodSearchQuery(c *gin.Context) (services.FoodSearchQuery, uint, bool, bool) {
+	query := services.FoodSearchQuery{Query: strings.TrimSpace(c.Query("q"))}
+	if query.Query == "" {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Query parameter 'q' is required",
+			models.FieldError{Field: "q", Message: "is required"})
+		return query, 0, false, false
+	}
+
+	var ok bool
+	if query.Page, ok = positiveIntQuery(c, "page", 1, 0); !ok {
+		return query, 0, false, false
+	}
+	if query.PageSize, ok = positiveIntQuery(c, "limit", defaultFoodSearchLimit, maxFoodSearchLimit); !ok {
+		return query, 0, false, false
+	}
+
+	var err error
+	if query.DataTypes, err = services.ParseDataTypes(c.Query("dataType")); err != nil {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid dataType",
+			models.FieldError{Field: "dataType", Message: "must be a comma-separated list of: foundation, sr-legacy, branded, survey"})
+		return query, 0, false, false
+	}
+	query.BrandOwner = strings.TrimSpace(c.Query("brandOwner"))
+
+	sort := c.DefaultQuery("sort", services.FoodSortRelevance)
+	query.Descending = strings.HasPrefix(sort, "-")
+	query.Sort = strings.TrimPrefix(sort, "-")
+	if !slices.Contains(foodSorts, query.Sort) || (query.Descending && query.Sort == services.FoodSortRelevance) {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid sort",
+			models.FieldError{Field: "sort", Message: "must be relevance, or name or dataType optionally prefixed with -"})
+		return query, 0, false, false
+	}
+
+	// Foods the user logs often rank higher
+	userID, ok := positiveIntQuery(c, "userId", 0, 0)
+	if !ok {
+		return query, 0, false, false
+	}
+	refresh, err := strconv.ParseBool(c.DefaultQuery("refresh", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid refresh",
+			models.FieldError{Field: "refresh", Message: "must be true or false"})
+		return query, 0, false, false
+	}
+	return query, uint(userID), refresh, true
+}
+
+// respondFoods answers with a page of search results, personalized for the
+// user when there is one
+func (h *FoodHandler) respondFoods(c *gin.Context, query services.FoodSearchQuery, foods []models.Food, total int, userID uint, source string) {
+	if err := personalizeFoods(h.db, userID, foodPointers(foods)); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.FoodSearchResponse{
+		Foods:      foods,
+		Source:     source,
+		Page:       query.Page,
+		TotalHits:  total,
+		TotalPages: (total + query.PageSize - 1) / query.PageSize,
+	})
+}
+
+// GetFood returns a food with its portions, fetching its detail from the
+// food provider when it was never fetched or longer ago than foodCacheTTL.
+// Foods missing from the local database are fetched too.
+func (h *FoodHandler) GetFood(c *gin.Context) {
+	userID, ok := positiveIntQuery(c, "userId", 0, 0)
+	if !ok {
+		return
+	}
+
+	fdcID := c.Param("fdcId")
+	var food models.Food
+	err := h.db.Preload("Portions").Where("fdc_id = ?", fdcID).First(&food).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondInternalError(c, err)
+		return
+	}
+	stored := err == nil
+
+	// Custom foods are only visible to their user, and unknown to the provider
+	if stored && food.UserID != nil && *food.UserID != uint(userID) {
+		respondError(c, http.StatusNotFound, models.ErrNotFound, "Food not found")
+		return
+	}
+	if food.UserID == nil && (!stored || food.DetailFetchedAt == nil || time.Since(*food.DetailFetchedAt) > foodCacheTTL) {
+		detail, err := h.provider.FoodDetail(c.Request.Context(), fdcID)
+		switch {
+		case err == nil:
+			if food, err = h.storeFoodDetail(food, *detail); err != nil {
+				respondInternalError(c, err)
 				return
 			}
-		} else if result.Error != nil {
-			// Une erreur s'est produite lors de la recherche
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + result.Error.Error()})
+		case stored:
+			// The stored food, even without portions, is better than none
+			log.Printf("request %s: food detail: %v", middleware.GetRequestID(c), err)
+		case errors.Is(err, services.ErrFoodNotFound):
+			respondError(c, http.StatusNotFound, models.ErrNotFound, "Food not found")
+			return
+		default:
+			log.Printf("request %s: food detail: %v", middleware.GetRequestID(c), err)
+			respondProviderError(c, err)
 			return
 		}
-		// Si l'aliment existe déjà, on utilise celui de la base de données
 	}
 
-	c.JSON(http.StatusOK, gin.H{"foods": foods})
+	if err := personalizeFoods(h.db, uint(userID), []*models.Food{&food}); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, food)
+}
+
+// storeFoodDetail saves the detail of a food, stored or not yet, replacing
+// its portions, and returns the stored record
+func (h *FoodHandler) storeFoodDetail(stored, detail models.Food) (models.Food, error) {
+	now := time.Now()
+	detail.ID, detail.CreatedAt = stored.ID, stored.CreatedAt
+	detail.DetailFetchedAt = &now
+	portions := detail.Portions
+	detail.Portions = nil
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&detail).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("food_id = ?", detail.ID).Delete(&models.FoodPortion{}).Error; err != nil {
+			return err
+		}
+		for i := range portions {
+			portions[i].FoodID = detail.ID
+		}
+		if len(portions) > 0 {
+			return tx.Create(&portions).Error
+		}
+		return nil
+	})
+	detail.Portions = portions
+	return detail, err
+}
+
+// storeFoods saves the foods returned by the provider that aren't stored yet
+// and refreshes the nutrients of those stored for longer than foodCacheTTL.
+// It returns the stored records.
+func (h *FoodHandler) storeFoods(foods []models.Food) ([]models.Food, error) {
+	stored := make([]models.Food, 0, len(foods))
+	for _, food := range foods {
+		// Vérifier si l'aliment existe déjà
+		var existing models.Food
+		err := h.db.Where("fdc_id = ?", food.FdcID).First(&existing).Error
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// L'aliment n'existe pas, le créer
+			if err := h.db.Create(&food).Error; err != nil {
+				return nil, err
+			}
+			existing = food
+		case err != nil:
+			return nil, err
+		case time.Since(existing.UpdatedAt) > foodCacheTTL:
+			// Les valeurs nutritionnelles sont périmées, les mettre à jour
+			food.ID, food.CreatedAt = existing.ID, existing.CreatedAt
+			food.DetailFetchedAt = existing.DetailFetchedAt
+			if err := h.db.Save(&food).Error; err != nil {
+				return nil, err
+			}
+			existing = food
+		}
+		stored = append(stored, existing)
+	}
+	return stored, nil
 }
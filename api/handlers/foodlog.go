@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxFoodLogSize caps the size of an imported food log
+const maxFoodLogSize = 10 << 20
+
+// ImportFoodLog logs the foods of a CSV food log exported by MyFitnessPal or
+// Cronometer, sent as the request body, in the user's meals. With dryRun,
+// it only reports what it would import.
+func (h *UserHandler) ImportFoodLog(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	format := c.Query("format")
+	if format != "" && !slices.Contains(services.FoodLogFormats, format) {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid format",
+			models.FieldError{Field: "format", Message: "must be one of: " + strings.Join(services.FoodLogFormats, ", ")})
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid dryRun",
+			models.FieldError{Field: "dryRun", Message: "must be true or false"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		respondDBError(c, err, "User not found")
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxFoodLogSize)
+	format, entries, skipped, err := services.ParseFoodLog(body, format)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		respondError(c, http.StatusRequestEntityTooLarge, models.ErrBadRequest, "Food log larger than 10 MB")
+		return
+	case errors.Is(err, services.ErrInvalidFoodLog):
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid food log: "+strings.TrimPrefix(err.Error(), services.ErrInvalidFoodLog.Error()+": "))
+		return
+	case err != nil:
+		respondInternalError(c, err)
+		return
+	}
+
+	report := models.FoodLogImport{
+		Format:    format,
+		DryRun:    dryRun,
+		Unmatched: []models.FoodLogLine{},
+		Skipped:   append([]models.FoodLogLine{}, skipped...),
+	}
+	if err := services.ImportFoodLog(h.db, userID, entries, &report); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	slices.SortStableFunc(report.Skipped, func(a, b models.FoodLogLine) int { return a.Line - b.Line })
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Headers describing a page of a list response
+const (
+	TotalCountHeader = "X-Total-Count"
+	LinkHeader       = "Link"
+)
+
+// sortOptions maps the values accepted by a list's sort parameter to the
+// columns they order by. Prefixing the value with "-" reverses the order.
+type sortOptions map[string][]string
+
+// orderClause returns the ORDER BY clause for the sort parameter value
+func (s sortOptions) orderClause(value string) (string, bool) {
+	desc := strings.HasPrefix(value, "-")
+	columns, ok := s[strings.TrimPrefix(value, "-")]
+	if !ok {
+		return "", false
+	}
+
+	clause := make([]string, len(columns))
+	for i, column := range columns {
+		clause[i] = column
+		if desc {
+			clause[i] += " DESC"
+		}
+	}
+	// Break ties on the ID so that pages don't overlap
+	if desc {
+		return strings.Join(clause, ", ") + ", id DESC", true
+	}
+	return strings.Join(clause, ", ") + ", id", true
+}
+
+func (s sortOptions) names() string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// paginate applies the page, limit and sort query parameters to query,
+// counts the matching rows and describes the page in the response headers.
+// It answers 400 and returns false when a parameter is invalid.
+func paginate(c *gin.Context, query *gorm.DB, sorts sortOptions, defaultSort string) (*gorm.DB, bool) {
+	page, ok := positiveIntQuery(c, "page", 1, 0)
+	if !ok {
+		return nil, false
+	}
+	limit, ok := positiveIntQuery(c, "limit", defaultPageSize, maxPageSize)
+	if !ok {
+		return nil, false
+	}
+
+	order, ok := sorts.orderClause(c.DefaultQuery("sort", defaultSort))
+	if !ok {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid sort",
+			models.FieldError{Field: "sort", Message: "must be one of: " + sorts.names() + ", optionally prefixed with -"})
+		return nil, false
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		respondInternalError(c, err)
+		return nil, false
+	}
+
+	c.Header(TotalCountHeader, strconv.FormatInt(total, 10))
+	var links []string
+	if page > 1 {
+		links = append(links, pageLink(c, page-1, "prev"))
+	}
+	if int64(page*limit) < total {
+		links = append(links, pageLink(c, page+1, "next"))
+	}
+	if len(links) > 0 {
+		c.Header(LinkHeader, strings.Join(links, ", "))
+	}
+
+	return query.Order(order).Offset((page - 1) * limit).Limit(limit), true
+}
+
+// pageLink returns a Link header entry pointing at another page of the
+// current request
+func pageLink(c *gin.Context, page int, rel string) string {
+	u := *c.Request.URL
+	params := u.Query()
+	params.Set("page", strconv.Itoa(page))
+	u.RawQuery = params.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
+
+// positiveIntQuery parses an optional positive integer query parameter, at
+// most max when max is not 0
+func positiveIntQuery(c *gin.Context, name string, def, max int) (int, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, true
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || (max > 0 && n > max) {
+		message := "must be a positive integer"
+		if max > 0 {
+			message = fmt.Sprintf("must be an integer between 1 and %d", max)
+		}
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, fmt.Sprintf("Invalid %s", name),
+			models.FieldError{Field: name, Message: message})
+		return 0, false
+	}
+	return n, true
+}
+
+// filterDateRange restricts query to the rows whose column falls between the
+// from and to query parameters, both inclusive days formatted as YYYY-MM-DD
+func filterDateRange(c *gin.Context, query *gorm.DB, column string) (*gorm.DB, bool) {
+	from, to, ok := dateRangeQuery(c)
+	if !ok {
+		return nil, false
+	}
+
+	if !from.IsZero() {
+		query = query.Where(column+" >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where(column+" < ?", to.AddDate(0, 0, 1))
+	}
+	return query, true
+}
+
+// dateRangeQuery parses the optional from and to query parameters, answering
+// 400 when to is before from
+func dateRangeQuery(c *gin.Context) (time.Time, time.Time, bool) {
+	from, ok := dateQuery(c, "from")
+	if !ok {
+		return from, time.Time{}, false
+	}
+	to, ok := dateQuery(c, "to")
+	if !ok {
+		return from, to, false
+	}
+
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid date range",
+			models.FieldError{Field: "to", Message: "must not be before from"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// dateQuery parses an optional date query parameter formatted as YYYY-MM-DD
+func dateQuery(c *gin.Context, name string) (time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+
+	date, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid date format. Use YYYY-MM-DD",
+			models.FieldError{Field: name, Message: "must be a date formatted as YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	return date, true
+}
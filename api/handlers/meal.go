@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/ZUHOWKS/my-body-tracker/api/models"
@@ -18,18 +20,22 @@ func NewMealHandler(db *gorm.DB) *MealHandler {
 }
 
 func (h *MealHandler) CreateMeal(c *gin.Context) {
+	// The meal type is validated by the binding rules of models.Meal
 	var meal models.Meal
-	if err := c.ShouldBindJSON(&meal); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &meal) {
 		return
 	}
 
-	// Validate meal type
-	switch meal.Type {
-	case models.Breakfast, models.Lunch, models.Break, models.Dinner:
-		// Valid type
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid meal type. Must be one of: breakfast, lunch, break, dinner"})
+	// Vérifier si l'utilisateur existe
+	var user models.User
+	err := h.db.First(&user, meal.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid request body",
+			models.FieldError{Field: "userId", Message: "must be an existing user"})
+		return
+	}
+	if err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
@@ -38,36 +44,71 @@ func (h *MealHandler) CreateMeal(c *gin.Context) {
 		meal.Date = time.Now()
 	}
 
-	if err := h.db.Create(&meal).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.db.Omit("Foods").Create(&meal).Error; err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, meal)
 }
 
+// mealSorts are the orders accepted by GetUserMeals
+var mealSorts = sortOptions{
+	"date": {"date", "meal_type"},
+	"type": {"meal_type", "date"},
+}
+
 func (h *MealHandler) GetUserMeals(c *gin.Context) {
-	var meals []models.Meal
-	query := h.db.Model(&models.Meal{}).Where("user_id = ?", c.Param("userId"))
+	userID, ok := parseIDParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		respondDBError(c, err, "User not found")
+		return
+	}
+
+	query := h.db.Model(&models.Meal{}).Where("user_id = ?", userID)
 
 	// Filter by date if provided
-	if date := c.Query("date"); date != "" {
-		parsedDate, err := time.Parse("2006-01-02", date)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
-			return
-		}
-		query = query.Where("DATE(date) = DATE(?)", parsedDate)
+	date, ok := dateQuery(c, "date")
+	if !ok {
+		return
+	}
+	if !date.IsZero() {
+		query = query.Where("DATE(date) = DATE(?)", date)
+	}
+	if query, ok = filterDateRange(c, query, "date"); !ok {
+		return
 	}
 
 	// Filter by type if provided
-	if mealType := c.Query("type"); mealType != "" {
+	mealType, ok := mealTypeQuery(c)
+	if !ok {
+		return
+	}
+	if mealType != "" {
 		query = query.Where("meal_type = ?", mealType)
 	}
 
+	if query, ok = paginate(c, query, mealSorts, "-date"); !ok {
+		return
+	}
+
 	// Execute query with preloaded foods
-	if err := query.Preload("Foods").Order("date DESC, meal_type").Find(&meals).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var meals []models.Meal
+	if err := query.Preload("Foods").Find(&meals).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if err := personalizeFoods(h.db, userID, mealFoodPointers(meals)); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if err := loadMealQuantities(h.db, meals); err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
@@ -75,65 +116,152 @@ func (h *MealHandler) GetUserMeals(c *gin.Context) {
 }
 
 func (h *MealHandler) AddFoodToMeal(c *gin.Context) {
-	mealID := c.Param("id")
-
-	// Parse the request body to get the foodId
-	var request struct {
-		FoodID string `json:"foodId"`
-	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	mealID, ok := parseIDParam(c, "id")
+	if !ok {
 		return
 	}
 
-	if request.FoodID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "foodId is required"})
+	// Parse the request body to get the foodId
+	var request models.AddFoodRequest
+	if !bindJSON(c, &request) {
 		return
 	}
 
 	// Vérifier si l'aliment existe déjà dans la base de données
 	var existingFood models.Food
 	if err := h.db.Where("fdc_id = ?", request.FoodID).First(&existingFood).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found. Please search for it first."})
-			return
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
-			return
-		}
+		respondDBError(c, err, "Food not found. Please search for it first.")
+		return
 	}
 
 	var meal models.Meal
 	if err := h.db.Preload("Foods").First(&meal, mealID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Meal not found"})
+		respondDBError(c, err, "Meal not found")
+		return
+	}
+	if existingFood.UserID != nil && *existingFood.UserID != meal.UserID {
+		respondError(c, http.StatusNotFound, models.ErrNotFound, "Food not found. Please search for it first.")
 		return
 	}
 
 	// Vérifier si l'aliment est déjà dans le repas
-	var foodExists bool
 	for _, food := range meal.Foods {
 		if food.FdcID == request.FoodID {
-			foodExists = true
-			break
+			respondError(c, http.StatusConflict, models.ErrConflict, "Food is already in this meal")
+			return
 		}
 	}
 
-	if foodExists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Food is already in this meal"})
+	entry, ok := h.mealFood(c, request, existingFood)
+	if !ok {
 		return
 	}
+	entry.MealID = meal.ID
 
 	// Ajouter l'aliment au repas
-	if err := h.db.Model(&meal).Association("Foods").Append(&existingFood); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add food to meal: " + err.Error()})
+	if err := h.db.Create(&entry).Error; err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
 	// Recharger le repas avec ses aliments
 	if err := h.db.Preload("Foods").First(&meal, mealID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload meal: " + err.Error()})
+		respondInternalError(c, err)
+		return
+	}
+	if err := personalizeFoods(h.db, meal.UserID, foodPointers(meal.Foods)); err != nil {
+		respondInternalError(c, err)
 		return
 	}
+	meals := []models.Meal{meal}
+	if err := loadMealQuantities(h.db, meals); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, meals[0])
+}
+
+// mealFood returns the meal_foods entry of the food with the quantity of the
+// request, in grams or in servings of one of the food's portions. It answers
+// 422 and returns false when the quantity is invalid.
+func (h *MealHandler) mealFood(c *gin.Context, request models.AddFoodRequest, food models.Food) (models.MealFood, bool) {
+	entry := models.MealFood{FoodID: food.ID, Grams: 100}
+	switch {
+	case request.PortionID != nil && request.Grams != nil:
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid request body",
+			models.FieldError{Field: "grams", Message: "must not be given with portionId"})
+		return entry, false
+	case request.PortionID == nil && request.Servings != nil:
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid request body",
+			models.FieldError{Field: "servings", Message: "requires portionId"})
+		return entry, false
+	case request.Grams != nil:
+		entry.Grams = *request.Grams
+	case request.PortionID != nil:
+		var portion models.FoodPortion
+		err := h.db.Where("id = ? AND food_id = ?", *request.PortionID, food.ID).First(&portion).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid request body",
+				models.FieldError{Field: "portionId", Message: "must be one of the food's portions"})
+			return entry, false
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return entry, false
+		}
+
+		servings := 1.0
+		if request.Servings != nil {
+			servings = *request.Servings
+		}
+		entry.Grams = servings * portion.GramWeight
+		entry.Measure = portion.Description
+		if servings != 1 {
+			entry.Measure = strconv.FormatFloat(servings, 'f', -1, 64) + " x " + portion.Description
+		}
+	}
+	return entry, true
+}
+
+// loadMealQuantities sets the quantity eaten on the foods of the meals
+func loadMealQuantities(db *gorm.DB, meals []models.Meal) error {
+	ids := make([]uint, len(meals))
+	for i, meal := range meals {
+		ids[i] = meal.ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
 
-	c.JSON(http.StatusOK, meal)
+	var entries []models.MealFood
+	if err := db.Where("meal_id IN ?", ids).Find(&entries).Error; err != nil {
+		return err
+	}
+	type key struct{ meal, food uint }
+	quantities := make(map[key]models.MealFood, len(entries))
+	for _, entry := range entries {
+		quantities[key{entry.MealID, entry.FoodID}] = entry
+	}
+
+	for i := range meals {
+		for j := range meals[i].Foods {
+			food := &meals[i].Foods[j]
+			entry := quantities[key{meals[i].ID, food.ID}]
+			food.Grams, food.Measure = entry.Grams, entry.Measure
+		}
+	}
+	return nil
+}
+
+// mealTypeQuery parses the optional type query parameter
+func mealTypeQuery(c *gin.Context) (models.MealType, bool) {
+	mealType := models.MealType(c.Query("type"))
+	switch mealType {
+	case "", models.Breakfast, models.Lunch, models.Break, models.Dinner:
+		return mealType, true
+	}
+	respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid meal type",
+		models.FieldError{Field: "type", Message: "must be one of: breakfast, lunch, break, dinner"})
+	return "", false
 }
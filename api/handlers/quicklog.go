@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// QuickLog logs the foods of a sentence such as "150g chicken breast and 1
+// cup rice for lunch yesterday" in the user's meal. With dryRun, it only
+// reports how it reads the sentence.
+func (h *UserHandler) QuickLog(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid dryRun",
+			models.FieldError{Field: "dryRun", Message: "must be true or false"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		respondDBError(c, err, "User not found")
+		return
+	}
+
+	var request models.QuickLogRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	now := time.Now()
+	if request.Now != nil {
+		now = *request.Now
+	}
+
+	sentence, err := services.ParseQuickLog(request.Text, now)
+	if errors.Is(err, services.ErrInvalidQuickLog) {
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Invalid request body",
+			models.FieldError{Field: "text", Message: `must name a food, e.g. "150g rice for lunch"`})
+		return
+	}
+
+	report := models.QuickLog{Text: request.Text, DryRun: dryRun}
+	if err := services.QuickLog(h.db, userID, sentence, &report); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
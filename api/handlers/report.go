@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// WeeklyReport answers the user's nutrition report of the ISO week of the
+// week query parameter, e.g. 2024-W10, the current one by default
+func (h *UserHandler) WeeklyReport(c *gin.Context) {
+	h.respondReport(c, models.ReportWeekly, "week", "must be an ISO week formatted as YYYY-Www")
+}
+
+// MonthlyReport answers the user's nutrition report of the month of the
+// month query parameter, e.g. 2024-03, the current one by default
+func (h *UserHandler) MonthlyReport(c *gin.Context) {
+	h.respondReport(c, models.ReportMonthly, "month", "must be a month formatted as YYYY-MM")
+}
+
+// respondReport answers the report of the period named by the param query
+// parameter, with the tolerance of the tolerance one
+func (h *UserHandler) respondReport(c *gin.Context, period, param, format string) {
+	now := time.Now().UTC()
+	label, from, to, err := services.ReportPeriod(period, c.Query(param), now)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid "+param,
+			models.FieldError{Field: param, Message: format})
+		return
+	}
+	tolerance := float64(services.DefaultReportTolerance)
+	if raw := c.Query("tolerance"); raw != "" {
+		tolerance, err = strconv.ParseFloat(raw, 64)
+		if err != nil || tolerance < 0 || tolerance > 100 {
+			respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid tolerance",
+				models.FieldError{Field: "tolerance", Message: "must be a percentage between 0 and 100"})
+			return
+		}
+	}
+
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+	report, err := services.NutritionReport(h.db, user.ID, period, label, from, to, now, tolerance)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// maxPrintableDays caps the period of the printable report, which lists
+// every meal
+const maxPrintableDays = 366
+
+// PrintableReport answers the user's printable report of the days between
+// from and to, 30 days by default, as an HTML page or a PDF
+func (h *UserHandler) PrintableReport(c *gin.Context) {
+	format := c.DefaultQuery("format", services.PrintableHTML)
+	if !slices.Contains(services.PrintableFormats, format) {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid format",
+			models.FieldError{Field: "format", Message: "must be one of: " + strings.Join(services.PrintableFormats, ", ")})
+		return
+	}
+	from, to, ok := dateRangeQuery(c)
+	if !ok {
+		return
+	}
+	// Without a bound, the report covers 30 days from from or to to, or the
+	// last 30 days
+	now := time.Now().UTC()
+	switch {
+	case from.IsZero() && to.IsZero():
+		to = now.Truncate(24 * time.Hour)
+		from = to.AddDate(0, 0, -29)
+	case from.IsZero():
+		from = to.AddDate(0, 0, -29)
+	case to.IsZero():
+		to = from.AddDate(0, 0, 29)
+	}
+	if to.Sub(from) >= maxPrintableDays*24*time.Hour {
+		respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid date range",
+			models.FieldError{Field: "from", Message: fmt.Sprintf("must be less than %d days before to", maxPrintableDays)})
+		return
+	}
+
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+	report, err := services.BuildPrintableReport(h.db, user, from, to, now)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	var body bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	if format == services.PrintablePDF {
+		contentType = "application/pdf"
+		err = report.WritePDF(&body)
+	} else {
+		err = report.WriteHTML(&body)
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("report-%d-%s-%s.%s", user.ID, from.Format("2006-01-02"), to.Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, body.Bytes())
+}
@@ -1,11 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/ZUHOWKS/my-body-tracker/api/models"
-	"github.com/ZUHOWKS/my-body-tracker/internal/calculator"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -18,25 +19,105 @@ func NewUserHandler(db *gorm.DB) *UserHandler {
 	return &UserHandler{db: db}
 }
 
+// findUser loads the user of the :id route parameter, answering 400 or 404
+// when there is none
+func (h *UserHandler) findUser(c *gin.Context, user *models.User) bool {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return false
+	}
+
+	if err := h.db.First(user, id).Error; err != nil {
+		respondDBError(c, err, "User not found")
+		return false
+	}
+	return true
+}
+
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &user) {
 		return
 	}
 
 	if err := h.db.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, user)
 }
 
+// DeleteUser soft-deletes the user and their data, which can be restored
+// with RestoreUser until the retention of deleted users ends
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	retention, err := services.UserRetention()
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	user, err := services.DeleteUser(h.db, id)
+	if err != nil {
+		respondDBError(c, err, "User not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.UserDeletion{
+		UserID:       user.ID,
+		DeletedAt:    user.DeletedAt.Time,
+		RestoreUntil: user.DeletedAt.Time.Add(retention),
+	})
+}
+
+// RestoreUser brings back a deleted user with their data
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	retention, err := services.UserRetention()
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	user, err := services.RestoreUser(h.db, id, retention)
+	switch {
+	case errors.Is(err, services.ErrUserNotDeleted):
+		respondError(c, http.StatusConflict, models.ErrConflict, "User is not deleted")
+		return
+	case errors.Is(err, services.ErrRestoreExpired):
+		respondError(c, http.StatusNotFound, models.ErrNotFound, "User was deleted too long ago to be restored")
+		return
+	case err != nil:
+		respondDBError(c, err, "User not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// userSorts are the orders accepted by ListUsers
+var userSorts = sortOptions{
+	"id":      {"id"},
+	"name":    {"last_name", "first_name"},
+	"created": {"created_at"},
+}
+
 func (h *UserHandler) ListUsers(c *gin.Context) {
+	query, ok := paginate(c, h.db.Model(&models.User{}), userSorts, "id")
+	if !ok {
+		return
+	}
+
 	var users []models.User
-	if err := h.db.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := query.Find(&users).Error; err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
@@ -45,44 +126,27 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 
 func (h *UserHandler) GetUserStats(c *gin.Context) {
 	var user models.User
-	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !h.findUser(c, &user) {
 		return
 	}
 
-	bmi := calculator.CalculateBMI(user.Weight, user.Height)
-	bfp := calculator.CalculateBFP(bmi, user.Age, user.Sex)
-	img := calculator.CalculateIMG(user.Weight, user.Height, user.Sex)
-	bmr := calculator.CalculateBasalMetabolism(user.Weight, user.Height, user.Age, user.Sex)
-
-	// Log the response to debug
-	response := gin.H{
-		"height": user.Height,
-		"weight": user.Weight,
-		"bmi":    bmi,
-		"bfp":    bfp,
-		"img":    img,
-		"bmr":    bmr,
-	}
-
-	// Print to server logs
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, services.UserStats(user))
 }
 
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	var user models.User
-	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !h.findUser(c, &user) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	id := user.ID
+	if !bindJSON(c, &user) {
 		return
 	}
+	user.ID = id
 
 	if err := h.db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalError(c, err)
 		return
 	}
 
@@ -91,8 +155,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 
 func (h *UserHandler) GetUser(c *gin.Context) {
 	var user models.User
-	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !h.findUser(c, &user) {
 		return
 	}
 
@@ -100,18 +163,14 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 }
 
 func (h *UserHandler) SetUserTargets(c *gin.Context) {
-	userID := c.Param("id")
-
-	var target models.Target
-	if err := c.ShouldBindJSON(&target); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// Vérifier si l'utilisateur existe
+	var user models.User
+	if !h.findUser(c, &user) {
 		return
 	}
 
-	// Vérifier si l'utilisateur existe
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	var target models.Target
+	if !bindJSON(c, &target) {
 		return
 	}
 
@@ -122,21 +181,22 @@ func (h *UserHandler) SetUserTargets(c *gin.Context) {
 	var existingTarget models.Target
 	result := h.db.Where("user_id = ?", user.ID).First(&existingTarget)
 
-	if result.Error == gorm.ErrRecordNotFound {
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		// Créer de nouveaux objectifs
 		if err := h.db.Create(&target).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondInternalError(c, err)
 			return
 		}
 	} else if result.Error != nil {
 		// Une erreur s'est produite
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		respondInternalError(c, result.Error)
 		return
 	} else {
 		// Mettre à jour les objectifs existants
 		target.ID = existingTarget.ID
+		target.CreatedAt = existingTarget.CreatedAt
 		if err := h.db.Save(&target).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondInternalError(c, err)
 			return
 		}
 	}
@@ -145,15 +205,14 @@ func (h *UserHandler) SetUserTargets(c *gin.Context) {
 }
 
 func (h *UserHandler) GetUserTargets(c *gin.Context) {
-	userID := c.Param("id")
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
 
 	var target models.Target
 	if err := h.db.Where("user_id = ?", userID).First(&target).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No targets found for this user"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondDBError(c, err, "No targets found for this user")
 		return
 	}
 
@@ -161,18 +220,14 @@ func (h *UserHandler) GetUserTargets(c *gin.Context) {
 }
 
 func (h *UserHandler) RecordWeight(c *gin.Context) {
-	userID := c.Param("id")
-
-	var record models.WeightRecord
-	if err := c.ShouldBindJSON(&record); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// Vérifier si l'utilisateur existe
+	var user models.User
+	if !h.findUser(c, &user) {
 		return
 	}
 
-	// Vérifier si l'utilisateur existe
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	var record models.WeightRecord
+	if !bindJSON(c, &record) {
 		return
 	}
 
@@ -182,28 +237,46 @@ func (h *UserHandler) RecordWeight(c *gin.Context) {
 		record.Date = time.Now()
 	}
 
-	// Mettre à jour le poids de l'utilisateur
-	user.Weight = record.Weight
-	if err := h.db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Mettre à jour le poids de l'utilisateur
+		if err := tx.Model(&user).Update("weight", record.Weight).Error; err != nil {
+			return err
+		}
 
-	// Créer l'enregistrement de poids
-	if err := h.db.Create(&record).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// Créer l'enregistrement de poids
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, record)
+	c.JSON(http.StatusCreated, record)
+}
+
+// weightSorts are the orders accepted by GetWeightHistory
+var weightSorts = sortOptions{
+	"date":   {"date"},
+	"weight": {"weight"},
 }
 
 func (h *UserHandler) GetWeightHistory(c *gin.Context) {
-	userID := c.Param("id")
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+
+	query, ok := filterDateRange(c, h.db.Model(&models.WeightRecord{}).Where("user_id = ?", user.ID), "date")
+	if !ok {
+		return
+	}
+	if query, ok = paginate(c, query, weightSorts, "-date"); !ok {
+		return
+	}
 
 	var records []models.WeightRecord
-	if err := h.db.Where("user_id = ?", userID).Order("date desc").Find(&records).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := query.Find(&records).Error; err != nil {
+		respondInternalError(c, err)
 		return
 	}
 
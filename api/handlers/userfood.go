@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultFoodUsageDays  = 90
+	maxFoodUsageDays      = 3650
+	defaultFoodUsageLimit = 10
+	maxFoodUsageLimit     = 50
+
+	// mealHourWindow is how many hours a meal may be away from the requested
+	// hour to be taken into account
+	mealHourWindow = 2
+)
+
+// GetRecentFoods returns the foods a user logged, most recently eaten first
+func (h *UserHandler) GetRecentFoods(c *gin.Context) {
+	h.foodUsage(c, func(a, b models.FoodUsage) bool {
+		return a.LastUsed.After(b.LastUsed)
+	})
+}
+
+// GetFrequentFoods returns the foods a user logged, most often eaten first
+func (h *UserHandler) GetFrequentFoods(c *gin.Context) {
+	h.foodUsage(c, func(a, b models.FoodUsage) bool {
+		if a.Uses != b.Uses {
+			return a.Uses > b.Uses
+		}
+		return a.LastUsed.After(b.LastUsed)
+	})
+}
+
+// foodUsage answers with the foods of the user's meals of the last days,
+// optionally of one meal type or around an hour of the day, ordered by less
+func (h *UserHandler) foodUsage(c *gin.Context, less func(a, b models.FoodUsage) bool) {
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+
+	mealType, ok := mealTypeQuery(c)
+	if !ok {
+		return
+	}
+	days, ok := positiveIntQuery(c, "days", defaultFoodUsageDays, maxFoodUsageDays)
+	if !ok {
+		return
+	}
+	limit, ok := positiveIntQuery(c, "limit", defaultFoodUsageLimit, maxFoodUsageLimit)
+	if !ok {
+		return
+	}
+	hour := -1
+	if raw := c.Query("hour"); raw != "" {
+		var err error
+		if hour, err = strconv.Atoi(raw); err != nil || hour < 0 || hour > 23 {
+			respondError(c, http.StatusBadRequest, models.ErrBadRequest, "Invalid hour",
+				models.FieldError{Field: "hour", Message: "must be an integer between 0 and 23"})
+			return
+		}
+	}
+
+	query := h.db.Where("user_id = ? AND date >= ?", user.ID, time.Now().AddDate(0, 0, -days))
+	if mealType != "" {
+		query = query.Where("meal_type = ?", mealType)
+	}
+
+	var meals []models.Meal
+	if err := query.Preload("Foods").Find(&meals).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	usage := map[uint]*models.FoodUsage{}
+	for _, meal := range meals {
+		if hour >= 0 && hourDistance(meal.Date.Local().Hour(), hour) > mealHourWindow {
+			continue
+		}
+		for _, food := range meal.Foods {
+			u, ok := usage[food.ID]
+			if !ok {
+				u = &models.FoodUsage{Food: food}
+				usage[food.ID] = u
+			}
+			u.Uses++
+			if meal.Date.After(u.LastUsed) {
+				u.LastUsed = meal.Date
+			}
+		}
+	}
+
+	foods := make([]models.FoodUsage, 0, len(usage))
+	for _, u := range usage {
+		foods = append(foods, *u)
+	}
+	sort.Slice(foods, func(i, j int) bool {
+		if less(foods[i], foods[j]) != less(foods[j], foods[i]) {
+			return less(foods[i], foods[j])
+		}
+		return foods[i].Food.ID < foods[j].Food.ID
+	})
+	if len(foods) > limit {
+		foods = foods[:limit]
+	}
+
+	pointers := make([]*models.Food, len(foods))
+	for i := range foods {
+		pointers[i] = &foods[i].Food
+	}
+	if err := personalizeFoods(h.db, user.ID, pointers); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, foods)
+}
+
+// hourDistance returns the number of hours between two hours of the day,
+// going around midnight if shorter
+func hourDistance(a, b int) int {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	return min(d, 24-d)
+}
+
+// findFood loads the food of the :fdcId route parameter for a user,
+// answering 404 when it was never returned by a search or is another user's
+// custom food
+func findFood(c *gin.Context, db *gorm.DB, userID uint, food *models.Food) bool {
+	if err := db.Where("fdc_id = ?", c.Param("fdcId")).First(food).Error; err != nil {
+		respondDBError(c, err, "Food not found. Please search for it first.")
+		return false
+	}
+	// Custom foods are only visible to their user
+	if food.UserID != nil && *food.UserID != userID {
+		respondError(c, http.StatusNotFound, models.ErrNotFound, "Food not found. Please search for it first.")
+		return false
+	}
+	return true
+}
+
+// personalizeFoods marks the user's favourite foods and replaces the values
+// of those they overrode
+func personalizeFoods(db *gorm.DB, userID uint, foods []*models.Food) error {
+	if userID == 0 || len(foods) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(foods))
+	for i, food := range foods {
+		ids[i] = food.ID
+	}
+
+	// Favourites have no deleted_at: those of a deleted user are skipped
+	// through the user's
+	var favorites []models.FavoriteFood
+	err := db.Where("user_id = ? AND food_id IN ?", userID, ids).
+		Where("user_id IN (?)", db.Model(&models.User{}).Select("id")).
+		Find(&favorites).Error
+	if err != nil {
+		return err
+	}
+	var overrides []models.FoodOverride
+	if err := db.Where("user_id = ? AND food_id IN ?", userID, ids).Find(&overrides).Error; err != nil {
+		return err
+	}
+
+	favorite := map[uint]bool{}
+	for _, f := range favorites {
+		favorite[f.FoodID] = true
+	}
+	overridden := map[uint]models.FoodOverride{}
+	for _, o := range overrides {
+		overridden[o.FoodID] = o
+	}
+
+	for _, food := range foods {
+		food.Favorite = favorite[food.ID]
+		if o, ok := overridden[food.ID]; ok {
+			o.Apply(food)
+		}
+	}
+	return nil
+}
+
+// foodPointers returns pointers to the foods, for personalizeFoods
+func foodPointers(foods []models.Food) []*models.Food {
+	pointers := make([]*models.Food, len(foods))
+	for i := range foods {
+		pointers[i] = &foods[i]
+	}
+	return pointers
+}
+
+// mealFoodPointers returns pointers to the foods of the meals, for
+// personalizeFoods
+func mealFoodPointers(meals []models.Meal) []*models.Food {
+	var pointers []*models.Food
+	for i := range meals {
+		pointers = append(pointers, foodPointers(meals[i].Foods)...)
+	}
+	return pointers
+}
+
+func (h *UserHandler) ListFavoriteFoods(c *gin.Context) {
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+
+	var foods []models.Food
+	err := h.db.Joins("JOIN favorite_foods ON favorite_foods.food_id = foods.id").
+		Where("favorite_foods.user_id = ?", user.ID).
+		Order("favorite_foods.created_at DESC").
+		Find(&foods).Error
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	if err := personalizeFoods(h.db, user.ID, foodPointers(foods)); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, foods)
+}
+
+func (h *UserHandler) AddFavoriteFood(c *gin.Context) {
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+	var food models.Food
+	if !findFood(c, h.db, user.ID, &food) {
+		return
+	}
+
+	// Starring a food twice keeps it starred
+	favorite := models.FavoriteFood{UserID: user.ID, FoodID: food.ID}
+	if err := h.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&favorite).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	if err := personalizeFoods(h.db, user.ID, []*models.Food{&food}); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, food)
+}
+
+func (h *UserHandler) RemoveFavoriteFood(c *gin.Context) {
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+	var food models.Food
+	if !findFood(c, h.db, user.ID, &food) {
+		return
+	}
+
+	if err := h.db.Where("user_id = ? AND food_id = ?", user.ID, food.ID).Delete(&models.FavoriteFood{}).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) ListFoodOverrides(c *gin.Context) {
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+
+	var overrides []models.FoodOverride
+	if err := h.db.Preload("Food").Where("user_id = ?", user.ID).Order("id").Find(&overrides).Error; err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, overrides)
+}
+
+func (h *UserHandler) SetFoodOverride(c *gin.Context) {
+	var override models.FoodOverride
+	if !bindJSON(c, &override) {
+		return
+	}
+	if override.Name == nil && override.Protein == nil && override.Carbs == nil && override.Fat == nil &&
+		override.Calories == nil && override.Fiber == nil && override.ServingSize == nil {
+		respondError(c, http.StatusUnprocessableEntity, models.ErrValidation, "Nothing to override",
+			models.FieldError{Field: "body", Message: "must set at least one of name, protein, carbs, fat, calories, fiber, servingSize"})
+		return
+	}
+
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+	var food models.Food
+	if !findFood(c, h.db, user.ID, &food) {
+		return
+	}
+
+	override.UserID = user.ID
+	override.FoodID = food.ID
+
+	// Remplacer la personnalisation existante le cas échéant
+	var existing models.FoodOverride
+	err := h.db.Where("user_id = ? AND food_id = ?", user.ID, food.ID).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = h.db.Omit("Food").Create(&override).Error
+	case err == nil:
+		override.ID = existing.ID
+		override.CreatedAt = existing.CreatedAt
+		err = h.db.Omit("Food").Save(&override).Error
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	override.Food = food
+	c.JSON(http.StatusOK, override)
+}
+
+func (h *UserHandler) DeleteFoodOverride(c *gin.Context) {
+	var user models.User
+	if !h.findUser(c, &user) {
+		return
+	}
+	var food models.Food
+	if !findFood(c, h.db, user.ID, &food) {
+		return
+	}
+
+	// Supprimer définitivement pour pouvoir en créer une nouvelle ensuite
+	result := h.db.Unscoped().Where("user_id = ? AND food_id = ?", user.ID, food.ID).Delete(&models.FoodOverride{})
+	if result.Error != nil {
+		respondInternalError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, models.ErrNotFound, "No override for this food")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
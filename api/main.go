@@ -1,19 +1,21 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"time"
 
-	"github.com/ZUHOWKS/my-body-tracker/api/handlers"
 	"github.com/ZUHOWKS/my-body-tracker/api/models"
-	"github.com/gin-gonic/gin"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// Entrypoint starts the API server, or runs the maintenance command given on
+// the command line, e.g. `bodytracker_api import-fdc <path>`
 func Entrypoint() {
 	// Load .env file if it exists
 	_ = godotenv.Load() // Ignore error if file doesn't exist
@@ -30,50 +32,79 @@ func Entrypoint() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	httpClient := &http.Client{}
-
-	db.AutoMigrate(
-		&models.User{},
-		&models.Food{},
-		&models.Meal{},
-		&models.Target{},
-		&models.WeightRecord{},
-	)
-
-	// Initialize handlers
-	userHandler := handlers.NewUserHandler(db)
-	foodHandler := handlers.NewFoodHandler(db, httpClient)
-	mealHandler := handlers.NewMealHandler(db)
-
-	r := gin.Default()
+	if err := Migrate(db); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 
-	userRoutes := r.Group("/users")
-	{
-		userRoutes.GET("/", userHandler.ListUsers)
-		userRoutes.POST("/", userHandler.CreateUser)
-		userRoutes.GET("/:id", userHandler.GetUser)
-		userRoutes.GET("/:id/stats", userHandler.GetUserStats)
-		userRoutes.PUT("/:id", userHandler.UpdateUser)
-		userRoutes.GET("/:id/targets", userHandler.GetUserTargets)
-		userRoutes.POST("/:id/targets", userHandler.SetUserTargets)
-		userRoutes.POST("/:id/weight", userHandler.RecordWeight)
-		userRoutes.GET("/:id/weight/history", userHandler.GetWeightHistory)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(db, os.Args[1:]))
 	}
 
-	foodRoutes := r.Group("/foods")
-	{
-		foodRoutes.GET("/search", foodHandler.SearchFood)
+	provider, err := newFoodProvider()
+	if err != nil {
+		log.Fatal("Failed to load FDC API keys:", err)
 	}
 
-	mealRoutes := r.Group("/meals")
-	{
-		mealRoutes.POST("/", mealHandler.CreateMeal)
-		mealRoutes.GET("/user/:userId", mealHandler.GetUserMeals)
-		mealRoutes.POST("/:id/foods", mealHandler.AddFoodToMeal)
+	// Purge the users deleted for longer than the retention, daily
+	retention, err := services.UserRetention()
+	if err != nil {
+		log.Fatal("Failed to load the retention of deleted users:", err)
 	}
+	go services.RunUserPurge(context.Background(), db, retention, 24*time.Hour)
+
+	r := NewRouter(db, provider)
 
 	// Start server
 	if err := r.Run(":8080"); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+// newFoodProvider returns the FDC provider, or the offline one when no FDC API
+// key is configured
+func newFoodProvider() (services.FoodProvider, error) {
+	keys, err := services.LoadFDCKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		log.Printf("Warning: no FDC API key configured (%s or %s), food searches only use the local database",
+			services.FDCKeysEnv, services.FDCKeysFileEnv)
+		return services.OfflineProvider{}, nil
+	}
+	log.Printf("Using %d FDC API key(s)", len(keys))
+
+	client := services.NewHTTPClient(services.NewFDCKeys(keys).Transport(nil), services.DefaultHTTPOptions)
+	return services.NewFDCProvider(client), nil
+}
+
+// Migrate creates or updates the tables of the API's models and the food
+// search index
+func Migrate(db *gorm.DB) error {
+	// The meal_foods join table records the quantity of each food eaten
+	for _, join := range []struct {
+		model any
+		field string
+	}{{&models.Meal{}, "Foods"}, {&models.Food{}, "Meals"}} {
+		if err := db.SetupJoinTable(join.model, join.field, &models.MealFood{}); err != nil {
+			return err
+		}
+	}
+
+	err := db.AutoMigrate(
+		&models.User{},
+		&models.Food{},
+		&models.FoodPortion{},
+		&models.FoodQuery{},
+		&models.FDCImport{},
+		&models.FavoriteFood{},
+		&models.FoodOverride{},
+		&models.Meal{},
+		&models.Target{},
+		&models.WeightRecord{},
+	)
+	if err != nil {
+		return err
+	}
+	return services.EnsureFoodSearchIndex(db)
+}
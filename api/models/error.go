@@ -0,0 +1,31 @@
+package models
+
+// ErrorCode identifies the kind of error returned by the API
+type ErrorCode string
+
+const (
+	ErrBadRequest ErrorCode = "bad_request"
+	ErrValidation ErrorCode = "validation_failed"
+	ErrNotFound   ErrorCode = "not_found"
+	ErrConflict   ErrorCode = "conflict"
+	ErrUpstream   ErrorCode = "upstream_error"
+	// ErrUpstreamUnavailable means the food provider is down or rate limits
+	// the API: the request may succeed later
+	ErrUpstreamUnavailable ErrorCode = "upstream_unavailable"
+	ErrUpstreamTimeout     ErrorCode = "upstream_timeout"
+	ErrInternal            ErrorCode = "internal_error"
+)
+
+// FieldError describes why a field of a request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse is the body of every error returned by the API
+type ErrorResponse struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import "time"
+
+// UserExportVersion is the version of the user export format. It is raised
+// when the format changes in a way older versions can't import.
+const UserExportVersion = 1
+
+// UserExport is all the data of a user, exported to be kept or restored
+// into another instance. Meals, favourites and overrides reference their
+// foods by FDC ID, and Foods holds those foods so that an instance which
+// doesn't have them can create them.
+type UserExport struct {
+	Version       int                    `json:"version" binding:"required,gte=1"`
+	ExportedAt    time.Time              `json:"exportedAt"`
+	Profile       ExportedProfile        `json:"profile"`
+	Targets       *ExportedTargets       `json:"targets" binding:"omitempty"`
+	WeightRecords []ExportedWeightRecord `json:"weightRecords" binding:"dive"`
+	Meals         []ExportedMeal         `json:"meals" binding:"dive"`
+	Foods         []ExportedFood         `json:"foods" binding:"dive"`
+	Favorites     []ExportedFavorite     `json:"favorites" binding:"dive"`
+	Overrides     []ExportedOverride     `json:"overrides" binding:"dive"`
+}
+
+// ExportedProfile is the profile of an exported user, validated as when
+// creating one
+type ExportedProfile struct {
+	FirstName     string    `json:"firstName" binding:"required,max=100"`
+	LastName      string    `json:"lastName" binding:"required,max=100"`
+	Age           int       `json:"age" binding:"required,gte=1,lte=130"`
+	Weight        float64   `json:"weight" binding:"required,gt=0,lte=500"`
+	Height        int       `json:"height" binding:"required,gte=50,lte=300"`
+	Goal          string    `json:"goal" binding:"max=200"`
+	Sex           int       `json:"sex" binding:"oneof=0 1"`
+	ActivityLevel int       `json:"activityLevel" binding:"gte=0,lte=7"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type ExportedTargets struct {
+	Calories  float64   `json:"calories" binding:"gte=0,lte=20000"`
+	Protein   float64   `json:"protein" binding:"gte=0,lte=2000"`
+	Carbs     float64   `json:"carbs" binding:"gte=0,lte=2000"`
+	Fat       float64   `json:"fat" binding:"gte=0,lte=2000"`
+	Fiber     float64   `json:"fiber" binding:"gte=0,lte=2000"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ExportedWeightRecord struct {
+	Weight    float64   `json:"weight" binding:"required,gt=0,lte=500"`
+	Date      time.Time `json:"date"`
+	Note      string    `json:"note,omitempty" binding:"max=500"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ExportedMeal struct {
+	Type      MealType           `json:"type" binding:"required,oneof=breakfast lunch break dinner"`
+	Date      time.Time          `json:"date"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Foods     []ExportedMealFood `json:"foods" binding:"dive"`
+}
+
+// ExportedMealFood is a food eaten in an exported meal
+type ExportedMealFood struct {
+	FdcID   string  `json:"fdcId" binding:"required"`
+	Grams   float64 `json:"grams" binding:"gt=0,lte=10000"`
+	Measure string  `json:"measure,omitempty"`
+}
+
+// ExportedFood is a food referenced by an export. Custom foods are the
+// user's own and are always created by an import; the others are shared
+// and only created when the instance doesn't have them.
+type ExportedFood struct {
+	FdcID            string            `json:"fdcId" binding:"required"`
+	Name             string            `json:"name" binding:"required"`
+	DataType         string            `json:"dataType"`
+	BrandOwner       string            `json:"brandOwner,omitempty"`
+	Custom           bool              `json:"custom,omitempty"`
+	Protein          float64           `json:"protein" binding:"gte=0"`
+	Carbs            float64           `json:"carbs" binding:"gte=0"`
+	Fat              float64           `json:"fat" binding:"gte=0"`
+	Calories         float64           `json:"calories" binding:"gte=0"`
+	Fiber            float64           `json:"fiber" binding:"gte=0"`
+	ServingSize      float64           `json:"servingSize" binding:"gte=0"`
+	ServingSizeUnit  string            `json:"servingSizeUnit,omitempty"`
+	HouseholdServing string            `json:"householdServing,omitempty"`
+	Portions         []ExportedPortion `json:"portions,omitempty" binding:"dive"`
+}
+
+type ExportedPortion struct {
+	Description string  `json:"description" binding:"required"`
+	GramWeight  float64 `json:"gramWeight" binding:"gt=0"`
+}
+
+type ExportedFavorite struct {
+	FdcID     string    `json:"fdcId" binding:"required"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ExportedOverride is a user's override of a food, nil fields keeping the
+// food's values
+type ExportedOverride struct {
+	FdcID       string    `json:"fdcId" binding:"required"`
+	Name        *string   `json:"name" binding:"omitempty,min=1,max=200"`
+	Protein     *float64  `json:"protein" binding:"omitempty,gte=0,lte=1000"`
+	Carbs       *float64  `json:"carbs" binding:"omitempty,gte=0,lte=1000"`
+	Fat         *float64  `json:"fat" binding:"omitempty,gte=0,lte=1000"`
+	Calories    *float64  `json:"calories" binding:"omitempty,gte=0,lte=10000"`
+	Fiber       *float64  `json:"fiber" binding:"omitempty,gte=0,lte=1000"`
+	ServingSize *float64  `json:"servingSize" binding:"omitempty,gt=0,lte=10000"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
@@ -1,16 +1,99 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Food struct {
 	gorm.Model
 	FdcID       string  `json:"fdcId" gorm:"uniqueIndex"`
 	Name        string  `json:"name"`
+	DataType    string  `json:"dataType" gorm:"index"`
+	BrandOwner  string  `json:"brandOwner"`
 	Protein     float64 `json:"protein"`
 	Carbs       float64 `json:"carbs"`
 	Fat         float64 `json:"fat"`
 	Calories    float64 `json:"calories"`
 	Fiber       float64 `json:"fiber"`
 	ServingSize float64 `json:"servingSize"`
-	Meals       []Meal  `json:"meals" gorm:"many2many:meal_foods;"`
+	// ServingSizeUnit is the unit of ServingSize, e.g. "g" or "ml"
+	ServingSizeUnit string `json:"servingSizeUnit"`
+	// HouseholdServing describes the serving of Branded foods, e.g. "1 cup"
+	HouseholdServing string `json:"householdServing"`
+	// Portions are loaded with the food's detail only
+	Portions []FoodPortion `json:"portions,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	// DetailFetchedAt is when the portions were last fetched from the food
+	// provider, nil if they never were
+	DetailFetchedAt *time.Time `json:"-"`
+	// UserID is the user who created the food, for custom foods only: those
+	// are visible to them alone
+	UserID *uint  `json:"userId,omitempty" gorm:"index"`
+	Meals  []Meal `json:"meals" gorm:"many2many:meal_foods;"`
+	// Favorite and Overridden describe the food for the user a response is
+	// for: starred by them, or with values replaced by their FoodOverride
+	Favorite   bool `json:"favorite,omitempty" gorm:"-"`
+	Overridden bool `json:"overridden,omitempty" gorm:"-"`
+	// Grams and Measure are set on the foods of a meal: the quantity eaten,
+	// and the household measure it was logged in if any
+	Grams   float64 `json:"grams,omitempty" gorm:"-"`
+	Measure string  `json:"measure,omitempty" gorm:"-"`
+}
+
+// FoodPortion is a household measure of a food, e.g. "1 cup, chopped", with
+// its weight
+type FoodPortion struct {
+	ID          uint    `json:"id" gorm:"primarykey"`
+	FoodID      uint    `json:"-" gorm:"index"`
+	Description string  `json:"description"`
+	GramWeight  float64 `json:"gramWeight"`
+}
+
+// Sources of the foods returned by a search
+const (
+	// FoodSourceLocal means the foods come from the local database only
+	FoodSourceLocal = "local"
+	// FoodSourceProvider means the food provider was queried first
+	FoodSourceProvider = "provider"
+)
+
+// FoodSearchResponse is the body returned by the food search endpoint: a
+// page of the results, with the number of results across all pages
+type FoodSearchResponse struct {
+	Foods      []Food `json:"foods"`
+	Source     string `json:"source"`
+	Page       int    `json:"page"`
+	TotalHits  int    `json:"totalHits"`
+	TotalPages int    `json:"totalPages"`
+}
+
+// FoodUsage is a food a user logged, with how many meals contained it and
+// when it was last eaten
+type FoodUsage struct {
+	Food     Food      `json:"food"`
+	Uses     int       `json:"uses"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// FoodQuery records when a normalized search query was last sent to the
+// food provider, so that repeated searches are answered locally
+type FoodQuery struct {
+	Query     string `gorm:"primaryKey"`
+	FetchedAt time.Time
+}
+
+// FDCImport records the progress of the import of an FDC dataset download,
+// so that an interrupted import resumes where it stopped
+type FDCImport struct {
+	// Source is the absolute path of the download
+	Source string `gorm:"primaryKey"`
+	// Size is the size of the download when imported, another one means a
+	// new download which is imported from the start
+	Size int64
+	// Foods is the number of foods of the download imported so far
+	Foods       int
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt *time.Time
 }
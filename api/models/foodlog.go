@@ -0,0 +1,26 @@
+package models
+
+// FoodLogImport reports the import of a food log exported by another
+// tracker, or what it would import for a dry run
+type FoodLogImport struct {
+	Format string `json:"format"`
+	DryRun bool   `json:"dryRun"`
+	// Entries is the number of foods logged, Meals and CustomFoods the number
+	// of meals and custom foods created for them
+	Entries     int `json:"entries"`
+	Meals       int `json:"meals"`
+	CustomFoods int `json:"customFoods"`
+	// Unmatched are the lines whose food matched no stored food, logged with
+	// a custom food
+	Unmatched []FoodLogLine `json:"unmatched"`
+	// Skipped are the lines which were not imported
+	Skipped []FoodLogLine `json:"skipped"`
+}
+
+// FoodLogLine is a line of an imported food log, with the reason it was
+// skipped if it was
+type FoodLogLine struct {
+	Line   int    `json:"line"`
+	Food   string `json:"food"`
+	Reason string `json:"reason,omitempty"`
+}
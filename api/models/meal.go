@@ -15,11 +15,35 @@ const (
 	Dinner    MealType = "dinner"
 )
 
+// MealTypes are the meal types in the order of the day
+var MealTypes = []MealType{Breakfast, Lunch, Break, Dinner}
+
 type Meal struct {
 	gorm.Model
-	Type   MealType  `json:"type" gorm:"column:meal_type;type:varchar(20)"`
+	Type   MealType  `json:"type" gorm:"column:meal_type;type:varchar(20)" binding:"required,oneof=breakfast lunch break dinner"`
 	Date   time.Time `json:"date" gorm:"index"`
-	UserID uint      `json:"userId" gorm:"column:user_id;index"`
-	User   User      `json:"-" gorm:"foreignKey:UserID;references:ID"`
-	Foods  []Food    `json:"foods" gorm:"many2many:meal_foods;"`
+	UserID uint      `json:"userId" gorm:"column:user_id;index" binding:"required"`
+	User   User      `json:"-" gorm:"foreignKey:UserID;references:ID" binding:"-"`
+	Foods  []Food    `json:"foods" gorm:"many2many:meal_foods;" binding:"-"`
+}
+
+// MealFood is a food eaten in a meal, in the meal_foods join table
+type MealFood struct {
+	MealID uint `gorm:"primaryKey"`
+	FoodID uint `gorm:"primaryKey"`
+	// Grams defaults to 100, the quantity the nutrients are given for
+	Grams float64 `gorm:"default:100"`
+	// Measure is the household measure the food was logged in, e.g.
+	// "2 x 1 cup, chopped", empty when it was logged in grams
+	Measure string
+}
+
+// AddFoodRequest is the body expected to add a food to a meal. The quantity
+// is given either in grams or as a number of servings of one of the food's
+// portions; 100 g when neither is.
+type AddFoodRequest struct {
+	FoodID    string   `json:"foodId" binding:"required"`
+	Grams     *float64 `json:"grams" binding:"omitempty,gt=0,lte=10000"`
+	PortionID *uint    `json:"portionId"`
+	Servings  *float64 `json:"servings" binding:"omitempty,gt=0,lte=100"`
 }
@@ -0,0 +1,32 @@
+package models
+
+// Nutrients are the amounts of the tracked nutrients in a quantity of food,
+// a meal or a day: kcal for calories, grams for the others
+type Nutrients struct {
+	Calories float64 `json:"calories"`
+	Protein  float64 `json:"protein"`
+	Carbs    float64 `json:"carbs"`
+	Fat      float64 `json:"fat"`
+	Fiber    float64 `json:"fiber"`
+}
+
+// Add adds other to the amounts
+func (n *Nutrients) Add(other Nutrients) {
+	n.Calories += other.Calories
+	n.Protein += other.Protein
+	n.Carbs += other.Carbs
+	n.Fat += other.Fat
+	n.Fiber += other.Fiber
+}
+
+// Scale returns the amounts multiplied by factor, e.g. those of 150 g of a
+// food from its values per 100 g with 1.5
+func (n Nutrients) Scale(factor float64) Nutrients {
+	return Nutrients{
+		Calories: n.Calories * factor,
+		Protein:  n.Protein * factor,
+		Carbs:    n.Carbs * factor,
+		Fat:      n.Fat * factor,
+		Fiber:    n.Fiber * factor,
+	}
+}
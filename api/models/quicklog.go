@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// QuickLogRequest is the body expected to log a meal from a sentence, e.g.
+// "150g chicken breast and 1 cup rice for lunch yesterday"
+type QuickLogRequest struct {
+	Text string `json:"text" binding:"required,max=500"`
+	// Now is the user's local time, which "today", "yesterday" and the meal
+	// guessed from the time of day are relative to: the server's time when
+	// it is missing
+	Now *time.Time `json:"now"`
+}
+
+// QuickLog is how a sentence was read and the foods it logged, or would log
+// for a dry run
+type QuickLog struct {
+	Text     string    `json:"text"`
+	Date     time.Time `json:"date"`
+	MealType MealType  `json:"mealType"`
+	// MealGuessed tells the sentence named no meal: its type comes from the
+	// time of day
+	MealGuessed bool `json:"mealGuessed"`
+	DryRun      bool `json:"dryRun"`
+	// MealID is the meal the foods were logged in, 0 for a dry run
+	MealID uint `json:"mealId"`
+	// Logged is the number of entries logged, or that would be
+	Logged  int             `json:"logged"`
+	Entries []QuickLogEntry `json:"entries"`
+	// Totals are the nutrients of the entries logged
+	Totals Nutrients `json:"totals"`
+}
+
+// QuickLogEntry is a food named in a quick log sentence, e.g. "1 cup rice",
+// with the stored food it matches and the quantity it stands for
+type QuickLogEntry struct {
+	// Text is the part of the sentence naming the food
+	Text string `json:"text"`
+	// Quantity and Unit are as written, 1 when no quantity was; the unit is
+	// empty for a number of items, e.g. "2 eggs"
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	Food     string  `json:"food"`
+	// FdcID and Name are those of the matching food, empty when none does:
+	// the entry is then not logged
+	FdcID string  `json:"fdcId,omitempty"`
+	Name  string  `json:"name,omitempty"`
+	Grams float64 `json:"grams"`
+	// Measure is the household measure logged, as for a food added to a
+	// meal, e.g. "2 x 1 cup"
+	Measure   string    `json:"measure"`
+	Nutrients Nutrients `json:"nutrients"`
+	// Note tells why the entry is not logged, or how its weight was
+	// estimated when the food has no measure of its unit
+	Note string `json:"note,omitempty"`
+}
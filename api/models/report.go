@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Report periods
+const (
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+)
+
+// NutritionReport sums up a user's intake over a week or a month
+type NutritionReport struct {
+	Period string `json:"period"`
+	// Label names the period, e.g. "2024-W10" or "2024-03"
+	Label string    `json:"label"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	// Days are the days of the period up to today
+	Days []ReportDay `json:"days"`
+	// LoggedDays is the number of days with at least a meal, and
+	// Completeness its percentage of Days
+	LoggedDays   int       `json:"loggedDays"`
+	Completeness float64   `json:"completeness"`
+	Meals        int       `json:"meals"`
+	Entries      int       `json:"entries"`
+	Totals       Nutrients `json:"totals"`
+	// Averages are the daily averages over the logged days
+	Averages   Nutrients  `json:"averages"`
+	MacroSplit MacroSplit `json:"macroSplit"`
+	// Targets are the user's daily targets, nil when they have none; the
+	// adherence and the best and worst days are only computed against them
+	Targets *Nutrients `json:"targets"`
+	// Tolerance is the percentage of a target a day may miss it by and
+	// still adhere to it
+	Tolerance float64          `json:"tolerance"`
+	Adherence *ReportAdherence `json:"adherence"`
+	BestDay   *ReportDay       `json:"bestDay"`
+	WorstDay  *ReportDay       `json:"worstDay"`
+	Weight    *WeightChange    `json:"weight"`
+}
+
+// ReportDay is the intake of a day of a report
+type ReportDay struct {
+	Date    time.Time `json:"date"`
+	Meals   int       `json:"meals"`
+	Entries int       `json:"entries"`
+	Nutrients
+	// Deviation is the average percentage by which the day missed the
+	// targets, when the user has some
+	Deviation *float64 `json:"deviation,omitempty"`
+}
+
+// ReportAdherence counts the logged days within the tolerance of each
+// target, and of all of them
+type ReportAdherence struct {
+	Calories int `json:"calories"`
+	Protein  int `json:"protein"`
+	Carbs    int `json:"carbs"`
+	Fat      int `json:"fat"`
+	Fiber    int `json:"fiber"`
+	All      int `json:"all"`
+}
+
+// MacroSplit is the percentage of the calories of the protein, carbs and
+// fat eaten
+type MacroSplit struct {
+	Protein float64 `json:"protein"`
+	Carbs   float64 `json:"carbs"`
+	Fat     float64 `json:"fat"`
+}
+
+// WeightChange is the change of weight over a period, between its first and
+// last weight records
+type WeightChange struct {
+	Start     float64   `json:"start"`
+	End       float64   `json:"end"`
+	Change    float64   `json:"change"`
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+	Records   int       `json:"records"`
+}
@@ -8,30 +8,51 @@ import (
 
 type User struct {
 	gorm.Model
-	FirstName string  `json:"firstName"`
-	LastName  string  `json:"lastName"`
-	Age       int     `json:"age"`
-	Weight    float64 `json:"weight"`
-	Height    int     `json:"height"`
-	Goal      string  `json:"goal"`
-	Sex       int     `json:"sex"`
-	Targets   Target  `json:"targets" gorm:"foreignKey:UserID"`
+	FirstName string  `json:"firstName" binding:"required,max=100"`
+	LastName  string  `json:"lastName" binding:"required,max=100"`
+	Age       int     `json:"age" binding:"required,gte=1,lte=130"`
+	Weight    float64 `json:"weight" binding:"required,gt=0,lte=500"`
+	Height    int     `json:"height" binding:"required,gte=50,lte=300"`
+	Goal      string  `json:"goal" binding:"max=200"`
+	// Sex is 1 for male, 0 for female
+	Sex int `json:"sex" binding:"oneof=0 1"`
+	// ActivityLevel is the number of days of physical activity per week
+	ActivityLevel int    `json:"activityLevel" binding:"gte=0,lte=7"`
+	Targets       Target `json:"targets" gorm:"foreignKey:UserID" binding:"-"`
+}
+
+// UserStats holds the health indicators computed from a user's profile
+type UserStats struct {
+	Height int     `json:"height"`
+	Weight float64 `json:"weight"`
+	BMI    float64 `json:"bmi"`
+	BFP    float64 `json:"bfp"`
+	IMG    float64 `json:"img"`
+	BMR    float64 `json:"bmr"`
 }
 
 type Target struct {
 	gorm.Model
 	UserID   uint    `json:"userId" gorm:"uniqueIndex"`
-	Calories float64 `json:"calories"`
-	Protein  float64 `json:"protein"`
-	Carbs    float64 `json:"carbs"`
-	Fat      float64 `json:"fat"`
-	Fiber    float64 `json:"fiber"`
+	Calories float64 `json:"calories" binding:"gte=0,lte=20000"`
+	Protein  float64 `json:"protein" binding:"gte=0,lte=2000"`
+	Carbs    float64 `json:"carbs" binding:"gte=0,lte=2000"`
+	Fat      float64 `json:"fat" binding:"gte=0,lte=2000"`
+	Fiber    float64 `json:"fiber" binding:"gte=0,lte=2000"`
 }
 
 type WeightRecord struct {
 	gorm.Model
 	UserID uint      `json:"userId" gorm:"index"`
-	Weight float64   `json:"weight"`
+	Weight float64   `json:"weight" binding:"required,gt=0,lte=500"`
 	Date   time.Time `json:"date"`
-	Note   string    `json:"note,omitempty"`
+	Note   string    `json:"note,omitempty" binding:"max=500"`
+}
+
+// UserDeletion is the body returned when deleting a user: their data is kept
+// until RestoreUntil, when it may be purged
+type UserDeletion struct {
+	UserID       uint      `json:"userId"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	RestoreUntil time.Time `json:"restoreUntil"`
 }
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FavoriteFood is a food starred by a user
+type FavoriteFood struct {
+	UserID    uint `gorm:"primaryKey"`
+	FoodID    uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// FoodOverride replaces a shared food's values for one user, e.g. for their
+// brand of a product. Nil fields keep the shared values.
+type FoodOverride struct {
+	gorm.Model
+	UserID      uint     `json:"userId" gorm:"uniqueIndex:idx_food_overrides_user_food"`
+	FoodID      uint     `json:"foodId" gorm:"uniqueIndex:idx_food_overrides_user_food"`
+	Food        Food     `json:"food" gorm:"foreignKey:FoodID" binding:"-"`
+	Name        *string  `json:"name" binding:"omitempty,min=1,max=200"`
+	Protein     *float64 `json:"protein" binding:"omitempty,gte=0,lte=1000"`
+	Carbs       *float64 `json:"carbs" binding:"omitempty,gte=0,lte=1000"`
+	Fat         *float64 `json:"fat" binding:"omitempty,gte=0,lte=1000"`
+	Calories    *float64 `json:"calories" binding:"omitempty,gte=0,lte=10000"`
+	Fiber       *float64 `json:"fiber" binding:"omitempty,gte=0,lte=1000"`
+	ServingSize *float64 `json:"servingSize" binding:"omitempty,gt=0,lte=10000"`
+}
+
+// Apply replaces the values of food with the overridden ones
+func (o FoodOverride) Apply(food *Food) {
+	if o.Name != nil {
+		food.Name = *o.Name
+	}
+	for _, field := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{o.Protein, &food.Protein},
+		{o.Carbs, &food.Carbs},
+		{o.Fat, &food.Fat},
+		{o.Calories, &food.Calories},
+		{o.Fiber, &food.Fiber},
+		{o.ServingSize, &food.ServingSize},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+	food.Overridden = true
+}
@@ -0,0 +1,9 @@
+// Package openapi holds the OpenAPI 3 description of the API, served at
+// /openapi.json. It is maintained by hand alongside the routes and models and
+// checked against them by the contract test of internal/contract.
+package openapi
+
+import _ "embed"
+
+//go:embed openapi.json
+var Spec []byte
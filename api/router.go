@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/handlers"
+	"github.com/ZUHOWKS/my-body-tracker/api/middleware"
+	"github.com/ZUHOWKS/my-body-tracker/api/openapi"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// NewRouter wires the API's routes to their handlers. provider is the food
+// database searched when the local one lacks results.
+func NewRouter(db *gorm.DB, provider services.FoodProvider) *gin.Engine {
+	// Initialize handlers
+	userHandler := handlers.NewUserHandler(db)
+	foodHandler := handlers.NewFoodHandler(db, provider)
+	mealHandler := handlers.NewMealHandler(db)
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), gin.Logger(), handlers.Recovery())
+	r.NoRoute(handlers.NoRoute)
+
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openapi.Spec)
+	})
+
+	userRoutes := r.Group("/users")
+	{
+		userRoutes.GET("/", userHandler.ListUsers)
+		userRoutes.POST("/", userHandler.CreateUser)
+		userRoutes.POST("/import", userHandler.ImportUser)
+		userRoutes.GET("/:id", userHandler.GetUser)
+		userRoutes.GET("/:id/stats", userHandler.GetUserStats)
+		userRoutes.PUT("/:id", userHandler.UpdateUser)
+		userRoutes.DELETE("/:id", userHandler.DeleteUser)
+		userRoutes.POST("/:id/restore", userHandler.RestoreUser)
+		userRoutes.GET("/:id/targets", userHandler.GetUserTargets)
+		userRoutes.POST("/:id/targets", userHandler.SetUserTargets)
+		userRoutes.POST("/:id/weight", userHandler.RecordWeight)
+		userRoutes.GET("/:id/weight/history", userHandler.GetWeightHistory)
+		userRoutes.GET("/:id/foods/recent", userHandler.GetRecentFoods)
+		userRoutes.GET("/:id/foods/frequent", userHandler.GetFrequentFoods)
+		userRoutes.GET("/:id/foods/favorites", userHandler.ListFavoriteFoods)
+		userRoutes.PUT("/:id/foods/favorites/:fdcId", userHandler.AddFavoriteFood)
+		userRoutes.DELETE("/:id/foods/favorites/:fdcId", userHandler.RemoveFavoriteFood)
+		userRoutes.GET("/:id/foods/overrides", userHandler.ListFoodOverrides)
+		userRoutes.PUT("/:id/foods/overrides/:fdcId", userHandler.SetFoodOverride)
+		userRoutes.DELETE("/:id/foods/overrides/:fdcId", userHandler.DeleteFoodOverride)
+		userRoutes.POST("/:id/import", userHandler.ImportFoodLog)
+		userRoutes.POST("/:id/quicklog", userHandler.QuickLog)
+		userRoutes.GET("/:id/export", userHandler.ExportUser)
+		userRoutes.GET("/:id/export/meals", userHandler.ExportMealsCSV)
+		userRoutes.GET("/:id/export/daily", userHandler.ExportDailyCSV)
+		userRoutes.GET("/:id/export/weight", userHandler.ExportWeightCSV)
+		userRoutes.GET("/:id/reports/weekly", userHandler.WeeklyReport)
+		userRoutes.GET("/:id/reports/monthly", userHandler.MonthlyReport)
+		userRoutes.GET("/:id/reports/print", userHandler.PrintableReport)
+	}
+
+	foodRoutes := r.Group("/foods")
+	{
+		foodRoutes.GET("/search", foodHandler.SearchFood)
+		foodRoutes.GET("/:fdcId", foodHandler.GetFood)
+	}
+
+	mealRoutes := r.Group("/meals")
+	{
+		mealRoutes.POST("/", mealHandler.CreateMeal)
+		mealRoutes.GET("/user/:userId", mealHandler.GetUserMeals)
+		mealRoutes.POST("/:id/foods", mealHandler.AddFoodToMeal)
+	}
+
+	return r
+}
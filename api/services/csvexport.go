@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+)
+
+// The CSV exports share their conventions: a header row of camelCase
+// columns, days formatted as YYYY-MM-DD, amounts in kcal, grams or kg
+// rounded to 2 decimals, and empty cells for missing values.
+var (
+	// MealsCSVColumns are the columns of the meals CSV, a row per food eaten
+	MealsCSVColumns = []string{"date", "mealType", "fdcId", "food", "grams", "measure", "calories", "protein", "carbs", "fat", "fiber"}
+	// DailyCSVColumns are the columns of the daily totals CSV, a row per day
+	// with the nutrients eaten, the target and the percentage of it reached
+	DailyCSVColumns = []string{"date", "meals", "entries",
+		"calories", "caloriesTarget", "caloriesPercent",
+		"protein", "proteinTarget", "proteinPercent",
+		"carbs", "carbsTarget", "carbsPercent",
+		"fat", "fatTarget", "fatPercent",
+		"fiber", "fiberTarget", "fiberPercent"}
+	// WeightCSVColumns are the columns of the weight CSV, a row per record
+	// with the change since the previous one
+	WeightCSVColumns = []string{"date", "weight", "change", "note"}
+)
+
+const csvDate = "2006-01-02"
+
+// csvNumber formats an amount of a CSV export
+func csvNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// WriteMealsCSV writes the entries as the meals CSV
+func WriteMealsCSV(w io.Writer, entries []MealEntry) error {
+	rows := [][]string{MealsCSVColumns}
+	for _, entry := range entries {
+		rows = append(rows, []string{
+			entry.Day().Format(csvDate), string(entry.MealType), entry.FdcID, entry.Food,
+			csvNumber(entry.Grams), entry.Measure,
+			csvNumber(entry.Calories), csvNumber(entry.Protein), csvNumber(entry.Carbs),
+			csvNumber(entry.Fat), csvNumber(entry.Fiber),
+		})
+	}
+	return csv.NewWriter(w).WriteAll(rows)
+}
+
+// WriteDailyCSV writes the days as the daily totals CSV, against target when
+// the user has one
+func WriteDailyCSV(w io.Writer, days []DayTotals, target *models.Target) error {
+	rows := [][]string{DailyCSVColumns}
+	for _, day := range days {
+		row := []string{day.Date.Format(csvDate), strconv.Itoa(day.Meals), strconv.Itoa(day.Entries)}
+		for _, nutrient := range []struct {
+			eaten  float64
+			target func(models.Target) float64
+		}{
+			{day.Calories, func(t models.Target) float64 { return t.Calories }},
+			{day.Protein, func(t models.Target) float64 { return t.Protein }},
+			{day.Carbs, func(t models.Target) float64 { return t.Carbs }},
+			{day.Fat, func(t models.Target) float64 { return t.Fat }},
+			{day.Fiber, func(t models.Target) float64 { return t.Fiber }},
+		} {
+			goal, percent := "", ""
+			if target != nil {
+				value := nutrient.target(*target)
+				goal = csvNumber(value)
+				if value > 0 {
+					percent = csvNumber(nutrient.eaten / value * 100)
+				}
+			}
+			row = append(row, csvNumber(nutrient.eaten), goal, percent)
+		}
+		rows = append(rows, row)
+	}
+	return csv.NewWriter(w).WriteAll(rows)
+}
+
+// WriteWeightCSV writes the records, oldest first, as the weight CSV
+func WriteWeightCSV(w io.Writer, records []models.WeightRecord) error {
+	rows := [][]string{WeightCSVColumns}
+	for i, record := range records {
+		change := ""
+		if i > 0 {
+			change = csvNumber(record.Weight - records[i-1].Weight)
+		}
+		rows = append(rows, []string{truncateDay(record.Date).Format(csvDate), csvNumber(record.Weight), change, record.Note})
+	}
+	return csv.NewWriter(w).WriteAll(rows)
+}
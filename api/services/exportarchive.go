@@ -0,0 +1,149 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+)
+
+// UserExportFile is the file of a ZIP export holding the export as JSON, the
+// one an import reads. The CSV files next to it are for spreadsheets.
+const UserExportFile = "user.json"
+
+// MaxUserExportSize caps the size of an export read, decompressed
+const MaxUserExportSize = 50 << 20
+
+// ErrMalformedUserExport is returned when reading an export which is neither
+// JSON nor a ZIP archive holding UserExportFile
+var ErrMalformedUserExport = errors.New("malformed export")
+
+// zipMagic starts every ZIP archive
+var zipMagic = []byte("PK\x03\x04")
+
+// isZipArchive tells whether data is a ZIP archive
+func isZipArchive(data []byte) bool {
+	return bytes.HasPrefix(data, zipMagic)
+}
+
+// WriteUserExportZip writes the export as a ZIP archive of UserExportFile
+// and of CSV files of its meals, weight records and foods
+func WriteUserExportZip(w io.Writer, export *models.UserExport) error {
+	archive := zip.NewWriter(w)
+
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: UserExportFile, Method: zip.Deflate, Modified: export.ExportedAt})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+
+	// The meals and weight records use the columns of the CSV exports, the
+	// meals with the values the user overrode and in the order of MealEntries
+	foods := make(map[string]models.Food, len(export.Foods))
+	for _, exported := range export.Foods {
+		foods[exported.FdcID] = models.Food{Name: exported.Name, Calories: exported.Calories, Protein: exported.Protein,
+			Carbs: exported.Carbs, Fat: exported.Fat, Fiber: exported.Fiber}
+	}
+	for _, override := range export.Overrides {
+		food, ok := foods[override.FdcID]
+		if !ok {
+			continue
+		}
+		models.FoodOverride{Name: override.Name, Protein: override.Protein, Carbs: override.Carbs, Fat: override.Fat,
+			Calories: override.Calories, Fiber: override.Fiber, ServingSize: override.ServingSize}.Apply(&food)
+		foods[override.FdcID] = food
+	}
+	var entries []MealEntry
+	for _, meal := range export.Meals {
+		first := len(entries)
+		for _, eaten := range meal.Foods {
+			food := foods[eaten.FdcID]
+			per100g := models.Nutrients{Calories: food.Calories, Protein: food.Protein, Carbs: food.Carbs, Fat: food.Fat, Fiber: food.Fiber}
+			entries = append(entries, MealEntry{
+				MealType:  meal.Type,
+				Date:      meal.Date,
+				FdcID:     eaten.FdcID,
+				Food:      food.Name,
+				Grams:     eaten.Grams,
+				Measure:   eaten.Measure,
+				Nutrients: per100g.Scale(eaten.Grams / 100),
+			})
+		}
+		slices.SortStableFunc(entries[first:], func(a, b MealEntry) int {
+			return strings.Compare(a.Food, b.Food)
+		})
+	}
+	sortMealEntries(entries)
+
+	records := make([]models.WeightRecord, len(export.WeightRecords))
+	for i, record := range export.WeightRecords {
+		records[i] = models.WeightRecord{Weight: record.Weight, Date: record.Date, Note: record.Note}
+	}
+
+	foodRows := [][]string{{"fdcId", "name", "dataType", "brandOwner", "custom", "calories", "protein", "carbs", "fat", "fiber", "servingSize", "servingSizeUnit"}}
+	for _, food := range export.Foods {
+		foodRows = append(foodRows, []string{
+			food.FdcID, food.Name, food.DataType, food.BrandOwner, strconv.FormatBool(food.Custom),
+			csvNumber(food.Calories), csvNumber(food.Protein), csvNumber(food.Carbs), csvNumber(food.Fat), csvNumber(food.Fiber),
+			csvNumber(food.ServingSize), food.ServingSizeUnit,
+		})
+	}
+
+	for _, table := range []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"meals.csv", func(w io.Writer) error { return WriteMealsCSV(w, entries) }},
+		{"weight_records.csv", func(w io.Writer) error { return WriteWeightCSV(w, records) }},
+		{"foods.csv", func(w io.Writer) error { return csv.NewWriter(w).WriteAll(foodRows) }},
+	} {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: table.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return err
+		}
+		if err := table.write(file); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ReadUserExport decodes an export, JSON or a ZIP archive written by
+// WriteUserExportZip. Decoding errors wrap ErrMalformedUserExport.
+func ReadUserExport(data []byte) (*models.UserExport, error) {
+	if isZipArchive(data) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedUserExport, err)
+		}
+		file, err := archive.Open(UserExportFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: archive has no %s", ErrMalformedUserExport, UserExportFile)
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(io.LimitReader(file, MaxUserExportSize+1)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedUserExport, err)
+		}
+		if len(data) > MaxUserExportSize {
+			return nil, fmt.Errorf("%w: %s larger than %d MB", ErrMalformedUserExport, UserExportFile, MaxUserExportSize>>20)
+		}
+	}
+
+	var export models.UserExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedUserExport, err)
+	}
+	return &export, nil
+}
@@ -1,80 +1,269 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/ZUHOWKS/my-body-tracker/api/models"
 )
 
 type FDCNutrient struct {
 	NutrientName string  `json:"nutrientName"`
+	UnitName     string  `json:"unitName"`
 	Value        float64 `json:"value"`
 }
 
 type FDCFood struct {
-	FdcId         int           `json:"fdcId"`
-	Description   string        `json:"description"`
-	ServingSize   float64       `json:"servingSize"`
-	FoodNutrients []FDCNutrient `json:"foodNutrients"`
+	FdcId                    int           `json:"fdcId"`
+	Description              string        `json:"description"`
+	DataType                 string        `json:"dataType"`
+	BrandOwner               string        `json:"brandOwner"`
+	ServingSize              float64       `json:"servingSize"`
+	ServingSizeUnit          string        `json:"servingSizeUnit"`
+	HouseholdServingFullText string        `json:"householdServingFullText"`
+	FoodNutrients            []FDCNutrient `json:"foodNutrients"`
+}
+
+// FDCFoodDetail is a food as returned by FDC's food endpoint, whose nutrients
+// are nested differently from the search results
+type FDCFoodDetail struct {
+	FdcId                    int                 `json:"fdcId"`
+	Description              string              `json:"description"`
+	DataType                 string              `json:"dataType"`
+	BrandOwner               string              `json:"brandOwner"`
+	ServingSize              float64             `json:"servingSize"`
+	ServingSizeUnit          string              `json:"servingSizeUnit"`
+	HouseholdServingFullText string              `json:"householdServingFullText"`
+	FoodNutrients            []FDCDetailNutrient `json:"foodNutrients"`
+	FoodPortions             []FDCPortion        `json:"foodPortions"`
+}
+
+type FDCDetailNutrient struct {
+	Nutrient struct {
+		Name     string `json:"name"`
+		UnitName string `json:"unitName"`
+	} `json:"nutrient"`
+	Amount float64 `json:"amount"`
+}
+
+// FDCPortion is a household measure. Survey foods describe it in
+// PortionDescription, SR Legacy foods in Modifier, Foundation foods with
+// MeasureUnit and Modifier.
+type FDCPortion struct {
+	Amount             float64 `json:"amount"`
+	GramWeight         float64 `json:"gramWeight"`
+	Modifier           string  `json:"modifier"`
+	PortionDescription string  `json:"portionDescription"`
+	MeasureUnit        struct {
+		Name string `json:"name"`
+	} `json:"measureUnit"`
+	SequenceNumber int `json:"sequenceNumber"`
 }
 
 type FDCResponse struct {
-	Foods []FDCFood `json:"foods"`
+	TotalHits   int       `json:"totalHits"`
+	CurrentPage int       `json:"currentPage"`
+	TotalPages  int       `json:"totalPages"`
+	Foods       []FDCFood `json:"foods"`
 }
 
-// SearchFood searches FDC database for a given query and returns our Food model
-func SearchFood(query string, client http.Client) ([]models.Food, error) {
-	baseURL := "https://api.nal.usda.gov/fdc/v1/foods/search"
-	params := url.Values{}
-	params.Add("api_key", "lVglma2Dy1h69QzmRovFef2yOxqABWT0bldH8iLm")
-	params.Add("query", query)
-	params.Add("pageSize", "5") // Limit results to 5 items
+// FDCBaseURL is the address of the FoodData Central API
+const FDCBaseURL = "https://api.nal.usda.gov/fdc/v1"
 
-	fullURL := baseURL + "?" + params.Encode()
+// fdcMaxPageSize is the largest page FDC returns
+const fdcMaxPageSize = 200
 
-	resp, err := client.Get(fullURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch FDC data: %s", err.Error())
+// fdcSortFields maps the search orders to FDC's sortBy values
+var fdcSortFields = map[string]string{
+	FoodSortName:     "lowercaseDescription.keyword",
+	FoodSortDataType: "dataType.keyword",
+}
+
+// FDCProvider searches USDA's FoodData Central. Its client authenticates the
+// requests, see FDCKeys.Transport.
+type FDCProvider struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewFDCProvider(client *http.Client) *FDCProvider {
+	return &FDCProvider{client: client, baseURL: FDCBaseURL}
+}
+
+// SearchFoods searches FDC database for a given query and returns our Food model
+func (p *FDCProvider) SearchFoods(ctx context.Context, query FoodSearchQuery) (*FoodSearchResult, error) {
+	params := url.Values{}
+	params.Add("query", query.Query)
+	params.Add("pageNumber", strconv.Itoa(max(query.Page, 1)))
+	params.Add("pageSize", strconv.Itoa(min(max(query.PageSize, 1), fdcMaxPageSize)))
+	if len(query.DataTypes) > 0 {
+		params.Add("dataType", strings.Join(query.DataTypes, ","))
+	}
+	if query.BrandOwner != "" {
+		params.Add("brandOwner", query.BrandOwner)
+	}
+	if field, ok := fdcSortFields[query.Sort]; ok {
+		params.Add("sortBy", field)
+		if query.Descending {
+			params.Add("sortOrder", "desc")
+		} else {
+			params.Add("sortOrder", "asc")
+		}
 	}
-	defer resp.Body.Close()
 
 	var fdcResp FDCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&fdcResp); err != nil {
-		return nil, fmt.Errorf("failed to parse FDC response: %w", err)
+	if err := p.get(ctx, "/foods/search", params, &fdcResp); err != nil {
+		return nil, err
 	}
 
 	// Convert FDC foods to our Food models
 	foods := make([]models.Food, len(fdcResp.Foods))
 	for i, fdcFood := range fdcResp.Foods {
-		protein, carbs, fat, calories, fiber := 0.0, 0.0, 0.0, 0.0, 0.0
-		for _, nutrient := range fdcFood.FoodNutrients {
-			switch nutrient.NutrientName {
-			case "Protein":
-				protein = nutrient.Value
-			case "Carbohydrate, by difference":
-				carbs = nutrient.Value
-			case "Total lipid (fat)":
-				fat = nutrient.Value
-			case "Energy":
-				calories = nutrient.Value
-			case "Fiber, total dietary":
-				fiber = nutrient.Value
-			}
+		foods[i] = fdcFood.toFood()
+	}
+
+	return &FoodSearchResult{Foods: foods, TotalHits: fdcResp.TotalHits, TotalPages: fdcResp.TotalPages}, nil
+}
+
+// FoodDetail fetches a food from FDC with its household measures
+func (p *FDCProvider) FoodDetail(ctx context.Context, fdcID string) (*models.Food, error) {
+	var detail FDCFoodDetail
+	if err := p.get(ctx, "/food/"+url.PathEscape(fdcID), url.Values{}, &detail); err != nil {
+		return nil, err
+	}
+
+	food := detail.toFood()
+	return &food, nil
+}
+
+// get sends a GET request to FDC and decodes its JSON answer into out
+func (p *FDCProvider) get(ctx context.Context, path string, params url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch FDC data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrFoodNotFound
+	default:
+		// The body is an error page, not a result
+		return &UpstreamError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse FDC response: %w", err)
+	}
+	return nil
+}
+
+func (f FDCFood) toFood() models.Food {
+	food := models.Food{
+		FdcID:            fmt.Sprintf("%d", f.FdcId), // Convert int to string
+		Name:             f.Description,
+		DataType:         f.DataType,
+		BrandOwner:       f.BrandOwner,
+		ServingSize:      f.ServingSize,
+		ServingSizeUnit:  f.ServingSizeUnit,
+		HouseholdServing: f.HouseholdServingFullText,
+	}
+	for _, nutrient := range f.FoodNutrients {
+		setNutrient(&food, nutrient.NutrientName, nutrient.UnitName, nutrient.Value)
+	}
+	return food
+}
+
+func (f FDCFoodDetail) toFood() models.Food {
+	food := FDCFood{
+		FdcId:                    f.FdcId,
+		Description:              f.Description,
+		DataType:                 f.DataType,
+		BrandOwner:               f.BrandOwner,
+		ServingSize:              f.ServingSize,
+		ServingSizeUnit:          f.ServingSizeUnit,
+		HouseholdServingFullText: f.HouseholdServingFullText,
+	}.toFood()
+	for _, nutrient := range f.FoodNutrients {
+		setNutrient(&food, nutrient.Nutrient.Name, nutrient.Nutrient.UnitName, nutrient.Amount)
+	}
+
+	sort.SliceStable(f.FoodPortions, func(i, j int) bool {
+		return f.FoodPortions[i].SequenceNumber < f.FoodPortions[j].SequenceNumber
+	})
+	food.Portions = []models.FoodPortion{}
+	for _, portion := range f.FoodPortions {
+		if description := portion.description(); description != "" && portion.GramWeight > 0 {
+			food.Portions = append(food.Portions, models.FoodPortion{Description: description, GramWeight: portion.GramWeight})
 		}
-		foods[i] = models.Food{
-			FdcID:       fmt.Sprintf("%d", fdcFood.FdcId), // Convert int to string
-			Name:        fdcFood.Description,
-			Protein:     protein,
-			Carbs:       carbs,
-			Fat:         fat,
-			Calories:    calories,
-			Fiber:       fiber,
-			ServingSize: fdcFood.ServingSize,
+	}
+	// Branded foods have no portions but a household serving, usable as one
+	// when the serving size is a weight
+	if len(food.Portions) == 0 && food.HouseholdServing != "" && food.ServingSize > 0 && isGrams(food.ServingSizeUnit) {
+		food.Portions = append(food.Portions, models.FoodPortion{Description: food.HouseholdServing, GramWeight: food.ServingSize})
+	}
+	return food
+}
+
+// description returns the portion's household measure, e.g. "1 cup, chopped"
+func (p FDCPortion) description() string {
+	if p.PortionDescription != "" {
+		// Survey foods give a numeric code as modifier
+		if p.PortionDescription == "Quantity not specified" {
+			return ""
+		}
+		return p.PortionDescription
+	}
+
+	var parts []string
+	if unit := p.MeasureUnit.Name; unit != "" && unit != "undetermined" {
+		parts = append(parts, unit)
+	}
+	if p.Modifier != "" {
+		parts = append(parts, p.Modifier)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(max(p.Amount, 1), 'f', -1, 64) + " " + strings.Join(parts, ", ")
+}
+
+// setNutrient sets the food's nutrient named by FDC to value, ignoring the
+// nutrients we don't track and the energy given in kJ
+func setNutrient(food *models.Food, name, unit string, value float64) {
+	switch name {
+	case "Protein":
+		food.Protein = value
+	case "Carbohydrate, by difference":
+		food.Carbs = value
+	case "Total lipid (fat)":
+		food.Fat = value
+	case "Energy":
+		if unit == "" || strings.EqualFold(unit, "kcal") {
+			food.Calories = value
+		}
+	case "Energy (Atwater General Factors)":
+		// Foundation foods may only give this one
+		if food.Calories == 0 && strings.EqualFold(unit, "kcal") {
+			food.Calories = value
 		}
+	case "Fiber, total dietary":
+		food.Fiber = value
 	}
+}
 
-	return foods, nil
+// isGrams tells whether an FDC serving size unit is the gram
+func isGrams(unit string) bool {
+	return strings.EqualFold(unit, "g") || strings.EqualFold(unit, "grm")
 }
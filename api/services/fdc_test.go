@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+)
+
+// fdcFixtures are the FDC responses recorded for the contract test
+const fdcFixtures = "../../internal/contract/fixtures"
+
+// newFDCTestProvider returns a provider talking to a server which answers
+// with the recorded responses: the search for rice, and the foods by ID. The
+// food 503 gets an error page with that status and the food 999 a body which
+// is not JSON. The parameters of the last search are stored in searched.
+func newFDCTestProvider(t *testing.T, searched *url.Values) *FDCProvider {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "fdc_search_rice.json"
+		if id, ok := strings.CutPrefix(r.URL.Path, "/food/"); ok {
+			switch id {
+			case "503":
+				http.Error(w, "<html>Service Unavailable</html>", http.StatusServiceUnavailable)
+				return
+			case "999":
+				w.Write([]byte("<html>maintenance</html>"))
+				return
+			}
+			name = "fdc_food_" + id + ".json"
+		} else if searched != nil {
+			*searched = r.URL.Query()
+		}
+		data, err := os.ReadFile(filepath.Join(fdcFixtures, name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewFDCProvider(server.Client())
+	provider.baseURL = server.URL
+	return provider
+}
+
+func TestFDCFoodDetail(t *testing.T) {
+	provider := newFDCTestProvider(t, nil)
+	tests := []struct {
+		fdcID    string
+		want     models.Food
+		portions []models.FoodPortion
+	}{
+		{
+			// Foundation foods describe their portions with a unit and a
+			// modifier, and may only give their energy as Atwater factors
+			fdcID: "790646",
+			want:  models.Food{Name: "Onions, yellow, raw", DataType: "Foundation", Protein: 0.83, Carbs: 8.61, Fat: 0.05, Calories: 38, Fiber: 1.2},
+			portions: []models.FoodPortion{
+				{Description: "1 cup, chopped", GramWeight: 160},
+				{Description: "1 medium", GramWeight: 110},
+				{Description: "1 tbsp", GramWeight: 10},
+			},
+		},
+		{
+			// SR Legacy foods describe their portions with a modifier, and give
+			// their energy in kcal and kJ
+			fdcID: "169756",
+			want:  models.Food{Name: "Rice, white, long-grain, regular, raw, enriched", DataType: "SR Legacy", Protein: 7.13, Carbs: 79.95, Fat: 0.66, Calories: 365, Fiber: 1.3},
+			portions: []models.FoodPortion{
+				{Description: "1 cup", GramWeight: 185},
+				{Description: "1 tbsp", GramWeight: 11.6},
+			},
+		},
+		{
+			// Survey foods describe their portions in full, but for the
+			// default one
+			fdcID: "2512381",
+			want:  models.Food{Name: "Rice, white, long-grain, regular, cooked, enriched, with salt", DataType: "Survey (FNDDS)", Protein: 2.69, Carbs: 28.2, Fat: 0.28, Calories: 130, Fiber: 0.4},
+			portions: []models.FoodPortion{
+				{Description: "1 cup", GramWeight: 158},
+				{Description: "1 cup, packed", GramWeight: 186},
+			},
+		},
+		{
+			// Branded foods have a household serving instead of portions
+			fdcID: "2047249",
+			want: models.Food{Name: "BROWN RICE", DataType: "Branded", BrandOwner: "Example Foods Inc.", Protein: 8.89, Carbs: 77.8, Fat: 2.22, Calories: 356, Fiber: 4.4,
+				ServingSize: 45, ServingSizeUnit: "g", HouseholdServing: "1/4 cup"},
+			portions: []models.FoodPortion{{Description: "1/4 cup", GramWeight: 45}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fdcID, func(t *testing.T) {
+			food, err := provider.FoodDetail(context.Background(), test.fdcID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			portions := food.Portions
+			food.Portions = nil
+			test.want.FdcID = test.fdcID
+			if !reflect.DeepEqual(*food, test.want) {
+				t.Errorf("food = %+v, want %+v", *food, test.want)
+			}
+			if !slices.Equal(portions, test.portions) {
+				t.Errorf("portions = %+v, want %+v", portions, test.portions)
+			}
+		})
+	}
+}
+
+func TestFDCFoodDetailErrors(t *testing.T) {
+	provider := newFDCTestProvider(t, nil)
+
+	if _, err := provider.FoodDetail(context.Background(), "1"); !errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("unknown food: error = %v, want ErrFoodNotFound", err)
+	}
+
+	_, err := provider.FoodDetail(context.Background(), "503")
+	var upstream *UpstreamError
+	if !errors.As(err, &upstream) || upstream.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("error page: error = %v, want an UpstreamError of status 503", err)
+	}
+
+	_, err = provider.FoodDetail(context.Background(), "999")
+	if err == nil || errors.As(err, &upstream) || errors.Is(err, ErrFoodNotFound) {
+		t.Errorf("body which is not JSON: error = %v, want a parse error", err)
+	}
+}
+
+func TestFDCSearchFoods(t *testing.T) {
+	var searched url.Values
+	provider := newFDCTestProvider(t, &searched)
+
+	result, err := provider.SearchFoods(context.Background(), FoodSearchQuery{
+		Query: "rice", Page: 2, PageSize: 500, DataTypes: []string{"Branded", "Survey (FNDDS)"},
+		BrandOwner: "Example Foods Inc.", Sort: FoodSortName, Descending: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := url.Values{
+		"query": {"rice"}, "pageNumber": {"2"}, "pageSize": {"200"}, "dataType": {"Branded,Survey (FNDDS)"},
+		"brandOwner": {"Example Foods Inc."}, "sortBy": {"lowercaseDescription.keyword"}, "sortOrder": {"desc"},
+	}
+	for name := range want {
+		if searched.Get(name) != want.Get(name) {
+			t.Errorf("%s = %q, want %q", name, searched.Get(name), want.Get(name))
+		}
+	}
+
+	if result.TotalHits != 3 || result.TotalPages != 2 || len(result.Foods) != 2 {
+		t.Fatalf("got %d foods of %d in %d pages, want 2 of 3 in 2", len(result.Foods), result.TotalHits, result.TotalPages)
+	}
+	brown := models.Food{FdcID: "2047249", Name: "BROWN RICE", DataType: "Branded", BrandOwner: "Example Foods Inc.",
+		Protein: 8.89, Carbs: 77.8, Fat: 2.22, Calories: 356, Fiber: 4.4, ServingSize: 45, ServingSizeUnit: "g"}
+	if !reflect.DeepEqual(result.Foods[1], brown) {
+		t.Errorf("food = %+v, want %+v", result.Foods[1], brown)
+	}
+}
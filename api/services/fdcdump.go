@@ -0,0 +1,398 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// fdcDump reads the foods of an FDC dataset download one at a time, always
+// in the same order
+type fdcDump interface {
+	// Next returns the next food, or io.EOF after the last one
+	Next() (*FDCFoodDetail, error)
+	Close() error
+}
+
+// openFDCDump opens a JSON download, or an unzipped CSV download given by
+// its directory or its food.csv
+func openFDCDump(path string) (fdcDump, error) {
+	dir, err := csvDumpDir(path)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		return openCSVDump(dir)
+	}
+	return openJSONDump(path)
+}
+
+// csvDumpDir returns the directory of a CSV download, empty for a JSON one
+func csvDumpDir(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case info.IsDir():
+		return path, nil
+	case filepath.Ext(path) == ".csv":
+		return filepath.Dir(path), nil
+	}
+	return "", nil
+}
+
+// fdcDumpSize is the size of the files of a download, which tells whether
+// it changed since an earlier import
+func fdcDumpSize(path string) (int64, error) {
+	dir, err := csvDumpDir(path)
+	if err != nil {
+		return 0, err
+	}
+	files := []string{path}
+	if dir != "" {
+		if files, err = filepath.Glob(filepath.Join(dir, "*.csv")); err != nil {
+			return 0, err
+		}
+	}
+
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// jsonDump streams a JSON download, e.g. {"FoundationFoods": [...]}, without
+// loading the whole file: the Branded one weighs several gigabytes
+type jsonDump struct {
+	file *os.File
+	dec  *json.Decoder
+}
+
+func openJSONDump(path string) (*jsonDump, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	dump := &jsonDump{file: file, dec: json.NewDecoder(bufio.NewReaderSize(file, 1<<20))}
+	if err := dump.openArray(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s is not an FDC JSON download: %w", path, err)
+	}
+	return dump, nil
+}
+
+// openArray moves to the first food of the array of foods, the only value of
+// the download's object
+func (d *jsonDump) openArray() error {
+	token, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == json.Delim('{') {
+		if _, err := d.dec.Token(); err != nil {
+			return err
+		}
+		if token, err = d.dec.Token(); err != nil {
+			return err
+		}
+	}
+	if token != json.Delim('[') {
+		return errors.New("no array of foods")
+	}
+	return nil
+}
+
+func (d *jsonDump) Next() (*FDCFoodDetail, error) {
+	if !d.dec.More() {
+		return nil, io.EOF
+	}
+	var food FDCFoodDetail
+	if err := d.dec.Decode(&food); err != nil {
+		return nil, err
+	}
+	return &food, nil
+}
+
+func (d *jsonDump) Close() error {
+	return d.file.Close()
+}
+
+// csvDataTypes maps the data types of a CSV download to those of the API,
+// the other ones (samples, acquisitions...) are not foods to log
+var csvDataTypes = map[string]string{
+	"foundation_food":   DataTypeFoundation,
+	"sr_legacy_food":    DataTypeSRLegacy,
+	"branded_food":      DataTypeBranded,
+	"survey_fndds_food": DataTypeSurvey,
+}
+
+// fdcNutrientNames are the nutrients setNutrient reads
+var fdcNutrientNames = []string{
+	"Protein",
+	"Carbohydrate, by difference",
+	"Total lipid (fat)",
+	"Energy",
+	"Energy (Atwater General Factors)",
+	"Fiber, total dietary",
+}
+
+// csvAmount is a nutrient of a food, by index in csvDump.nutrients
+type csvAmount struct {
+	nutrient int
+	amount   float64
+}
+
+type csvBrandedFood struct {
+	brandOwner       string
+	servingSize      float64
+	servingSizeUnit  string
+	householdServing string
+}
+
+// csvDump reads a CSV download, whose foods, nutrients and portions are in
+// separate tables. Those of the nutrients, portions and brands are loaded in
+// memory, keeping only what the API stores, and food.csv is streamed.
+type csvDump struct {
+	foods *csvTable
+
+	nutrients []FDCDetailNutrient
+	amounts   map[int][]csvAmount
+	portions  map[int][]FDCPortion
+	branded   map[int]csvBrandedFood
+}
+
+func openCSVDump(dir string) (*csvDump, error) {
+	dump := &csvDump{
+		amounts:  map[int][]csvAmount{},
+		portions: map[int][]FDCPortion{},
+		branded:  map[int]csvBrandedFood{},
+	}
+	if err := dump.load(dir); err != nil {
+		return nil, err
+	}
+
+	foods, err := openCSVTable(filepath.Join(dir, "food.csv"))
+	if err != nil {
+		return nil, err
+	}
+	dump.foods = foods
+	return dump, nil
+}
+
+func (d *csvDump) load(dir string) error {
+	// nutrient.csv: id, name, unit_name
+	nutrients := map[int]int{}
+	err := readCSVTable(filepath.Join(dir, "nutrient.csv"), func(row csvRow) error {
+		name := row.get("name")
+		if !slices.Contains(fdcNutrientNames, name) {
+			return nil
+		}
+		var nutrient FDCDetailNutrient
+		nutrient.Nutrient.Name = name
+		nutrient.Nutrient.UnitName = row.get("unit_name")
+		nutrients[row.int("id")] = len(d.nutrients)
+		d.nutrients = append(d.nutrients, nutrient)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// food_nutrient.csv: fdc_id, nutrient_id, amount
+	err = readCSVTable(filepath.Join(dir, "food_nutrient.csv"), func(row csvRow) error {
+		if nutrient, ok := nutrients[row.int("nutrient_id")]; ok {
+			fdcID := row.int("fdc_id")
+			d.amounts[fdcID] = append(d.amounts[fdcID], csvAmount{nutrient: nutrient, amount: row.float("amount")})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// measure_unit.csv: id, name
+	units := map[int]string{}
+	err = readCSVTable(filepath.Join(dir, "measure_unit.csv"), func(row csvRow) error {
+		units[row.int("id")] = row.get("name")
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// food_portion.csv: fdc_id, seq_num, amount, measure_unit_id,
+	// portion_description, modifier, gram_weight
+	err = readCSVTable(filepath.Join(dir, "food_portion.csv"), func(row csvRow) error {
+		portion := FDCPortion{
+			Amount:             row.float("amount"),
+			GramWeight:         row.float("gram_weight"),
+			Modifier:           row.get("modifier"),
+			PortionDescription: row.get("portion_description"),
+			SequenceNumber:     row.int("seq_num"),
+		}
+		portion.MeasureUnit.Name = units[row.int("measure_unit_id")]
+		fdcID := row.int("fdc_id")
+		d.portions[fdcID] = append(d.portions[fdcID], portion)
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// branded_food.csv: fdc_id, brand_owner, serving_size, serving_size_unit,
+	// household_serving_fulltext
+	err = readCSVTable(filepath.Join(dir, "branded_food.csv"), func(row csvRow) error {
+		d.branded[row.int("fdc_id")] = csvBrandedFood{
+			brandOwner:       row.get("brand_owner"),
+			servingSize:      row.float("serving_size"),
+			servingSizeUnit:  row.get("serving_size_unit"),
+			householdServing: row.get("household_serving_fulltext"),
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Next returns the next food of food.csv: fdc_id, data_type, description
+func (d *csvDump) Next() (*FDCFoodDetail, error) {
+	for {
+		row, err := d.foods.next()
+		if err != nil {
+			return nil, err
+		}
+		dataType, ok := csvDataTypes[row.get("data_type")]
+		if !ok {
+			continue
+		}
+
+		fdcID := row.int("fdc_id")
+		food := &FDCFoodDetail{
+			FdcId:        fdcID,
+			Description:  row.get("description"),
+			DataType:     dataType,
+			FoodPortions: d.portions[fdcID],
+		}
+		for _, amount := range d.amounts[fdcID] {
+			nutrient := d.nutrients[amount.nutrient]
+			nutrient.Amount = amount.amount
+			food.FoodNutrients = append(food.FoodNutrients, nutrient)
+		}
+		if branded, ok := d.branded[fdcID]; ok {
+			food.BrandOwner = branded.brandOwner
+			food.ServingSize = branded.servingSize
+			food.ServingSizeUnit = branded.servingSizeUnit
+			food.HouseholdServingFullText = branded.householdServing
+		}
+		return food, nil
+	}
+}
+
+func (d *csvDump) Close() error {
+	return d.foods.Close()
+}
+
+// csvTable reads a table of a CSV download, whose first line names the
+// columns
+type csvTable struct {
+	file    *os.File
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+type csvRow struct {
+	table  *csvTable
+	fields []string
+}
+
+func openCSVTable(path string) (*csvTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(bufio.NewReaderSize(file, 1<<20))
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	return &csvTable{file: file, reader: reader, columns: columns}, nil
+}
+
+func (t *csvTable) next() (csvRow, error) {
+	fields, err := t.reader.Read()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("failed to read %s: %w", t.file.Name(), err)
+		}
+		return csvRow{}, err
+	}
+	return csvRow{table: t, fields: fields}, nil
+}
+
+func (t *csvTable) Close() error {
+	return t.file.Close()
+}
+
+// readCSVTable calls fn with each row of a table
+func readCSVTable(path string, fn func(csvRow) error) error {
+	table, err := openCSVTable(path)
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	for {
+		row, err := table.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// get returns the value of a column, empty when the table has no such column
+func (r csvRow) get(column string) string {
+	i, ok := r.table.columns[column]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+	return r.fields[i]
+}
+
+func (r csvRow) int(column string) int {
+	n, _ := strconv.Atoi(r.get(column))
+	return n
+}
+
+func (r csvRow) float(column string) float64 {
+	f, _ := strconv.ParseFloat(r.get(column), 64)
+	return f
+}
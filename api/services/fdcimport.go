@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFDCImportCompleted is returned when importing a download already
+// imported in full
+var ErrFDCImportCompleted = errors.New("FDC download already imported")
+
+// MaxFDCImportBatchSize keeps the statements of a batch within the number of
+// parameters Postgres accepts
+const MaxFDCImportBatchSize = 2000
+
+// FDCImportOptions tunes ImportFDC
+type FDCImportOptions struct {
+	// BatchSize is the number of foods saved per transaction
+	BatchSize int
+	// Restart imports the download from the start, even if an earlier import
+	// of it stopped or completed
+	Restart bool
+	// Progress, if set, is called after each batch
+	Progress func(models.FDCImport)
+}
+
+// fdcImportColumns are the columns of a stored food an import replaces. A
+// food deleted by an admin stays deleted.
+var fdcImportColumns = []string{
+	"name", "data_type", "brand_owner", "protein", "carbs", "fat", "calories", "fiber",
+	"serving_size", "serving_size_unit", "household_serving", "detail_fetched_at",
+	"updated_at",
+}
+
+// ImportFDC stores the foods of an FDC dataset download, JSON or CSV, with
+// their nutrients and portions, so that they can be searched without the
+// food provider. Foods already stored are updated in place by FDC ID, those
+// deleted staying deleted, and each batch records the progress of the
+// import: running it again after an interruption resumes where it stopped,
+// and stopping it when ctx is done loses nothing but the batch in progress.
+func ImportFDC(ctx context.Context, db *gorm.DB, path string, opts FDCImportOptions) (*models.FDCImport, error) {
+	if opts.BatchSize <= 0 || opts.BatchSize > MaxFDCImportBatchSize {
+		return nil, fmt.Errorf("batch size must be between 1 and %d", MaxFDCImportBatchSize)
+	}
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	size, err := fdcDumpSize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var progress models.FDCImport
+	result := db.Where("source = ?", source).Limit(1).Find(&progress)
+	switch {
+	case result.Error != nil:
+		return nil, result.Error
+	case result.RowsAffected == 0, opts.Restart, progress.Size != size:
+		progress = models.FDCImport{Source: source, Size: size, StartedAt: time.Now()}
+	case progress.CompletedAt != nil:
+		return &progress, ErrFDCImportCompleted
+	}
+
+	dump, err := openFDCDump(source)
+	if err != nil {
+		return nil, err
+	}
+	defer dump.Close()
+
+	// Skip the foods imported by the interrupted import
+	for i := 0; i < progress.Foods; i++ {
+		if _, err := dump.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return &progress, err
+		}
+	}
+
+	batch := make([]models.Food, 0, opts.BatchSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return &progress, err
+		}
+
+		batch = batch[:0]
+		read := 0
+		var readErr error
+		for len(batch) < opts.BatchSize {
+			detail, err := dump.Next()
+			if err != nil {
+				readErr = err
+				break
+			}
+			read++
+			if detail.FdcId > 0 {
+				batch = append(batch, detail.toFood())
+			}
+		}
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return &progress, fmt.Errorf("failed to read food %d: %w", progress.Foods+read+1, readErr)
+		}
+
+		progress.Foods += read
+		progress.UpdatedAt = time.Now()
+		if readErr != nil {
+			progress.CompletedAt = &progress.UpdatedAt
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := storeImportedFoods(tx, batch); err != nil {
+				return err
+			}
+			return tx.Save(&progress).Error
+		})
+		if err != nil {
+			return &progress, err
+		}
+		if opts.Progress != nil && read > 0 {
+			opts.Progress(progress)
+		}
+		if readErr != nil {
+			return &progress, nil
+		}
+	}
+}
+
+// storeImportedFoods upserts foods by FDC ID and replaces their portions
+func storeImportedFoods(tx *gorm.DB, foods []models.Food) error {
+	if len(foods) == 0 {
+		return nil
+	}
+
+	// A download may list a food twice, which a single upsert can't take
+	now := time.Now()
+	index := map[string]int{}
+	var rows []models.Food
+	var portions [][]models.FoodPortion
+	for _, food := range foods {
+		food.CreatedAt, food.UpdatedAt, food.DetailFetchedAt = now, now, &now
+		foodPortions := food.Portions
+		food.Portions = nil
+		if i, ok := index[food.FdcID]; ok {
+			rows[i], portions[i] = food, foodPortions
+			continue
+		}
+		index[food.FdcID] = len(rows)
+		rows = append(rows, food)
+		portions = append(portions, foodPortions)
+	}
+
+	err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "fdc_id"}},
+		DoUpdates: clause.AssignmentColumns(fdcImportColumns),
+	}).Create(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	// The upsert doesn't return the IDs of the updated foods on every database
+	fdcIDs := make([]string, len(rows))
+	for i, food := range rows {
+		fdcIDs[i] = food.FdcID
+	}
+	var stored []models.Food
+	if err := tx.Select("id", "fdc_id").Where("fdc_id IN ?", fdcIDs).Find(&stored).Error; err != nil {
+		return err
+	}
+
+	ids := make([]uint, len(stored))
+	var newPortions []models.FoodPortion
+	for i, food := range stored {
+		ids[i] = food.ID
+		for _, portion := range portions[index[food.FdcID]] {
+			portion.FoodID = food.ID
+			newPortions = append(newPortions, portion)
+		}
+	}
+	if err := tx.Where("food_id IN ?", ids).Delete(&models.FoodPortion{}).Error; err != nil {
+		return err
+	}
+	if len(newPortions) > 0 {
+		return tx.CreateInBatches(&newPortions, MaxFDCImportBatchSize).Error
+	}
+	return nil
+}
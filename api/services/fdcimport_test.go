@@ -0,0 +1,188 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"gorm.io/gorm"
+)
+
+// fdcJSONDump is a JSON download of three foods, one of each data type
+// which has portions or a serving
+const fdcJSONDump = `{"SRLegacyFoods": [
+	{"fdcId": 169756, "description": "Rice, white, raw", "dataType": "SR Legacy",
+	 "foodNutrients": [{"nutrient": {"name": "Protein", "unitName": "g"}, "amount": 7.13},
+	                   {"nutrient": {"name": "Energy", "unitName": "kcal"}, "amount": 365}],
+	 "foodPortions": [{"amount": 1, "modifier": "cup", "gramWeight": 185, "sequenceNumber": 1}]},
+	{"fdcId": 790646, "description": "Onions, yellow, raw", "dataType": "Foundation",
+	 "foodNutrients": [{"nutrient": {"name": "Energy (Atwater General Factors)", "unitName": "kcal"}, "amount": 38}],
+	 "foodPortions": [{"amount": 1, "modifier": "chopped", "measureUnit": {"name": "cup"}, "gramWeight": 160, "sequenceNumber": 1}]},
+	{"fdcId": 2047249, "description": "BROWN RICE", "dataType": "Branded", "brandOwner": "Example Foods Inc.",
+	 "servingSize": 45, "servingSizeUnit": "g", "householdServingFullText": "1/4 cup",
+	 "foodNutrients": [{"nutrient": {"name": "Energy", "unitName": "kcal"}, "amount": 356}]}
+]}`
+
+// fdcCSVDump is the same download as fdcJSONDump in CSV, with a sample which
+// is not a food to log
+var fdcCSVDump = map[string]string{
+	"food.csv": `fdc_id,data_type,description,food_category_id
+169756,sr_legacy_food,"Rice, white, raw",20
+790646,foundation_food,"Onions, yellow, raw",11
+1105000,sub_sample_food,"Onions, yellow, sample 1",11
+2047249,branded_food,BROWN RICE,
+`,
+	"nutrient.csv": `id,name,unit_name,nutrient_nbr
+1003,Protein,G,203
+1008,Energy,KCAL,208
+1062,Energy,kJ,268
+2047,Energy (Atwater General Factors),KCAL,957
+`,
+	"food_nutrient.csv": `id,fdc_id,nutrient_id,amount
+1,169756,1003,7.13
+2,169756,1008,365
+3,169756,1062,1527
+4,790646,2047,38
+5,2047249,1008,356
+`,
+	"measure_unit.csv": `id,name
+1000,cup
+`,
+	"food_portion.csv": `id,fdc_id,seq_num,amount,measure_unit_id,portion_description,modifier,gram_weight
+1,169756,1,1,9999,,cup,185
+2,790646,1,1,1000,,chopped,160
+`,
+	"branded_food.csv": `fdc_id,brand_owner,serving_size,serving_size_unit,household_serving_fulltext
+2047249,Example Foods Inc.,45,g,1/4 cup
+`,
+}
+
+// fdcDumps write the downloads in a directory of the test, returning the path
+// to import
+var fdcDumps = map[string]func(t *testing.T) string{
+	"json": func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "FoodData_Central_sr_legacy_food_json.json")
+		if err := os.WriteFile(path, []byte(fdcJSONDump), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	},
+	"csv": func(t *testing.T) string {
+		dir := t.TempDir()
+		for name, data := range fdcCSVDump {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	},
+}
+
+// importedFoods returns the foods stored by FDC ID, with their portions
+func importedFoods(t *testing.T, db *gorm.DB) map[string]models.Food {
+	t.Helper()
+	var foods []models.Food
+	if err := db.Unscoped().Preload("Portions").Find(&foods).Error; err != nil {
+		t.Fatalf("Failed to load the foods: %v", err)
+	}
+	byFdcID := map[string]models.Food{}
+	for _, food := range foods {
+		byFdcID[food.FdcID] = food
+	}
+	return byFdcID
+}
+
+func TestImportFDCResume(t *testing.T) {
+	for format, dump := range fdcDumps {
+		t.Run(format, func(t *testing.T) {
+			db := newTestDB(t)
+			path := dump(t)
+
+			// Interrupt the import after its first batch
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			progress, err := services.ImportFDC(ctx, db, path, services.FDCImportOptions{
+				BatchSize: 1,
+				Progress:  func(models.FDCImport) { cancel() },
+			})
+			if !errors.Is(err, context.Canceled) || progress.Foods != 1 || progress.CompletedAt != nil {
+				t.Fatalf("interrupted import: got %d foods, %v, want 1 and context.Canceled", progress.Foods, err)
+			}
+			if foods := importedFoods(t, db); len(foods) != 1 {
+				t.Fatalf("interrupted import: stored %d foods, want 1", len(foods))
+			}
+
+			// Running it again imports the other foods only
+			var batches []int
+			progress, err = services.ImportFDC(context.Background(), db, path, services.FDCImportOptions{
+				BatchSize: 1,
+				Progress:  func(progress models.FDCImport) { batches = append(batches, progress.Foods) },
+			})
+			if err != nil || progress.CompletedAt == nil {
+				t.Fatalf("resumed import: %v, completed %t", err, progress.CompletedAt != nil)
+			}
+			if !slices.Equal(batches, []int{2, 3}) {
+				t.Errorf("resumed import: progress %v, want [2 3]", batches)
+			}
+
+			foods := importedFoods(t, db)
+			rice, onions, brown := foods["169756"], foods["790646"], foods["2047249"]
+			if len(foods) != 3 || rice.Calories != 365 || rice.Protein != 7.13 || onions.Calories != 38 {
+				t.Errorf("stored foods %+v, want the 3 of the download with their nutrients", foods)
+			}
+			if len(rice.Portions) != 1 || len(onions.Portions) != 1 || onions.Portions[0].Description != "1 cup, chopped" {
+				t.Errorf("portions of rice %+v and onions %+v, want one each", rice.Portions, onions.Portions)
+			}
+			if brown.BrandOwner != "Example Foods Inc." || brown.ServingSize != 45 || brown.HouseholdServing != "1/4 cup" {
+				t.Errorf("branded food = %+v, want its brand and serving", brown)
+			}
+		})
+	}
+}
+
+func TestImportFDCTwice(t *testing.T) {
+	for format, dump := range fdcDumps {
+		t.Run(format, func(t *testing.T) {
+			db := newTestDB(t)
+			path := dump(t)
+			opts := services.FDCImportOptions{BatchSize: 2}
+
+			if _, err := services.ImportFDC(context.Background(), db, path, opts); err != nil {
+				t.Fatalf("first import: %v", err)
+			}
+			before := importedFoods(t, db)
+			// An admin deletes a food between the imports
+			onions := before["790646"]
+			if err := db.Delete(&onions).Error; err != nil {
+				t.Fatalf("Failed to delete the food: %v", err)
+			}
+
+			if _, err := services.ImportFDC(context.Background(), db, path, opts); !errors.Is(err, services.ErrFDCImportCompleted) {
+				t.Errorf("same download again: got %v, want ErrFDCImportCompleted", err)
+			}
+			opts.Restart = true
+			if _, err := services.ImportFDC(context.Background(), db, path, opts); err != nil {
+				t.Fatalf("restarted import: %v", err)
+			}
+
+			after := importedFoods(t, db)
+			if len(after) != len(before) {
+				t.Fatalf("got %d foods after the second import, want %d", len(after), len(before))
+			}
+			for fdcID, food := range before {
+				got := after[fdcID]
+				if got.ID != food.ID || got.Name != food.Name || got.Calories != food.Calories || len(got.Portions) != len(food.Portions) {
+					t.Errorf("food %s = %+v after the second import, want %+v", fdcID, got, food)
+				}
+			}
+			if !after["790646"].DeletedAt.Valid {
+				t.Error("the deleted food was restored by the import")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Environment variables holding the FDC API keys
+const (
+	// FDCKeysEnv is a comma-separated list of keys; FDC_API_KEY is accepted
+	// too for a single key
+	FDCKeysEnv = "FDC_API_KEYS"
+	// FDCKeysFileEnv is the path of a secrets file holding a key per line,
+	// e.g. a Docker secret. Empty lines and lines starting with # are ignored.
+	FDCKeysFileEnv = "FDC_API_KEYS_FILE"
+)
+
+// LoadFDCKeys returns the FDC API keys configured in the environment and in
+// the secrets file it points to, without duplicates
+func LoadFDCKeys() ([]string, error) {
+	var keys []string
+	add := func(key string) {
+		key = strings.TrimSpace(key)
+		if key != "" && !strings.HasPrefix(key, "#") && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	if path := os.Getenv(FDCKeysFileEnv); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			add(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range strings.Split(os.Getenv(FDCKeysEnv)+","+os.Getenv("FDC_API_KEY"), ",") {
+		add(key)
+	}
+	return keys, nil
+}
+
+// FDCKeys are FDC API keys used one at a time: when FDC rate limits a key,
+// the next one replaces it
+type FDCKeys struct {
+	mu      sync.Mutex
+	keys    []string
+	current int
+}
+
+func NewFDCKeys(keys []string) *FDCKeys {
+	return &FDCKeys{keys: keys}
+}
+
+// Transport returns a transport sending the current key with each request
+// through base, or http.DefaultTransport when nil, and switching to the next
+// key when FDC answers 429. Put it under NewHTTPClient's retries so that
+// they use the next key.
+func (k *FDCKeys) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		key := k.key()
+		// In a header rather than the query so that it stays out of logged URLs
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Api-Key", key)
+
+		resp, err := base.RoundTrip(req)
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			k.rotate(key)
+		}
+		return resp, err
+	})
+}
+
+func (k *FDCKeys) key() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.keys[k.current]
+}
+
+// rotate moves on to the next key, unless a concurrent request already
+// replaced the rate-limited one
+func (k *FDCKeys) rotate(limited string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys[k.current] == limited {
+		k.current = (k.current + 1) % len(k.keys)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+)
+
+// Formats of the food logs exported by other trackers
+const (
+	// FoodLogMyFitnessPal is MyFitnessPal's nutrition export, with a line per
+	// meal, or per food when it has a food column
+	FoodLogMyFitnessPal = "mfp"
+	// FoodLogCronometer is Cronometer's servings export, with a line per food
+	FoodLogCronometer = "cronometer"
+)
+
+// FoodLogFormats lists the formats ParseFoodLog reads
+var FoodLogFormats = []string{FoodLogMyFitnessPal, FoodLogCronometer}
+
+// ErrInvalidFoodLog is returned for a file which is not a food log export
+var ErrInvalidFoodLog = errors.New("invalid food log")
+
+// FoodLogEntry is a food logged in another tracker
+type FoodLogEntry struct {
+	Line int
+	Date time.Time
+	Meal models.MealType
+	Food string
+	// Amount is the quantity as exported, e.g. "1.00 cup"
+	Amount string
+	// Grams is the weight eaten, 0 when the amount doesn't give it
+	Grams                                float64
+	Calories, Protein, Carbs, Fat, Fiber float64
+}
+
+// foodLogColumns lists the names of each column in the exports
+var foodLogColumns = map[string][]string{
+	"date":     {"Date", "Day"},
+	"meal":     {"Meal", "Group"},
+	"food":     {"Food Name", "Food"},
+	"amount":   {"Amount", "Quantity"},
+	"calories": {"Energy (kcal)", "Calories"},
+	"protein":  {"Protein (g)", "Protein"},
+	"carbs":    {"Carbs (g)", "Carbohydrates (g)", "Carbohydrates"},
+	"fat":      {"Fat (g)", "Fat"},
+	"fiber":    {"Fiber (g)", "Fiber"},
+}
+
+// foodLogDateLayouts are the date formats of the exports
+var foodLogDateLayouts = []string{"2006-01-02", "1/2/2006", "2006/01/02", "2006-01-02 15:04:05"}
+
+// weightAmount matches the weights in an amount, e.g. "150 g" or "1 cup - 158g"
+var weightAmount = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(g|oz)\b`)
+
+// gramsPerOunce converts the weights given in ounces
+const gramsPerOunce = 28.3495
+
+// ParseFoodLog reads a CSV food log exported by MyFitnessPal or Cronometer,
+// detecting the format when it is empty, and returns the format with the
+// entries and the lines that can't be imported
+func ParseFoodLog(r io.Reader, format string) (string, []FoodLogEntry, []models.FoodLogLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return "", nil, nil, fmt.Errorf("%w: the file is empty", ErrInvalidFoodLog)
+	}
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %w", ErrInvalidFoodLog, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		for column, names := range foodLogColumns {
+			if _, ok := columns[column]; !ok && slices.Contains(names, name) {
+				columns[column] = i
+			}
+		}
+	}
+
+	if format == "" {
+		format = FoodLogMyFitnessPal
+		if slices.Contains(header, "Group") {
+			format = FoodLogCronometer
+		}
+	}
+	required := []string{"date", "meal", "calories"}
+	if format == FoodLogCronometer {
+		required = append(required, "food")
+	}
+	for _, column := range required {
+		if _, ok := columns[column]; !ok {
+			return "", nil, nil, fmt.Errorf("%w: no %s column, expected one of %s", ErrInvalidFoodLog,
+				column, strings.Join(foodLogColumns[column], ", "))
+		}
+	}
+
+	var entries []FoodLogEntry
+	var skipped []models.FoodLogLine
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return format, entries, skipped, nil
+		}
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("%w: %w", ErrInvalidFoodLog, err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		entry, err := parseFoodLogRecord(record, columns)
+		entry.Line = line
+		if err != nil {
+			skipped = append(skipped, models.FoodLogLine{Line: line, Food: entry.Food, Reason: err.Error()})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func parseFoodLogRecord(record []string, columns map[string]int) (FoodLogEntry, error) {
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	entry := FoodLogEntry{Food: value("food"), Amount: value("amount")}
+	meal := value("meal")
+	if entry.Food == "" {
+		if _, ok := columns["food"]; ok {
+			return entry, errors.New("no food name")
+		}
+		// A line per meal, logged as a single food
+		entry.Food = meal + " (MyFitnessPal)"
+	}
+	entry.Meal = foodLogMeal(meal)
+
+	var err error
+	for _, layout := range foodLogDateLayouts {
+		if entry.Date, err = time.Parse(layout, value("date")); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return entry, fmt.Errorf("invalid date %q", value("date"))
+	}
+	entry.Date = time.Date(entry.Date.Year(), entry.Date.Month(), entry.Date.Day(), 0, 0, 0, 0, time.UTC)
+
+	for column, field := range map[string]*float64{
+		"calories": &entry.Calories,
+		"protein":  &entry.Protein,
+		"carbs":    &entry.Carbs,
+		"fat":      &entry.Fat,
+		"fiber":    &entry.Fiber,
+	} {
+		text := strings.ReplaceAll(value(column), ",", "")
+		if text == "" {
+			continue
+		}
+		if *field, err = strconv.ParseFloat(text, 64); err != nil || *field < 0 || math.IsInf(*field, 0) {
+			return entry, fmt.Errorf("invalid %s %q", column, value(column))
+		}
+	}
+
+	if weights := weightAmount.FindAllStringSubmatch(entry.Amount, -1); len(weights) > 0 {
+		weight := weights[len(weights)-1]
+		entry.Grams, _ = strconv.ParseFloat(weight[1], 64)
+		if strings.EqualFold(weight[2], "oz") {
+			entry.Grams *= gramsPerOunce
+		}
+	}
+	return entry, nil
+}
+
+// foodLogMeal maps the meals of the other trackers to ours, snacks and the
+// other meals being breaks
+func foodLogMeal(name string) models.MealType {
+	switch strings.ToLower(name) {
+	case "breakfast":
+		return models.Breakfast
+	case "lunch":
+		return models.Lunch
+	case "dinner":
+		return models.Dinner
+	}
+	return models.Break
+}
+
+// maxImportedGrams is the largest quantity of a food an import logs, as for
+// a food added to a meal
+const maxImportedGrams = 10000
+
+// errDryRun rolls back the import of a dry run
+var errDryRun = errors.New("dry run")
+
+// importedMeal is a meal receiving imported foods
+type importedMeal struct {
+	id uint
+	// logged are the grams of the foods already in the meal before the
+	// import, by food ID
+	logged map[uint]float64
+	// added are the foods the import adds, by food ID
+	added map[uint]*models.MealFood
+	order []uint
+}
+
+// ImportFoodLog logs the entries in the user's meals, creating the meals
+// missing. Entries are matched by name to the stored foods, with the
+// quantity of the entry or the one giving its calories; a custom food with
+// the entry's nutrients is created for the others. Foods logged twice in a
+// meal add up to at most maxImportedGrams, and foods already in a meal
+// before the import are skipped, so that importing a log twice logs nothing
+// new. When report.DryRun is set, the report is filled without saving
+// anything.
+func ImportFoodLog(db *gorm.DB, userID uint, entries []FoodLogEntry, report *models.FoodLogImport) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		meals := map[string]*importedMeal{}
+		var order []*importedMeal
+		for _, entry := range entries {
+			food, grams, custom, err := importedFood(tx, userID, entry)
+			if err != nil {
+				return err
+			}
+			if reason := importedGramsReason(grams); reason != "" {
+				report.Skipped = append(report.Skipped, models.FoodLogLine{Line: entry.Line, Food: entry.Food, Reason: reason})
+				continue
+			}
+			// Custom foods are only saved for the entries logged
+			if custom {
+				if err := saveCustomFood(tx, &food, report); err != nil {
+					return err
+				}
+			}
+
+			key := entry.Date.Format("2006-01-02") + " " + string(entry.Meal)
+			meal, ok := meals[key]
+			if !ok {
+				var created bool
+				if meal, created, err = importMeal(tx, userID, entry.Date, entry.Meal); err != nil {
+					return err
+				}
+				if created {
+					report.Meals++
+				}
+				meals[key] = meal
+				order = append(order, meal)
+			}
+			if _, ok := meal.logged[food.ID]; ok {
+				report.Skipped = append(report.Skipped, models.FoodLogLine{Line: entry.Line, Food: entry.Food,
+					Reason: "food already in the meal"})
+				continue
+			}
+			added, merged := meal.added[food.ID]
+			if merged {
+				if reason := importedGramsReason(added.Grams + grams); reason != "" {
+					report.Skipped = append(report.Skipped, models.FoodLogLine{Line: entry.Line, Food: entry.Food, Reason: reason})
+					continue
+				}
+			}
+			if custom {
+				report.Unmatched = append(report.Unmatched, models.FoodLogLine{Line: entry.Line, Food: entry.Food})
+			}
+
+			// The weight is in the grams, e.g. "1.00 cup - 158g" is measured
+			// as "1.00 cup"
+			measure := strings.Trim(weightAmount.ReplaceAllString(entry.Amount, ""), " -,")
+			report.Entries++
+			if merged {
+				added.Grams += grams
+				added.Measure = strings.Trim(added.Measure+" + "+measure, " +")
+				continue
+			}
+			meal.added[food.ID] = &models.MealFood{MealID: meal.id, FoodID: food.ID, Grams: grams, Measure: measure}
+			meal.order = append(meal.order, food.ID)
+		}
+
+		for _, meal := range order {
+			for _, foodID := range meal.order {
+				if err := tx.Create(meal.added[foodID]).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if report.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// importedGramsReason returns why a quantity of a food isn't logged, empty
+// if it is
+func importedGramsReason(grams float64) string {
+	switch {
+	case grams <= 0:
+		// Rounded to 0 g, which the meal would store as the default 100 g
+		return "quantity of 0 g"
+	case grams > maxImportedGrams:
+		return fmt.Sprintf("quantity over %d g", maxImportedGrams)
+	}
+	return ""
+}
+
+// importedFood returns the stored food an entry matches and the grams
+// eaten, or a custom food with the entry's nutrients, not saved yet, and
+// whether it is one
+func importedFood(tx *gorm.DB, userID uint, entry FoodLogEntry) (models.Food, float64, bool, error) {
+	var food models.Food
+	result := tx.Where("LOWER(name) = ? AND user_id IS NULL", strings.ToLower(entry.Food)).Order("id").Limit(1).Find(&food)
+	if result.Error != nil {
+		return food, 0, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		switch {
+		case entry.Grams > 0:
+			return food, entry.Grams, false, nil
+		case entry.Calories > 0 && food.Calories > 0:
+			return food, math.Round(entry.Calories/food.Calories*1000) / 10, false, nil
+		}
+	}
+
+	// Custom foods give their nutrients per 100 g, like the others. An entry
+	// without weight is logged as 100 g of a food with its nutrients.
+	grams, scale := 100.0, 1.0
+	if entry.Grams > 0 {
+		grams, scale = entry.Grams, 100/entry.Grams
+	}
+	round := func(value float64) float64 {
+		return math.Round(value*scale*100) / 100
+	}
+	food = models.Food{
+		Name:     entry.Food,
+		DataType: DataTypeCustom,
+		Calories: round(entry.Calories),
+		Protein:  round(entry.Protein),
+		Carbs:    round(entry.Carbs),
+		Fat:      round(entry.Fat),
+		Fiber:    round(entry.Fiber),
+		UserID:   &userID,
+	}
+	food.FdcID = customFoodID(userID, food)
+	return food, grams, true, nil
+}
+
+// saveCustomFood loads the user's custom food with the FDC ID of food, or
+// creates it if they have none yet
+func saveCustomFood(tx *gorm.DB, food *models.Food, report *models.FoodLogImport) error {
+	result := tx.Where("fdc_id = ?", food.FdcID).Limit(1).Find(food)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if err := tx.Create(food).Error; err != nil {
+		return err
+	}
+	report.CustomFoods++
+	return nil
+}
+
+// customFoodID returns the FDC ID of a custom food of the user: the same
+// food with the same nutrients is the same custom food
+func customFoodID(userID uint, food models.Food) string {
+	hash := sha256.Sum256(fmt.Appendf(nil, "%s|%g|%g|%g|%g|%g", strings.ToLower(food.Name),
+		food.Calories, food.Protein, food.Carbs, food.Fat, food.Fiber))
+	return fmt.Sprintf("custom-%d-%x", userID, hash[:6])
+}
+
+// importMeal returns the user's meal of the date and type, created if there
+// is none, and whether it was
+func importMeal(tx *gorm.DB, userID uint, date time.Time, mealType models.MealType) (*importedMeal, bool, error) {
+	meal := &importedMeal{logged: map[uint]float64{}, added: map[uint]*models.MealFood{}}
+
+	var stored models.Meal
+	day := truncateDay(date)
+	result := tx.Where("user_id = ? AND meal_type = ? AND date >= ? AND date < ?", userID, mealType, day, day.AddDate(0, 0, 1)).
+		Order("id").Limit(1).Find(&stored)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		stored = models.Meal{Type: mealType, Date: date, UserID: userID}
+		if err := tx.Omit("Foods").Create(&stored).Error; err != nil {
+			return nil, false, err
+		}
+		meal.id = stored.ID
+		return meal, true, nil
+	}
+
+	meal.id = stored.ID
+	var logged []models.MealFood
+	if err := tx.Select("food_id", "grams").Where("meal_id = ?", stored.ID).Find(&logged).Error; err != nil {
+		return nil, false, err
+	}
+	for _, food := range logged {
+		meal.logged[food.FoodID] = food.Grams
+	}
+	return meal, false, nil
+}
@@ -0,0 +1,61 @@
+package services_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+)
+
+func TestImportFoodLog(t *testing.T) {
+	db := newTestDB(t)
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Age: 36, Weight: 60, Height: 165}
+	rice := models.Food{FdcID: "169756", Name: "Rice", DataType: "SR Legacy", Calories: 365}
+	egg := models.Food{FdcID: "171287", Name: "Egg", DataType: "SR Legacy", Calories: 143}
+	create(t, db, &user, &rice, &egg)
+	// A meal logged at noon is the meal of its day
+	meal := models.Meal{Type: models.Lunch, Date: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), UserID: user.ID}
+	create(t, db, &meal)
+	create(t, db, &models.MealFood{MealID: meal.ID, FoodID: egg.ID, Grams: 50})
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	entries := []services.FoodLogEntry{
+		{Line: 2, Date: day, Meal: models.Lunch, Food: "Rice", Amount: "6000 g", Grams: 6000},
+		{Line: 3, Date: day, Meal: models.Lunch, Food: "Rice", Amount: "5000 g", Grams: 5000},
+		{Line: 4, Date: day, Meal: models.Lunch, Food: "rice", Amount: "1000 g", Grams: 1000},
+		// 0.1 kcal of rice rounds to 0 g
+		{Line: 5, Date: day, Meal: models.Lunch, Food: "Rice", Amount: "1 pinch", Calories: 0.1},
+		{Line: 6, Date: day, Meal: models.Lunch, Food: "Egg", Amount: "50 g", Grams: 50},
+	}
+	var report models.FoodLogImport
+	if err := services.ImportFoodLog(db, user.ID, entries, &report); err != nil {
+		t.Fatalf("ImportFoodLog: %v", err)
+	}
+
+	want := []models.FoodLogLine{
+		{Line: 3, Food: "Rice", Reason: "quantity over 10000 g"},
+		{Line: 5, Food: "Rice", Reason: "quantity of 0 g"},
+		{Line: 6, Food: "Egg", Reason: "food already in the meal"},
+	}
+	if !slices.Equal(report.Skipped, want) {
+		t.Errorf("skipped %+v, want %+v", report.Skipped, want)
+	}
+	if report.Entries != 2 || report.Meals != 0 {
+		t.Errorf("logged %d entries in %d new meals, want 2 in the existing meal", report.Entries, report.Meals)
+	}
+
+	var logged []models.MealFood
+	if err := db.Where("meal_id = ? AND food_id = ?", meal.ID, rice.ID).Find(&logged).Error; err != nil {
+		t.Fatalf("Failed to load the meal: %v", err)
+	}
+	if len(logged) != 1 || logged[0].Grams != 7000 || logged[0].Measure != "" {
+		t.Errorf("rice logged %+v, want 7000 g in one line", logged)
+	}
+	var meals int64
+	db.Model(&models.Meal{}).Where("user_id = ?", user.ID).Count(&meals)
+	if meals != 1 {
+		t.Errorf("user has %d meals, want 1", meals)
+	}
+}
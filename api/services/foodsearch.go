@@ -0,0 +1,224 @@
+package services
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+)
+
+// NormalizeFoodQuery lowercases a search query and collapses its spaces, so
+// that equivalent queries share their cache entry
+func NormalizeFoodQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// EnsureFoodSearchIndex creates the full-text index over food names: a GIN
+// index on their tsvector on Postgres, an FTS5 table kept in sync by
+// triggers on SQLite. Other databases fall back to LIKE searches.
+func EnsureFoodSearchIndex(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return db.Exec(`CREATE INDEX IF NOT EXISTS idx_foods_name_fts ON foods USING GIN (to_tsvector('english', name))`).Error
+	case "sqlite":
+		statements := []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS foods_fts USING fts5(name, content='foods', content_rowid='id', tokenize='porter unicode61')`,
+			`CREATE TRIGGER IF NOT EXISTS foods_fts_insert AFTER INSERT ON foods BEGIN
+				INSERT INTO foods_fts(rowid, name) VALUES (new.id, new.name);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS foods_fts_delete AFTER DELETE ON foods BEGIN
+				INSERT INTO foods_fts(foods_fts, rowid, name) VALUES ('delete', old.id, old.name);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS foods_fts_update AFTER UPDATE OF name ON foods BEGIN
+				INSERT INTO foods_fts(foods_fts, rowid, name) VALUES ('delete', old.id, old.name);
+				INSERT INTO foods_fts(rowid, name) VALUES (new.id, new.name);
+			END`,
+			// Index the foods stored before the table existed
+			`INSERT INTO foods_fts(foods_fts) VALUES ('rebuild')`,
+		}
+		for _, statement := range statements {
+			if err := db.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rankedFood is a food matching a search with its relevance, higher is better
+type rankedFood struct {
+	models.Food
+	Relevance float64
+}
+
+// maxLocalCandidates caps the number of stored foods ranked for a search
+const maxLocalCandidates = 1000
+
+// SearchLocalFoods searches the stored foods by name and returns the page of
+// the query, with the number of matches across all pages. Foods are ranked
+// by relevance and, when userID is not 0, by how often the user logged them,
+// unless the query asks for another order.
+func SearchLocalFoods(db *gorm.DB, query FoodSearchQuery, userID uint) ([]models.Food, int, error) {
+	terms := strings.Fields(NormalizeFoodQuery(query.Query))
+	if len(terms) == 0 {
+		return []models.Food{}, 0, nil
+	}
+
+	// Custom foods are only visible to their user
+	filters := "foods.deleted_at IS NULL AND (foods.user_id IS NULL OR foods.user_id = ?)"
+	args := []any{userID}
+	if len(query.DataTypes) > 0 {
+		filters += " AND foods.data_type IN ?"
+		args = append(args, query.DataTypes)
+	}
+	if query.BrandOwner != "" {
+		filters += " AND LOWER(foods.brand_owner) = ?"
+		args = append(args, strings.ToLower(query.BrandOwner))
+	}
+
+	var matches []rankedFood
+	var err error
+	switch db.Dialector.Name() {
+	case "postgres":
+		tsQuery := tsQueryExpression(terms)
+		err = db.Raw(`SELECT foods.*, ts_rank(to_tsvector('english', name), to_tsquery('english', ?)) AS relevance
+			FROM foods
+			WHERE to_tsvector('english', name) @@ to_tsquery('english', ?) AND `+filters+`
+			ORDER BY relevance DESC, id
+			LIMIT ?`, slices.Concat([]any{tsQuery, tsQuery}, args, []any{maxLocalCandidates})...).Scan(&matches).Error
+	case "sqlite":
+		err = db.Raw(`SELECT foods.*, -bm25(foods_fts) AS relevance
+			FROM foods_fts JOIN foods ON foods.id = foods_fts.rowid
+			WHERE foods_fts MATCH ? AND `+filters+`
+			ORDER BY relevance DESC, foods.id
+			LIMIT ?`, slices.Concat([]any{ftsMatchExpression(terms)}, args, []any{maxLocalCandidates})...).Scan(&matches).Error
+	default:
+		q := db.Model(&models.Food{}).Where(filters, args...)
+		for _, term := range terms {
+			q = q.Where("LOWER(name) LIKE ?", "%"+term+"%")
+		}
+		err = q.Select("foods.*, 1 AS relevance").Order("id").Limit(maxLocalCandidates).Scan(&matches).Error
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	uses, err := foodUses(db, userID, matches)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Relevance scales differ between databases: normalize it before
+	// boosting the foods the user logs often
+	best := 0.0
+	for _, match := range matches {
+		best = math.Max(best, match.Relevance)
+	}
+	score := func(match rankedFood) float64 {
+		relevance := 1.0
+		if best > 0 {
+			relevance = match.Relevance / best
+		}
+		return relevance * (1 + math.Log1p(float64(uses[match.ID])))
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return score(matches[i]) > score(matches[j])
+	})
+
+	// Other orders keep the ranking between equal values
+	var key func(rankedFood) string
+	switch query.Sort {
+	case FoodSortName:
+		key = func(f rankedFood) string { return strings.ToLower(f.Name) }
+	case FoodSortDataType:
+		key = func(f rankedFood) string { return f.DataType }
+	}
+	if key != nil {
+		sort.SliceStable(matches, func(i, j int) bool {
+			if query.Descending {
+				return key(matches[i]) > key(matches[j])
+			}
+			return key(matches[i]) < key(matches[j])
+		})
+	}
+
+	start := min((max(query.Page, 1)-1)*query.PageSize, len(matches))
+	end := min(start+query.PageSize, len(matches))
+	foods := make([]models.Food, 0, end-start)
+	for _, match := range matches[start:end] {
+		foods = append(foods, match.Food)
+	}
+	return foods, len(matches), nil
+}
+
+// searchWords splits search terms into the words the full-text indexes
+// know, dropping the punctuation their query syntaxes would read
+func searchWords(terms []string) []string {
+	return strings.FieldsFunc(strings.Join(terms, " "), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ftsMatchExpression turns search terms into an FTS5 query matching names
+// containing every word, the last one as a prefix so that partial words match
+func ftsMatchExpression(terms []string) string {
+	words := searchWords(terms)
+	if len(words) == 0 {
+		// Matches nothing rather than failing on an empty expression
+		return `""`
+	}
+
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = `"` + word + `"`
+	}
+	quoted[len(quoted)-1] += "*"
+	return strings.Join(quoted, " ")
+}
+
+// tsQueryExpression is ftsMatchExpression for Postgres: a to_tsquery
+// expression matching every word, the last one as a prefix
+func tsQueryExpression(terms []string) string {
+	words := searchWords(terms)
+	if len(words) == 0 {
+		// An empty query matches nothing
+		return ""
+	}
+	return strings.Join(words, " & ") + ":*"
+}
+
+// foodUses counts how many of the user's meals contain each of the foods
+func foodUses(db *gorm.DB, userID uint, foods []rankedFood) (map[uint]int, error) {
+	uses := map[uint]int{}
+	if userID == 0 || len(foods) == 0 {
+		return uses, nil
+	}
+
+	ids := make([]uint, len(foods))
+	for i, food := range foods {
+		ids[i] = food.ID
+	}
+
+	var counts []struct {
+		FoodID uint
+		Uses   int
+	}
+	err := db.Table("meal_foods").
+		Select("meal_foods.food_id, COUNT(*) AS uses").
+		Joins("JOIN meals ON meals.id = meal_foods.meal_id").
+		Where("meals.user_id = ? AND meals.deleted_at IS NULL AND meal_foods.food_id IN ?", userID, ids).
+		Group("meal_foods.food_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, count := range counts {
+		uses[count.FoodID] = count.Uses
+	}
+	return uses, nil
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoodSearchExpressions(t *testing.T) {
+	tests := []struct {
+		query string
+		fts   string
+		ts    string
+	}{
+		{"rice", `"rice"*`, "rice:*"},
+		{"chicken bre", `"chicken" "bre"*`, "chicken & bre:*"},
+		// Punctuation is no query syntax
+		{`kellogg's "corn" flakes*`, `"kellogg" "s" "corn" "flakes"*`, "kellogg & s & corn & flakes:*"},
+		{"milk 2% & !", `"milk" "2"*`, "milk & 2:*"},
+		{"crème brûlée", `"crème" "brûlée"*`, "crème & brûlée:*"},
+		{"!!", `""`, ""},
+	}
+	for _, test := range tests {
+		terms := strings.Fields(NormalizeFoodQuery(test.query))
+		if got := ftsMatchExpression(terms); got != test.fts {
+			t.Errorf("ftsMatchExpression(%q) = %s, want %s", test.query, got, test.fts)
+		}
+		if got := tsQueryExpression(terms); got != test.ts {
+			t.Errorf("tsQueryExpression(%q) = %s, want %s", test.query, got, test.ts)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting a food provider that failed
+// too many times in a row, until its cooldown is over
+var ErrCircuitOpen = errors.New("food provider unavailable after repeated failures")
+
+// UpstreamError is an unexpected status answered by a food provider
+type UpstreamError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *UpstreamError) Error() string {
+	return "food provider answered " + e.Status
+}
+
+// IsTimeout tells whether err is a food provider that didn't answer in time
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// HTTPOptions tunes the HTTP client of the food providers
+type HTTPOptions struct {
+	// Timeout bounds a request with all its retries
+	Timeout time.Duration
+	// AttemptTimeout bounds each attempt
+	AttemptTimeout time.Duration
+	// MaxRetries is the number of retries of a failed GET request
+	MaxRetries int
+	// BaseBackoff is the wait before the first retry, doubled before each
+	// next one up to MaxBackoff, with jitter
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// FailureThreshold failed requests in a row open the circuit: requests
+	// fail right away with ErrCircuitOpen for Cooldown, after which a single
+	// request is let through to probe the provider
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// DefaultHTTPOptions are the settings of the food providers in production
+var DefaultHTTPOptions = HTTPOptions{
+	Timeout:          15 * time.Second,
+	AttemptTimeout:   5 * time.Second,
+	MaxRetries:       2,
+	BaseBackoff:      200 * time.Millisecond,
+	MaxBackoff:       2 * time.Second,
+	FailureThreshold: 5,
+	Cooldown:         30 * time.Second,
+}
+
+// NewHTTPClient returns a client for the food providers which sends requests
+// through base, or http.DefaultTransport when nil, with timeouts, retries
+// with backoff on network errors, 429 and 5xx answers, and a circuit breaker
+func NewHTTPClient(base http.RoundTripper, opts HTTPOptions) *http.Client {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &resilientTransport{base: base, opts: opts},
+	}
+}
+
+type resilientTransport struct {
+	base http.RoundTripper
+	opts HTTPOptions
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.acquire(); err != nil {
+		return nil, err
+	}
+
+	attempts := 1
+	// Only requests without side effects are safe to send twice
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		attempts += t.opts.MaxRetries
+	}
+
+	var resp *http.Response
+	var err error
+	abandoned := false
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			waitErr := sleep(req.Context(), t.backoff(attempt, resp))
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if waitErr != nil {
+				// The caller gave up before the retry
+				resp, err, abandoned = nil, waitErr, true
+				break
+			}
+		}
+
+		resp, err = t.attempt(req)
+		if !retryable(req, resp, err) {
+			break
+		}
+	}
+
+	if abandoned || (err != nil && errors.Is(req.Context().Err(), context.Canceled)) {
+		// The caller gave up, which says nothing about the provider
+		t.abandon()
+	} else {
+		t.release(err == nil && !retryableStatus(resp.StatusCode))
+	}
+	return resp, err
+}
+
+// attempt sends the request once, within AttemptTimeout
+func (t *resilientTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.opts.AttemptTimeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.AttemptTimeout)
+	resp, err := t.base.RoundTrip(req.Clone(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout also covers reading the body
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryable tells whether a failed attempt is worth another one: not when
+// the caller gave up, nor when the provider answered something else than a
+// transient error
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return retryableStatus(resp.StatusCode)
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// backoff returns the wait before a retry: the provider's Retry-After when
+// it fits within MaxBackoff, an exponential backoff with full jitter otherwise
+func (t *resilientTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if wait := time.Duration(seconds) * time.Second; wait <= t.opts.MaxBackoff {
+				return wait
+			}
+		}
+	}
+
+	ceiling := t.opts.BaseBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > t.opts.MaxBackoff {
+		ceiling = t.opts.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// acquire fails with ErrCircuitOpen while the circuit is open, and lets a
+// single probe through once the cooldown is over
+func (t *resilientTransport) acquire() error {
+	if t.opts.FailureThreshold <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures < t.opts.FailureThreshold {
+		return nil
+	}
+	if time.Now().Before(t.openUntil) || t.probing {
+		return fmt.Errorf("%w, retry in %s", ErrCircuitOpen, time.Until(t.openUntil).Round(time.Second))
+	}
+	t.probing = true
+	return nil
+}
+
+// release records the outcome of a request, opening the circuit again when
+// the probe failed
+func (t *resilientTransport) release(ok bool) {
+	if t.opts.FailureThreshold <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+	if ok {
+		t.failures = 0
+		return
+	}
+	t.failures++
+	if t.failures >= t.opts.FailureThreshold {
+		t.openUntil = time.Now().Add(t.opts.Cooldown)
+	}
+}
+
+// abandon ends a request without recording its outcome
+func (t *resilientTransport) abandon() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+}
+
+// cancelOnClose releases the context of an attempt once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastHTTPOptions are options short enough for tests, without circuit
+// breaker
+var fastHTTPOptions = HTTPOptions{
+	Timeout:        time.Second,
+	AttemptTimeout: 500 * time.Millisecond,
+	MaxRetries:     2,
+	BaseBackoff:    time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+}
+
+// newStatusServer returns a server answering the statuses in turn, the last
+// one for every request after them, and the number of requests it got
+func newStatusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit := int(hits.Add(1))
+		w.WriteHeader(statuses[min(hit, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestHTTPClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		want     int
+		hits     int32
+	}{
+		{"retry server errors", http.MethodGet, []int{500, 502, 200}, 200, 3},
+		{"retry rate limits", http.MethodGet, []int{429, 200}, 200, 2},
+		{"give up after the retries", http.MethodGet, []int{503}, 503, 3},
+		{"keep client errors", http.MethodGet, []int{404}, 404, 1},
+		{"send a POST once", http.MethodPost, []int{500, 200}, 500, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, hits := newStatusServer(t, test.statuses...)
+			client := NewHTTPClient(nil, fastHTTPOptions)
+
+			req, _ := http.NewRequest(test.method, server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.want || hits.Load() != test.hits {
+				t.Errorf("got status %d after %d requests, want %d after %d", resp.StatusCode, hits.Load(), test.want, test.hits)
+			}
+		})
+	}
+}
+
+func TestHTTPClientBackoff(t *testing.T) {
+	transport := &resilientTransport{opts: HTTPOptions{BaseBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}}
+	retryAfter := func(value string) *http.Response {
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {value}}}
+	}
+
+	if wait := transport.backoff(1, retryAfter("1")); wait != time.Second {
+		t.Errorf("Retry-After 1: waited %s, want 1s", wait)
+	}
+	// Retry-After beyond MaxBackoff, or not in seconds, falls back to the
+	// exponential backoff
+	for _, value := range []string{"60", "Wed, 21 Oct 2015 07:28:00 GMT"} {
+		if wait := transport.backoff(1, retryAfter(value)); wait <= 0 || wait > 100*time.Millisecond {
+			t.Errorf("Retry-After %s: waited %s, want at most 100ms", value, wait)
+		}
+	}
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: 2 * time.Second} {
+		for range 20 {
+			if wait := transport.backoff(attempt, nil); wait <= 0 || wait > ceiling {
+				t.Errorf("attempt %d: waited %s, want at most %s", attempt, wait, ceiling)
+			}
+		}
+	}
+}
+
+func TestHTTPClientCircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	opts := fastHTTPOptions
+	opts.MaxRetries, opts.FailureThreshold, opts.Cooldown = 0, 2, 50*time.Millisecond
+	client := NewHTTPClient(nil, opts)
+	get := func() (int, error) {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	for range 2 {
+		if status, err := get(); status != http.StatusInternalServerError {
+			t.Fatalf("closed circuit: got %d, %v, want 500", status, err)
+		}
+	}
+	if _, err := get(); !errors.Is(err, ErrCircuitOpen) || hits.Load() != 2 {
+		t.Fatalf("open circuit: got %v after %d requests, want ErrCircuitOpen after 2", err, hits.Load())
+	}
+
+	// A failed probe opens the circuit again
+	time.Sleep(opts.Cooldown)
+	if status, _ := get(); status != http.StatusInternalServerError || hits.Load() != 3 {
+		t.Fatalf("failed probe: got %d after %d requests, want 500 after 3", status, hits.Load())
+	}
+	if _, err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after a failed probe: got %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful probe closes it
+	time.Sleep(opts.Cooldown)
+	failing.Store(false)
+	for range 3 {
+		if status, err := get(); status != http.StatusOK {
+			t.Fatalf("closed again: got %d, %v, want 200", status, err)
+		}
+	}
+}
+
+func TestHTTPClientSingleProbe(t *testing.T) {
+	release := make(chan struct{})
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		<-release
+	}))
+	t.Cleanup(server.Close)
+
+	opts := fastHTTPOptions
+	opts.MaxRetries, opts.FailureThreshold, opts.Cooldown = 0, 1, 10*time.Millisecond
+	client := NewHTTPClient(nil, opts)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	time.Sleep(opts.Cooldown)
+
+	// While the probe waits for its answer, other requests fail right away
+	probed := make(chan error)
+	go func() {
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		probed <- err
+	}()
+	for hits.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := client.Get(server.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("during the probe: got %v, want ErrCircuitOpen", err)
+	}
+	close(release)
+	if err := <-probed; err != nil {
+		t.Errorf("probe: unexpected error %v", err)
+	}
+}
+
+func TestHTTPClientCallerGivesUp(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{"cancelled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}, context.DeadlineExceeded},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, hits := newStatusServer(t, http.StatusServiceUnavailable)
+			// The caller gives up while waiting to retry
+			opts := fastHTTPOptions
+			opts.BaseBackoff, opts.MaxBackoff, opts.FailureThreshold, opts.Cooldown = time.Hour, time.Hour, 1, time.Hour
+			client := NewHTTPClient(nil, opts)
+			client.Timeout = 0
+
+			ctx, cancel := test.ctx()
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			resp, err := client.Do(req)
+			if resp != nil {
+				resp.Body.Close()
+				t.Fatalf("got status %d, want no response", resp.StatusCode)
+			}
+			if !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+
+			// Giving up says nothing about the provider: the circuit stays
+			// closed
+			ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if _, err := client.Do(req); errors.Is(err, ErrCircuitOpen) || hits.Load() != 2 {
+				t.Errorf("next request: got %v after %d requests, want it sent", err, hits.Load())
+			}
+		})
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	opts := fastHTTPOptions
+	opts.Timeout, opts.AttemptTimeout, opts.MaxRetries = time.Second, 20*time.Millisecond, 1
+	_, err := NewHTTPClient(nil, opts).Get(server.URL)
+	if !IsTimeout(err) {
+		t.Errorf("attempts timing out: IsTimeout(%v) = false", err)
+	}
+	if IsTimeout(errors.New("connection refused")) || IsTimeout(&UpstreamError{StatusCode: 502}) {
+		t.Error("IsTimeout is true for errors other than timeouts")
+	}
+	if !strings.Contains((&UpstreamError{StatusCode: 502, Status: "502 Bad Gateway"}).Error(), "502 Bad Gateway") {
+		t.Error("UpstreamError does not tell the status")
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"slices"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+)
+
+// MealEntry is a food eaten in a meal, with the nutrients of the quantity
+// eaten. The food's values are the user's own when they overrode them.
+type MealEntry struct {
+	MealID   uint
+	MealType models.MealType
+	Date     time.Time
+	FdcID    string
+	Food     string
+	Grams    float64
+	Measure  string
+	models.Nutrients
+}
+
+// Day returns the day of the entry's meal
+func (e MealEntry) Day() time.Time {
+	return truncateDay(e.Date)
+}
+
+// truncateDay returns midnight UTC of the day of t, the days the date
+// filters of the API compare with
+func truncateDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// MealEntries returns the foods the user ate between from and to, both
+// inclusive days, ordered by day, meal type and food name. A zero from or
+// to leaves the range open on that side.
+func MealEntries(db *gorm.DB, userID uint, from, to time.Time) ([]MealEntry, error) {
+	query := db.Table("meal_foods").
+		Select(`meals.id AS meal_id, meals.meal_type, meals.date, foods.fdc_id,
+			COALESCE(o.name, foods.name) AS food, meal_foods.grams, meal_foods.measure,
+			COALESCE(o.calories, foods.calories) AS calories, COALESCE(o.protein, foods.protein) AS protein,
+			COALESCE(o.carbs, foods.carbs) AS carbs, COALESCE(o.fat, foods.fat) AS fat,
+			COALESCE(o.fiber, foods.fiber) AS fiber`).
+		Joins("JOIN meals ON meals.id = meal_foods.meal_id").
+		Joins("JOIN foods ON foods.id = meal_foods.food_id").
+		Joins("LEFT JOIN food_overrides o ON o.food_id = foods.id AND o.user_id = meals.user_id AND o.deleted_at IS NULL").
+		Where("meals.user_id = ? AND meals.deleted_at IS NULL", userID)
+	if !from.IsZero() {
+		query = query.Where("meals.date >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("meals.date < ?", to.AddDate(0, 0, 1))
+	}
+
+	var entries []MealEntry
+	if err := query.Order("meals.date, meals.id, food").Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	// The values of the foods are per 100 g
+	for i := range entries {
+		entries[i].Nutrients = entries[i].Nutrients.Scale(entries[i].Grams / 100)
+	}
+	sortMealEntries(entries)
+	return entries, nil
+}
+
+// sortMealEntries orders entries by day and meal type, keeping the order of
+// those of a same meal
+func sortMealEntries(entries []MealEntry) {
+	slices.SortStableFunc(entries, func(a, b MealEntry) int {
+		if c := a.Day().Compare(b.Day()); c != 0 {
+			return c
+		}
+		return slices.Index(models.MealTypes, a.MealType) - slices.Index(models.MealTypes, b.MealType)
+	})
+}
+
+// DayTotals are the nutrients eaten in a day
+type DayTotals struct {
+	Date    time.Time
+	Meals   int
+	Entries int
+	models.Nutrients
+}
+
+// DailyRange returns the days DailyTotals covers: from and to, the zero ones
+// replaced by the first or last day of the entries. Either stays zero when
+// there are no entries.
+func DailyRange(entries []MealEntry, from, to time.Time) (time.Time, time.Time) {
+	if len(entries) > 0 {
+		if from.IsZero() {
+			from = entries[0].Day()
+		}
+		if to.IsZero() {
+			to = entries[len(entries)-1].Day()
+		}
+	}
+	return from, to
+}
+
+// DailyTotals sums the entries per day, from the day of from to the one of
+// to, days without entries included. Zero bounds are those of the entries.
+func DailyTotals(entries []MealEntry, from, to time.Time) []DayTotals {
+	from, to = DailyRange(entries, from, to)
+	if from.IsZero() || to.IsZero() {
+		return nil
+	}
+
+	var days []DayTotals
+	index := map[time.Time]int{}
+	for day := truncateDay(from); !day.After(truncateDay(to)); day = day.AddDate(0, 0, 1) {
+		index[day] = len(days)
+		days = append(days, DayTotals{Date: day})
+	}
+	meals := map[uint]bool{}
+	for _, entry := range entries {
+		i, ok := index[entry.Day()]
+		if !ok {
+			continue
+		}
+		days[i].Entries++
+		days[i].Add(entry.Nutrients)
+		if !meals[entry.MealID] {
+			meals[entry.MealID] = true
+			days[i].Meals++
+		}
+	}
+	return days
+}
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+)
+
+// ErrProviderOffline is returned by the OfflineProvider
+var ErrProviderOffline = errors.New("no food provider configured")
+
+// OfflineProvider is the food provider of a server without FDC API key: the
+// foods already stored are the only ones available
+type OfflineProvider struct{}
+
+func (OfflineProvider) SearchFoods(context.Context, FoodSearchQuery) (*FoodSearchResult, error) {
+	return nil, ErrProviderOffline
+}
+
+func (OfflineProvider) FoodDetail(context.Context, string) (*models.Food, error) {
+	return nil, ErrProviderOffline
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/internal/calculator"
+	"gorm.io/gorm"
+)
+
+// Formats of the printable report
+const (
+	PrintableHTML = "html"
+	PrintablePDF  = "pdf"
+)
+
+// PrintableFormats are the formats a PrintableReport is written in
+var PrintableFormats = []string{PrintableHTML, PrintablePDF}
+
+// UserStats computes the health indicators of the user's profile
+func UserStats(user models.User) models.UserStats {
+	bmi := calculator.CalculateBMI(user.Weight, user.Height)
+	return models.UserStats{
+		Height: user.Height,
+		Weight: user.Weight,
+		BMI:    bmi,
+		BFP:    calculator.CalculateBFP(bmi, user.Age, user.Sex),
+		IMG:    calculator.CalculateIMG(user.Weight, user.Height, user.Sex),
+		BMR:    calculator.CalculateBasalMetabolism(user.Weight, user.Height, user.Age, user.Sex),
+	}
+}
+
+// PrintableReport is the summary of a user's period handed out on paper:
+// their profile, weight, average intake against their targets and meals
+type PrintableReport struct {
+	User        models.User
+	Stats       models.UserStats
+	From, To    time.Time
+	GeneratedAt time.Time
+	// Targets are nil when the user has none
+	Targets    *models.Target
+	Days       []DayTotals
+	LoggedDays int
+	// Averages are the daily averages over the logged days
+	Averages models.Nutrients
+	Weights  []models.WeightRecord
+	Meals    []PrintableMeal
+}
+
+// PrintableMeal is a meal of a printable report with the foods eaten
+type PrintableMeal struct {
+	Date    time.Time
+	Type    models.MealType
+	Entries []MealEntry
+	models.Nutrients
+}
+
+// PrintableNutrient is a row of the average intake against the targets
+type PrintableNutrient struct {
+	Name    string
+	Unit    string
+	Average float64
+	// Target is 0 when the user has none for the nutrient
+	Target float64
+}
+
+// Percent returns the percentage of the target reached on average
+func (n PrintableNutrient) Percent() float64 {
+	if n.Target <= 0 {
+		return 0
+	}
+	return n.Average / n.Target * 100
+}
+
+// BuildPrintableReport gathers the user's data of the days from from to to
+func BuildPrintableReport(db *gorm.DB, user models.User, from, to, now time.Time) (*PrintableReport, error) {
+	report := &PrintableReport{User: user, Stats: UserStats(user), From: from, To: to, GeneratedAt: now}
+
+	var targets []models.Target
+	if err := db.Where("user_id = ?", user.ID).Limit(1).Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	if len(targets) > 0 {
+		report.Targets = &targets[0]
+	}
+
+	entries, err := MealEntries(db, user.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report.Days = DailyTotals(entries, from, to)
+	var totals models.Nutrients
+	for _, day := range report.Days {
+		if day.Meals > 0 {
+			report.LoggedDays++
+			totals.Add(day.Nutrients)
+		}
+	}
+	if report.LoggedDays > 0 {
+		report.Averages = totals.Scale(1 / float64(report.LoggedDays))
+	}
+	for _, entry := range entries {
+		if n := len(report.Meals); n == 0 || report.Meals[n-1].Entries[0].MealID != entry.MealID {
+			report.Meals = append(report.Meals, PrintableMeal{Date: entry.Day(), Type: entry.MealType})
+		}
+		meal := &report.Meals[len(report.Meals)-1]
+		meal.Entries = append(meal.Entries, entry)
+		meal.Add(entry.Nutrients)
+	}
+
+	if err := db.Where("user_id = ? AND date >= ? AND date < ?", user.ID, from, to.AddDate(0, 0, 1)).
+		Order("date, id").Find(&report.Weights).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Nutrients returns the rows of the average intake against the targets
+func (r *PrintableReport) Nutrients() []PrintableNutrient {
+	var target models.Target
+	if r.Targets != nil {
+		target = *r.Targets
+	}
+	return []PrintableNutrient{
+		{"Calories", "kcal", r.Averages.Calories, target.Calories},
+		{"Protein", "g", r.Averages.Protein, target.Protein},
+		{"Carbs", "g", r.Averages.Carbs, target.Carbs},
+		{"Fat", "g", r.Averages.Fat, target.Fat},
+		{"Fiber", "g", r.Averages.Fiber, target.Fiber},
+	}
+}
+
+// weightChart lays the weight records out in a chart of width by height,
+// y growing downwards: it returns the points of the records, placed by date
+// over the report's period, and the weights at the top and bottom
+func (r *PrintableReport) weightChart(width, height float64) (points [][2]float64, top, bottom float64) {
+	if len(r.Weights) == 0 {
+		return nil, 0, 0
+	}
+	top, bottom = r.Weights[0].Weight, r.Weights[0].Weight
+	for _, record := range r.Weights {
+		top, bottom = max(top, record.Weight), min(bottom, record.Weight)
+	}
+	// Leave a margin around the records, and some room for a flat line
+	margin := max((top-bottom)*0.1, 0.5)
+	top, bottom = top+margin, bottom-margin
+
+	span := r.To.AddDate(0, 0, 1).Sub(r.From).Seconds()
+	for _, record := range r.Weights {
+		x := record.Date.Sub(r.From).Seconds() / span * width
+		y := (top - record.Weight) / (top - bottom) * height
+		points = append(points, [2]float64{min(max(x, 0), width), y})
+	}
+	return points, top, bottom
+}
+
+// profile returns the labels and values of the user's profile and stats
+func (r *PrintableReport) profile() [][2]string {
+	u, s := r.User, r.Stats
+	rows := [][2]string{
+		{"Name", u.FirstName + " " + u.LastName},
+		{"Age", fmt.Sprintf("%d years", u.Age)},
+		{"Sex", sexName(u.Sex)},
+		{"Height", fmt.Sprintf("%d cm", s.Height)},
+		{"Weight", fmt.Sprintf("%.1f kg", s.Weight)},
+		{"Activity", fmt.Sprintf("%d days per week", u.ActivityLevel)},
+		{"BMI", fmt.Sprintf("%.1f", s.BMI)},
+		{"Body fat", fmt.Sprintf("%.1f %%", s.BFP)},
+		{"IMG", fmt.Sprintf("%.1f %%", s.IMG)},
+		{"Basal metabolism", fmt.Sprintf("%.0f kcal/day", s.BMR)},
+	}
+	if u.Goal != "" {
+		rows = append(rows, [2]string{"Goal", u.Goal})
+	}
+	return rows
+}
+
+// sexName returns the name of the User.Sex values
+func sexName(sex int) string {
+	if sex == 1 {
+		return "Male"
+	}
+	return "Female"
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// Size of the weight chart of the HTML report, in SVG units
+const (
+	htmlChartWidth  = 640
+	htmlChartHeight = 200
+)
+
+var printableHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2006-01-02") },
+	"num": func(decimals int, f float64) string {
+		return fmt.Sprintf("%.*f", decimals, f)
+	},
+	"points": func(points [][2]float64) string {
+		coords := make([]string, len(points))
+		for i, p := range points {
+			coords[i] = fmt.Sprintf("%.1f,%.1f", p[0], p[1])
+		}
+		return strings.Join(coords, " ")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Nutrition report - {{.User.FirstName}} {{.User.LastName}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 800px; margin: 2em auto; padding: 0 1em; }
+h1 { margin-bottom: 0.2em; }
+h2 { border-bottom: 2px solid #2a7ab0; padding-bottom: 0.2em; margin-top: 1.5em; }
+.period { color: #666; margin-top: 0; }
+table { border-collapse: collapse; width: 100%; margin: 0.5em 0; }
+th, td { padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child, .food { text-align: left; }
+th { background: #f2f6f9; }
+.profile td:first-child { font-weight: bold; width: 40%; }
+.total td { font-weight: bold; }
+.day { page-break-inside: avoid; }
+svg text { font-size: 11px; fill: #666; }
+@media print { body { margin: 0; max-width: none; } h2 { page-break-after: avoid; } }
+</style>
+</head>
+<body>
+<h1>Nutrition report</h1>
+<p class="period">{{.User.FirstName}} {{.User.LastName}}, from {{date .From}} to {{date .To}}. Generated on {{date .GeneratedAt}}.</p>
+
+<h2>Profile</h2>
+<table class="profile">
+{{range .Profile}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+
+<h2>Weight</h2>
+{{with .Chart}}<svg viewBox="-50 -10 {{.ViewWidth}} {{.ViewHeight}}" width="100%" role="img" aria-label="Weight chart">
+<line x1="0" y1="0" x2="0" y2="{{.Height}}" stroke="#999"/>
+<line x1="0" y1="{{.Height}}" x2="{{.Width}}" y2="{{.Height}}" stroke="#999"/>
+<text x="-6" y="4" text-anchor="end">{{num 1 .Top}} kg</text>
+<text x="-6" y="{{.Height}}" text-anchor="end">{{num 1 .Bottom}} kg</text>
+<text x="0" y="{{.LabelY}}">{{date $.From}}</text>
+<text x="{{.Width}}" y="{{.LabelY}}" text-anchor="end">{{date $.To}}</text>
+<polyline points="{{points .Points}}" fill="none" stroke="#2a7ab0" stroke-width="2"/>
+{{range .Points}}<circle cx="{{num 1 (index . 0)}}" cy="{{num 1 (index . 1)}}" r="3" fill="#2a7ab0"/>
+{{end}}</svg>
+{{end}}{{if .Weights}}<table>
+<tr><th>Date</th><th>Weight</th><th class="food">Note</th></tr>
+{{range .Weights}}<tr><td>{{date .Date}}</td><td>{{num 1 .Weight}} kg</td><td class="food">{{.Note}}</td></tr>
+{{end}}</table>
+{{else}}<p>No weight recorded during the period.</p>
+{{end}}
+<h2>Average intake</h2>
+<p>Daily averages over the {{.LoggedDays}} day(s) with meals out of {{len .Days}}.</p>
+<table>
+<tr><th>Nutrient</th><th>Average</th><th>Target</th><th>Of target</th></tr>
+{{range .Nutrients}}<tr><td>{{.Name}}</td><td>{{num 0 .Average}} {{.Unit}}</td>{{if gt .Target 0.0}}<td>{{num 0 .Target}} {{.Unit}}</td><td>{{num 0 .Percent}} %</td>{{else}}<td>-</td><td>-</td>{{end}}</tr>
+{{end}}</table>
+
+<h2>Meals</h2>
+{{range .Meals}}<div class="day">
+<h3>{{date .Date}} - {{.Type}}</h3>
+<table>
+<tr><th>Food</th><th>Quantity</th><th>Calories</th><th>Protein</th><th>Carbs</th><th>Fat</th></tr>
+{{range .Entries}}<tr><td>{{.Food}}</td><td>{{if .Measure}}{{.Measure}}, {{end}}{{num 0 .Grams}} g</td><td>{{num 0 .Calories}} kcal</td><td>{{num 1 .Protein}} g</td><td>{{num 1 .Carbs}} g</td><td>{{num 1 .Fat}} g</td></tr>
+{{end}}<tr class="total"><td>Total</td><td></td><td>{{num 0 .Calories}} kcal</td><td>{{num 1 .Protein}} g</td><td>{{num 1 .Carbs}} g</td><td>{{num 1 .Fat}} g</td></tr>
+</table>
+</div>
+{{else}}<p>No meals logged during the period.</p>
+{{end}}</body>
+</html>
+`))
+
+// htmlChart is the weight chart of the HTML report
+type htmlChart struct {
+	Width, Height         float64
+	ViewWidth, ViewHeight float64
+	LabelY                float64
+	Points                [][2]float64
+	Top, Bottom           float64
+}
+
+// WriteHTML writes the report as a self-contained HTML page, to be opened in
+// a browser and printed
+func (r *PrintableReport) WriteHTML(w io.Writer) error {
+	data := struct {
+		*PrintableReport
+		Profile   [][2]string
+		Nutrients []PrintableNutrient
+		Chart     *htmlChart
+	}{PrintableReport: r, Profile: r.profile(), Nutrients: r.Nutrients()}
+	if points, top, bottom := r.weightChart(htmlChartWidth, htmlChartHeight); len(points) > 0 {
+		data.Chart = &htmlChart{
+			Width: htmlChartWidth, Height: htmlChartHeight,
+			// Room for the weights on the left and the dates below
+			ViewWidth: htmlChartWidth + 60, ViewHeight: htmlChartHeight + 30,
+			LabelY: htmlChartHeight + 16,
+			Points: points, Top: top, Bottom: bottom,
+		}
+	}
+	return printableHTML.Execute(w, data)
+}
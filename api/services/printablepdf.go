@@ -0,0 +1,239 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/internal/pdf"
+)
+
+// Layout of the PDF report, in points
+const (
+	pdfMargin      = 50
+	pdfWidth       = pdf.PageWidth - 2*pdfMargin
+	pdfBottom      = pdf.PageHeight - pdfMargin
+	pdfRowHeight   = 16
+	pdfChartHeight = 150
+	// pdfChartLeft is the room left of the weight chart for its weights
+	pdfChartLeft = 50
+)
+
+var (
+	pdfAccent = pdf.Color{R: 0.16, G: 0.48, B: 0.69}
+	pdfMuted  = pdf.Color{R: 0.4, G: 0.4, B: 0.4}
+	pdfRule   = pdf.Color{R: 0.85, G: 0.85, B: 0.85}
+	pdfShade  = pdf.Color{R: 0.95, G: 0.96, B: 0.98}
+)
+
+// pdfColumn is a column of a table of the PDF report, its amounts aligned
+// right
+type pdfColumn struct {
+	title string
+	width float64
+	right bool
+}
+
+// pdfLayout writes the report top to bottom, adding pages as they fill up
+type pdfLayout struct {
+	doc   *pdf.Document
+	pages []*pdf.Page
+	y     float64
+}
+
+func (l *pdfLayout) page() *pdf.Page {
+	return l.pages[len(l.pages)-1]
+}
+
+func (l *pdfLayout) newPage() {
+	l.pages = append(l.pages, l.doc.AddPage())
+	l.y = pdfMargin
+}
+
+// ensure starts a new page unless height points are left on this one
+func (l *pdfLayout) ensure(height float64) {
+	if l.y+height > pdfBottom {
+		l.newPage()
+	}
+}
+
+// text writes a line of text at the left margin
+func (l *pdfLayout) text(font pdf.Font, size float64, color pdf.Color, s string) {
+	l.ensure(size * 1.5)
+	l.y += size * 1.2
+	l.page().Text(pdfMargin, l.y, font, size, color, s)
+	l.y += size * 0.3
+}
+
+// heading writes the title of a section, kept with the lines following it
+func (l *pdfLayout) heading(title string) {
+	l.ensure(70)
+	l.y += 14
+	l.text(pdf.HelveticaBold, 14, pdfAccent, title)
+	l.page().Line(pdfMargin, l.y+2, pdfMargin+pdfWidth, l.y+2, 1.5, pdfAccent)
+	l.y += 8
+}
+
+// row writes a row of a table, shaded and in bold for its header
+func (l *pdfLayout) row(columns []pdfColumn, cells []string, header bool) {
+	l.ensure(pdfRowHeight)
+	font, page := pdf.Helvetica, l.page()
+	if header {
+		font = pdf.HelveticaBold
+		page.Rect(pdfMargin, l.y, pdfWidth, pdfRowHeight, pdfShade)
+	}
+	x := float64(pdfMargin)
+	for i, column := range columns {
+		cell := fitText(font, 9, cells[i], column.width-8)
+		switch {
+		case cell == "":
+		case column.right:
+			page.Text(x+column.width-4-pdf.TextWidth(font, 9, cell), l.y+11.5, font, 9, pdf.Black, cell)
+		default:
+			page.Text(x+4, l.y+11.5, font, 9, pdf.Black, cell)
+		}
+		x += column.width
+	}
+	l.y += pdfRowHeight
+	page.Line(pdfMargin, l.y, pdfMargin+pdfWidth, l.y, 0.5, pdfRule)
+}
+
+// table writes a table, repeating its header, if its columns have titles,
+// on the pages it spans
+func (l *pdfLayout) table(columns []pdfColumn, rows [][]string) {
+	titles, header := make([]string, len(columns)), false
+	for i, column := range columns {
+		titles[i] = column.title
+		header = header || column.title != ""
+	}
+	for i, cells := range rows {
+		if i == 0 || l.y+pdfRowHeight > pdfBottom {
+			l.ensure(pdfRowHeight * 2)
+			if header {
+				l.row(columns, titles, true)
+			}
+		}
+		l.row(columns, cells, false)
+	}
+	l.y += 6
+}
+
+// fitText shortens s with an ellipsis to fit in width
+func fitText(font pdf.Font, size float64, s string, width float64) string {
+	if pdf.TextWidth(font, size, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.TextWidth(font, size, string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+// WritePDF writes the report as an A4 PDF document
+func (r *PrintableReport) WritePDF(w io.Writer) error {
+	name := r.User.FirstName + " " + r.User.LastName
+	l := &pdfLayout{doc: pdf.New("Nutrition report - " + name)}
+	l.newPage()
+
+	l.text(pdf.HelveticaBold, 22, pdf.Black, "Nutrition report")
+	l.text(pdf.Helvetica, 11, pdfMuted, fmt.Sprintf("%s, from %s to %s. Generated on %s.",
+		name, r.From.Format(csvDate), r.To.Format(csvDate), r.GeneratedAt.Format(csvDate)))
+
+	l.heading("Profile")
+	var profile [][]string
+	for _, row := range r.profile() {
+		profile = append(profile, []string{row[0], row[1]})
+	}
+	l.table([]pdfColumn{{title: "", width: pdfWidth * 0.4}, {title: "", width: pdfWidth * 0.6}}, profile)
+
+	l.heading("Weight")
+	if len(r.Weights) == 0 {
+		l.text(pdf.Helvetica, 10, pdf.Black, "No weight recorded during the period.")
+	} else {
+		r.writePDFChart(l)
+		var weights [][]string
+		for _, record := range r.Weights {
+			weights = append(weights, []string{record.Date.Format(csvDate), fmt.Sprintf("%.1f kg", record.Weight), record.Note})
+		}
+		l.table([]pdfColumn{{title: "Date", width: 90}, {title: "Weight", width: 80, right: true}, {title: "Note", width: pdfWidth - 170}}, weights)
+	}
+
+	l.heading("Average intake")
+	l.text(pdf.Helvetica, 10, pdf.Black, fmt.Sprintf("Daily averages over the %d day(s) with meals out of %d.", r.LoggedDays, len(r.Days)))
+	l.y += 4
+	var nutrients [][]string
+	for _, n := range r.Nutrients() {
+		target, percent := "-", "-"
+		if n.Target > 0 {
+			target, percent = fmt.Sprintf("%.0f %s", n.Target, n.Unit), fmt.Sprintf("%.0f %%", n.Percent())
+		}
+		nutrients = append(nutrients, []string{n.Name, fmt.Sprintf("%.0f %s", n.Average, n.Unit), target, percent})
+	}
+	quarter := pdfWidth / 4
+	l.table([]pdfColumn{{title: "Nutrient", width: quarter}, {title: "Average", width: quarter, right: true},
+		{title: "Target", width: quarter, right: true}, {title: "Of target", width: quarter, right: true}}, nutrients)
+
+	l.heading("Meals")
+	if len(r.Meals) == 0 {
+		l.text(pdf.Helvetica, 10, pdf.Black, "No meals logged during the period.")
+	}
+	columns := []pdfColumn{{title: "Food", width: pdfWidth - 321}, {title: "Quantity", width: 105, right: true},
+		{title: "Calories", width: 60, right: true}, {title: "Protein", width: 52, right: true},
+		{title: "Carbs", width: 52, right: true}, {title: "Fat", width: 52, right: true}}
+	for _, meal := range r.Meals {
+		// Keep the title of a meal with its first foods
+		l.ensure(22 + 3*pdfRowHeight)
+		l.y += 6
+		l.text(pdf.HelveticaBold, 11, pdf.Black, fmt.Sprintf("%s - %s", meal.Date.Format(csvDate), meal.Type))
+		var rows [][]string
+		for _, entry := range meal.Entries {
+			quantity := fmt.Sprintf("%.0f g", entry.Grams)
+			if entry.Measure != "" {
+				quantity = entry.Measure + ", " + quantity
+			}
+			rows = append(rows, []string{entry.Food, quantity, fmt.Sprintf("%.0f kcal", entry.Calories),
+				fmt.Sprintf("%.1f g", entry.Protein), fmt.Sprintf("%.1f g", entry.Carbs), fmt.Sprintf("%.1f g", entry.Fat)})
+		}
+		rows = append(rows, []string{"Total", "", fmt.Sprintf("%.0f kcal", meal.Calories),
+			fmt.Sprintf("%.1f g", meal.Protein), fmt.Sprintf("%.1f g", meal.Carbs), fmt.Sprintf("%.1f g", meal.Fat)})
+		l.table(columns, rows)
+	}
+
+	for i, page := range l.pages {
+		footer := fmt.Sprintf("%s - page %d of %d", name, i+1, len(l.pages))
+		page.Text(pdfMargin, pdf.PageHeight-pdfMargin/2, pdf.Helvetica, 8, pdfMuted, footer)
+	}
+	_, err := l.doc.WriteTo(w)
+	return err
+}
+
+// writePDFChart draws the weight records as a line over the period
+func (r *PrintableReport) writePDFChart(l *pdfLayout) {
+	l.ensure(pdfChartHeight + 30)
+	width := pdfWidth - pdfChartLeft
+	left, top := float64(pdfMargin+pdfChartLeft), l.y+8
+	points, high, low := r.weightChart(width, pdfChartHeight)
+	page := l.page()
+
+	page.Line(left, top, left, top+pdfChartHeight, 0.75, pdfMuted)
+	page.Line(left, top+pdfChartHeight, left+width, top+pdfChartHeight, 0.75, pdfMuted)
+	for _, label := range []struct {
+		y      float64
+		weight float64
+	}{{top + 4, high}, {top + pdfChartHeight, low}} {
+		text := fmt.Sprintf("%.1f kg", label.weight)
+		page.Text(left-6-pdf.TextWidth(pdf.Helvetica, 8, text), label.y, pdf.Helvetica, 8, pdfMuted, text)
+	}
+	to := r.To.Format(csvDate)
+	page.Text(left, top+pdfChartHeight+12, pdf.Helvetica, 8, pdfMuted, r.From.Format(csvDate))
+	page.Text(left+width-pdf.TextWidth(pdf.Helvetica, 8, to), top+pdfChartHeight+12, pdf.Helvetica, 8, pdfMuted, to)
+
+	for i := range points {
+		points[i][0] += left
+		points[i][1] += top
+		page.Rect(points[i][0]-2, points[i][1]-2, 4, 4, pdfAccent)
+	}
+	page.Polyline(points, 1.5, pdfAccent)
+	l.y = top + pdfChartHeight + 24
+}
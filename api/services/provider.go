@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+)
+
+// Data types of the foods of FoodData Central
+const (
+	DataTypeFoundation = "Foundation"
+	DataTypeSRLegacy   = "SR Legacy"
+	DataTypeBranded    = "Branded"
+	DataTypeSurvey     = "Survey (FNDDS)"
+	// DataTypeCustom is the data type of the foods created for a user, e.g.
+	// when importing their food log
+	DataTypeCustom = "Custom"
+)
+
+// dataTypeAliases maps the lowercased names accepted for each data type
+var dataTypeAliases = map[string]string{
+	"foundation":     DataTypeFoundation,
+	"sr legacy":      DataTypeSRLegacy,
+	"sr-legacy":      DataTypeSRLegacy,
+	"legacy":         DataTypeSRLegacy,
+	"branded":        DataTypeBranded,
+	"survey":         DataTypeSurvey,
+	"survey (fndds)": DataTypeSurvey,
+	"fndds":          DataTypeSurvey,
+}
+
+// ParseDataTypes parses a comma-separated list of data types, accepting
+// their FDC names and short aliases such as "sr-legacy" or "survey"
+func ParseDataTypes(list string) ([]string, error) {
+	var dataTypes []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		dataType, ok := dataTypeAliases[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown data type %q", name)
+		}
+		if !slices.Contains(dataTypes, dataType) {
+			dataTypes = append(dataTypes, dataType)
+		}
+	}
+	sort.Strings(dataTypes)
+	return dataTypes, nil
+}
+
+// Orders of food search results
+const (
+	FoodSortRelevance = "relevance"
+	FoodSortName      = "name"
+	FoodSortDataType  = "dataType"
+)
+
+// FoodSearchQuery is a food search with its filters and the page wanted
+type FoodSearchQuery struct {
+	Query string
+	// Page starts at 1
+	Page     int
+	PageSize int
+	// DataTypes restricts the search to these data types, all when empty
+	DataTypes  []string
+	BrandOwner string
+	// Sort is one of the FoodSort values; Descending reverses it, except for
+	// relevance
+	Sort       string
+	Descending bool
+}
+
+// Key identifies the search for the cache of provider queries: equivalent
+// searches share it
+func (q FoodSearchQuery) Key() string {
+	key := fmt.Sprintf("%s|page=%d|size=%d", NormalizeFoodQuery(q.Query), q.Page, q.PageSize)
+	if len(q.DataTypes) > 0 {
+		key += "|types=" + strings.Join(q.DataTypes, ",")
+	}
+	if q.BrandOwner != "" {
+		key += "|brand=" + strings.ToLower(q.BrandOwner)
+	}
+	if q.Sort != "" && q.Sort != FoodSortRelevance {
+		key += "|sort=" + q.Sort
+		if q.Descending {
+			key += "-desc"
+		}
+	}
+	return key
+}
+
+// FoodSearchResult is a page of foods found by a provider
+type FoodSearchResult struct {
+	Foods      []models.Food
+	TotalHits  int
+	TotalPages int
+}
+
+// ErrFoodNotFound is returned by a FoodProvider asked for an unknown food
+var ErrFoodNotFound = errors.New("food not found")
+
+// FoodProvider is a remote food database
+type FoodProvider interface {
+	SearchFoods(ctx context.Context, query FoodSearchQuery) (*FoodSearchResult, error)
+	// FoodDetail fetches a food by its FDC ID, with its portions
+	FoodDetail(ctx context.Context, fdcID string) (*models.Food, error)
+}
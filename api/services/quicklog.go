@@ -0,0 +1,687 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidQuickLog is returned for a sentence naming no food to log
+var ErrInvalidQuickLog = errors.New("invalid quick log")
+
+// QuickLogSentence is a quick log sentence as read by ParseQuickLog, e.g.
+// "150g chicken breast and 1 cup rice for lunch yesterday"
+type QuickLogSentence struct {
+	Date time.Time
+	Meal models.MealType
+	// MealGuessed tells the sentence named no meal: Meal comes from the time
+	// of day
+	MealGuessed bool
+	Items       []QuickLogItem
+}
+
+// QuickLogItem is a food named in a sentence, e.g. "1 cup rice"
+type QuickLogItem struct {
+	Text string
+	// Quantity is 1 when the item gives none, e.g. "rice": HasQuantity
+	// tells it apart from "1 rice", and "0g rice" from no quantity
+	Quantity    float64
+	HasQuantity bool
+	// Unit is the name of one of the quickLogUnits, empty for a number of
+	// items, e.g. "2 eggs"
+	Unit string
+	Food string
+}
+
+// quickLogUnit is a unit of the quantities of a sentence
+type quickLogUnit struct {
+	// words name the unit, the first one being how it is shown; the
+	// portions of a food are matched on them too, e.g. "1 cup, chopped"
+	words []string
+	// grams is the weight of a unit of mass or volume, 0 for the household
+	// measures, which depend on the food
+	grams float64
+	// liquid is set for the units of volume, weighed as water
+	liquid bool
+	// volume is the volume in ml of a household measure, weighing it when
+	// the food has no portion in it
+	volume float64
+}
+
+func (u quickLogUnit) name() string {
+	return u.words[0]
+}
+
+var quickLogUnits = []quickLogUnit{
+	{words: []string{"g", "gram", "grams", "gr"}, grams: 1},
+	{words: []string{"kg", "kilo", "kilos", "kilogram", "kilograms"}, grams: 1000},
+	{words: []string{"oz", "ounce", "ounces"}, grams: gramsPerOunce},
+	{words: []string{"lb", "lbs", "pound", "pounds"}, grams: gramsPerPound},
+	{words: []string{"ml", "milliliter", "milliliters", "millilitre", "millilitres"}, grams: 1, liquid: true},
+	{words: []string{"cl", "centiliter", "centiliters", "centilitre", "centilitres"}, grams: 10, liquid: true},
+	{words: []string{"l", "liter", "liters", "litre", "litres"}, grams: 1000, liquid: true},
+	{words: []string{"cup", "cups"}, volume: 240},
+	{words: []string{"tbsp", "tablespoon", "tablespoons", "tbs"}, volume: 15},
+	{words: []string{"tsp", "teaspoon", "teaspoons"}, volume: 5},
+	{words: []string{"glass", "glasses"}, volume: 250},
+	{words: []string{"slice", "slices"}},
+	{words: []string{"piece", "pieces", "pc", "pcs"}},
+	{words: []string{"serving", "servings", "portion", "portions"}},
+	{words: []string{"can", "cans"}},
+	{words: []string{"bowl", "bowls"}},
+	{words: []string{"handful", "handfuls"}},
+	{words: []string{"scoop", "scoops"}},
+}
+
+const gramsPerPound = 453.592
+
+// findQuickLogUnit returns the unit a word names
+func findQuickLogUnit(word string) (quickLogUnit, bool) {
+	for _, unit := range quickLogUnits {
+		if slices.Contains(unit.words, word) {
+			return unit, true
+		}
+	}
+	return quickLogUnit{}, false
+}
+
+// quickLogToken matches the words of a sentence: ISO dates, numbers and
+// fractions, words and the separators of a list
+var quickLogToken = regexp.MustCompile(`\d{4}-\d{2}-\d{2}|\d+(?:[.,]\d+)?(?:/\d+)?|[½¼¾⅓⅔]|[\p{L}'’]+(?:-[\p{L}'’]+)*|[,;:&+]`)
+
+// token is a word of a sentence as written, with its lowercase form and
+// where it is in the sentence
+type token struct {
+	text, word string
+	start, end int
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	for _, bounds := range quickLogToken.FindAllStringIndex(text, -1) {
+		word := text[bounds[0]:bounds[1]]
+		tokens = append(tokens, token{text: word, word: strings.ToLower(word), start: bounds[0], end: bounds[1]})
+	}
+	return tokens
+}
+
+// joinTokens writes tokens back as a sentence
+func joinTokens(tokens []token) string {
+	var text strings.Builder
+	for i, t := range tokens {
+		if i > 0 && t.word != "," && t.word != ";" && t.word != ":" {
+			text.WriteByte(' ')
+		}
+		text.WriteString(t.text)
+	}
+	return text.String()
+}
+
+// quantityWords are the words standing for a quantity, e.g. "a banana" or
+// "half a cup"
+var quantityWords = map[string]float64{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"half": 0.5, "quarter": 0.25, "dozen": 12,
+}
+
+var fractionRunes = map[string]float64{"½": 0.5, "¼": 0.25, "¾": 0.75, "⅓": 1.0 / 3, "⅔": 2.0 / 3}
+
+// numberValue returns the value of a number, e.g. "2", "1.5", "1,5", "1/2"
+// or "½"
+func numberValue(word string) (float64, bool) {
+	if value, ok := fractionRunes[word]; ok {
+		return value, true
+	}
+	if numerator, denominator, ok := strings.Cut(word, "/"); ok {
+		n, err1 := strconv.ParseFloat(numerator, 64)
+		d, err2 := strconv.ParseFloat(denominator, 64)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, false
+		}
+		return n / d, true
+	}
+	value, err := strconv.ParseFloat(strings.Replace(word, ",", ".", 1), 64)
+	return value, err == nil
+}
+
+// startsQuantity tells whether a token may start a quantity
+func startsQuantity(t token) bool {
+	_, isNumber := numberValue(t.word)
+	_, isWord := quantityWords[t.word]
+	return isNumber || isWord
+}
+
+// parseQuantity reads the quantity at the start of tokens, e.g. "1 1/2",
+// "half a", "2 dozen" or "one and a half", and returns it with the number of
+// tokens read, 0 when there is none
+func parseQuantity(tokens []token) (float64, int) {
+	quantity, n := 0.0, 0
+	for n < len(tokens) {
+		word := tokens[n].word
+		if value, ok := numberValue(word); ok {
+			switch {
+			case n == 0:
+				quantity = value
+			case value < 1:
+				// A fraction after a number, e.g. "1 1/2"
+				quantity += value
+			default:
+				return quantity, n
+			}
+			n++
+			continue
+		}
+		if value, ok := quantityWords[word]; ok {
+			if n == 0 {
+				quantity = value
+			} else {
+				quantity *= value
+			}
+			n++
+			continue
+		}
+		if word == "and" && n > 0 {
+			if rest, m := parseQuantity(tokens[n+1:]); m > 0 && rest < 1 {
+				return quantity + rest, n + 1 + m
+			}
+		}
+		return quantity, n
+	}
+	return quantity, n
+}
+
+// quickLogMeals are the words naming a meal
+var quickLogMeals = map[string]models.MealType{
+	"breakfast": models.Breakfast,
+	"brunch":    models.Lunch,
+	"lunch":     models.Lunch,
+	"dinner":    models.Dinner,
+	"supper":    models.Dinner,
+	"snack":     models.Break,
+	"snacks":    models.Break,
+	"break":     models.Break,
+}
+
+// quickLogDays are the phrases naming a day before today, longest first,
+// with the meal some of them imply
+var quickLogDays = []struct {
+	words []string
+	days  int
+	meal  models.MealType
+}{
+	{[]string{"the", "day", "before", "yesterday"}, 2, ""},
+	{[]string{"day", "before", "yesterday"}, 2, ""},
+	{[]string{"yesterday", "morning"}, 1, models.Breakfast},
+	{[]string{"yesterday", "evening"}, 1, models.Dinner},
+	{[]string{"yesterday", "night"}, 1, models.Dinner},
+	{[]string{"last", "night"}, 1, models.Dinner},
+	{[]string{"yesterday"}, 1, ""},
+	{[]string{"this", "morning"}, 0, models.Breakfast},
+	{[]string{"this", "evening"}, 0, models.Dinner},
+	{[]string{"tonight"}, 0, models.Dinner},
+	{[]string{"today"}, 0, ""},
+}
+
+// quickLogFillers are the words a sentence may start with, e.g. "I had"
+var quickLogFillers = []string{"i", "just", "ate", "had", "have", "eaten", "drank", "log"}
+
+// quickLogSeparators are the words and signs between the foods of a list;
+// the signs always separate two foods, the words only before a quantity
+var quickLogSeparators = []string{",", ";", ":", "&", "+", "and", "plus", "with"}
+
+// isSign tells whether a separator is a sign rather than a word
+func isSign(separator token) bool {
+	return !unicode.IsLetter([]rune(separator.word)[0])
+}
+
+// ParseQuickLog reads a sentence telling what was eaten, e.g. "150g chicken
+// breast and 1 cup rice for lunch yesterday", with simple rules: foods are
+// listed with their quantities, the day is today unless it is named
+// ("yesterday", "3 days ago", "on monday", "2024-03-01") and the meal is
+// guessed from the time of day unless it is named ("for lunch"). Days and
+// hours are those of now, the user's local time.
+func ParseQuickLog(text string, now time.Time) (QuickLogSentence, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	sentence := QuickLogSentence{Date: today}
+	tokens := tokenize(text)
+	for len(tokens) > 0 && slices.Contains(quickLogFillers, tokens[0].word) {
+		tokens = tokens[1:]
+	}
+
+	// The day first, since it may name the meal too, e.g. "last night"
+	var impliedMeal models.MealType
+	var rest []token
+	for i := 0; i < len(tokens); {
+		date, meal, n := parseQuickLogDay(tokens[i:], today)
+		if n == 0 {
+			rest = append(rest, tokens[i])
+			i++
+			continue
+		}
+		// "on monday", "last friday", the latter being a week ago on a friday
+		if len(rest) > 0 && rest[len(rest)-1].word == "on" {
+			rest = rest[:len(rest)-1]
+		} else if len(rest) > 0 && rest[len(rest)-1].word == "last" {
+			rest = rest[:len(rest)-1]
+			if date.Equal(today) {
+				date = date.AddDate(0, 0, -7)
+			}
+		}
+		sentence.Date, impliedMeal = date, meal
+		i += n
+	}
+	tokens, rest = rest, nil
+
+	for i, t := range tokens {
+		meal, ok := quickLogMeals[t.word]
+		if !ok || !isMealWord(tokens, i) {
+			rest = append(rest, t)
+			continue
+		}
+		if len(rest) > 0 && slices.Contains([]string{"my", "the", "a"}, rest[len(rest)-1].word) {
+			rest = rest[:len(rest)-1]
+		}
+		if len(rest) > 0 && slices.Contains([]string{"for", "at", "as", "during"}, rest[len(rest)-1].word) {
+			rest = rest[:len(rest)-1]
+		}
+		sentence.Meal = meal
+	}
+	tokens = rest
+
+	switch {
+	case sentence.Meal != "":
+	case impliedMeal != "":
+		sentence.Meal = impliedMeal
+	default:
+		sentence.Meal, sentence.MealGuessed = mealOfHour(now.Hour()), true
+	}
+
+	for _, item := range splitQuickLogItems(tokens) {
+		if item, ok := parseQuickLogItem(text, item); ok {
+			sentence.Items = append(sentence.Items, item)
+		}
+	}
+	if len(sentence.Items) == 0 {
+		return sentence, fmt.Errorf("%w: no food named", ErrInvalidQuickLog)
+	}
+	return sentence, nil
+}
+
+// isMealWord tells whether the meal word at i of tokens names the meal. It
+// does when it follows a preposition or ends the sentence, as in "rice for
+// lunch" or "as a snack" but not "2 breakfast sausages". Starting the
+// sentence, it does when a colon follows it, as in "lunch: rice", or when no
+// later word names the meal, so that "breakfast burrito for dinner" keeps
+// its food.
+func isMealWord(tokens []token, i int) bool {
+	switch {
+	case i == len(tokens)-1:
+		return true
+	case i > 1 && tokens[i-2].word == "as" && tokens[i-1].word == "a":
+		return true
+	case i > 0:
+		return slices.Contains([]string{"for", "at", "as", "during", "my", "the"}, tokens[i-1].word)
+	case tokens[1].word == ":":
+		return true
+	}
+	for j := 1; j < len(tokens); j++ {
+		if _, ok := quickLogMeals[tokens[j].word]; ok && isMealWord(tokens, j) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseQuickLogDay reads a day at the start of tokens and returns it, with
+// the meal it implies if any and the number of tokens read, 0 when they
+// don't start with a day
+func parseQuickLogDay(tokens []token, today time.Time) (time.Time, models.MealType, int) {
+	for _, day := range quickLogDays {
+		if len(tokens) >= len(day.words) && slices.EqualFunc(tokens[:len(day.words)], day.words, func(t token, word string) bool {
+			return t.word == word
+		}) {
+			return today.AddDate(0, 0, -day.days), day.meal, len(day.words)
+		}
+	}
+
+	if date, err := time.Parse("2006-01-02", tokens[0].word); err == nil {
+		return date, "", 1
+	}
+
+	// "3 days ago", "two days ago"
+	if days, n := parseQuantity(tokens); n > 0 && len(tokens) >= n+2 && days == math.Trunc(days) &&
+		(tokens[n].word == "days" || tokens[n].word == "day") && tokens[n+1].word == "ago" {
+		return today.AddDate(0, 0, -int(days)), "", n + 2
+	}
+
+	// A weekday is the last one up to today
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if tokens[0].word == strings.ToLower(weekday.String()) {
+			days := (int(today.Weekday()) - int(weekday) + 7) % 7
+			return today.AddDate(0, 0, -days), "", 1
+		}
+	}
+	return time.Time{}, "", 0
+}
+
+// mealOfHour returns the meal usually eaten at an hour of the day
+func mealOfHour(hour int) models.MealType {
+	switch {
+	case hour < 11:
+		return models.Breakfast
+	case hour < 15:
+		return models.Lunch
+	case hour < 18:
+		return models.Break
+	}
+	return models.Dinner
+}
+
+// splitQuickLogItems splits a list of foods on its signs, and on its words
+// when they are followed by a quantity, so that "mac and cheese" stays a
+// food but "rice, toast and 2 eggs" are three
+func splitQuickLogItems(tokens []token) [][]token {
+	var items [][]token
+	var item []token
+	for i := 0; i < len(tokens); i++ {
+		if !slices.Contains(quickLogSeparators, tokens[i].word) {
+			item = append(item, tokens[i])
+			continue
+		}
+		next := i
+		for next < len(tokens) && slices.Contains(quickLogSeparators, tokens[next].word) {
+			next++
+		}
+		// A word within a quantity, e.g. "one and a half cups", or before
+		// something else than a quantity is part of the food
+		_, n := parseQuantity(item)
+		if isSign(tokens[i]) || (next < len(tokens) && startsQuantity(tokens[next]) && n < len(item)) {
+			items = append(items, item)
+			item = nil
+			i = next - 1
+			continue
+		}
+		item = append(item, tokens[i])
+	}
+	return append(items, item)
+}
+
+// parseQuickLogItem reads a food of the sentence text with its quantity,
+// e.g. "150g chicken breast", "a slice of bread" or "2 x egg"
+func parseQuickLogItem(text string, tokens []token) (QuickLogItem, bool) {
+	for len(tokens) > 0 && slices.Contains(quickLogSeparators, tokens[len(tokens)-1].word) {
+		tokens = tokens[:len(tokens)-1]
+	}
+	for len(tokens) > 0 && slices.Contains(quickLogSeparators, tokens[0].word) {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return QuickLogItem{}, false
+	}
+	item := QuickLogItem{Text: text[tokens[0].start:tokens[len(tokens)-1].end]}
+
+	quantity, n := parseQuantity(tokens)
+	item.Quantity, item.HasQuantity = quantity, n > 0
+	if !item.HasQuantity {
+		item.Quantity = 1
+	}
+	food := tokens[n:]
+	if n > 0 && len(food) > 1 && food[0].word == "x" {
+		food = food[1:]
+	}
+	// The unit is a word of the food when nothing follows it, e.g. "2 cups"
+	if len(food) > 1 {
+		if unit, ok := findQuickLogUnit(food[0].word); ok {
+			item.Unit, food = unit.name(), food[1:]
+		}
+	}
+	for len(food) > 1 && slices.Contains([]string{"of", "the", "some", "my"}, food[0].word) {
+		food = food[1:]
+	}
+	item.Food = joinTokens(food)
+	return item, item.Food != ""
+}
+
+// QuickLog logs the items of a sentence in the user's meal of its day and
+// type, creating the meal if there is none. Items are matched by name to the
+// stored foods, those the user logs often first; the others are reported
+// and not logged. A food already in the meal has its quantity added to, up
+// to maxImportedGrams in all. When report.DryRun is set, the report is
+// filled without saving anything.
+func QuickLog(db *gorm.DB, userID uint, sentence QuickLogSentence, report *models.QuickLog) error {
+	report.Date, report.MealType, report.MealGuessed = sentence.Date, sentence.Meal, sentence.MealGuessed
+	report.Entries = []models.QuickLogEntry{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var meal *importedMeal
+		for _, item := range sentence.Items {
+			entry := models.QuickLogEntry{Text: item.Text, Quantity: item.Quantity, Unit: item.Unit, Food: item.Food}
+			food, found, err := quickLogFood(tx, userID, item.Food)
+			if err != nil {
+				return err
+			}
+			if !found {
+				entry.Note = "no food matches, not logged"
+				report.Entries = append(report.Entries, entry)
+				continue
+			}
+			entry.FdcID, entry.Name = food.FdcID, food.Name
+
+			var portions []models.FoodPortion
+			if err := tx.Where("food_id = ?", food.ID).Order("id").Find(&portions).Error; err != nil {
+				return err
+			}
+			// Branded foods have a household serving instead, as in their
+			// detail
+			if len(portions) == 0 && food.HouseholdServing != "" && food.ServingSize > 0 && isGrams(food.ServingSizeUnit) {
+				portions = append(portions, models.FoodPortion{Description: food.HouseholdServing, GramWeight: food.ServingSize})
+			}
+			entry.Grams, entry.Measure, entry.Note = quickLogWeight(item, food, portions)
+			entry.Nutrients = models.Nutrients{
+				Calories: food.Calories,
+				Protein:  food.Protein,
+				Carbs:    food.Carbs,
+				Fat:      food.Fat,
+				Fiber:    food.Fiber,
+			}.Scale(entry.Grams / 100)
+			// A quantity rounding to nothing, e.g. "0g rice" or "0.01g
+			// rice", is no food eaten
+			if entry.Grams <= 0 {
+				entry.Note = "quantity of 0 g, not logged"
+				report.Entries = append(report.Entries, entry)
+				continue
+			}
+			if entry.Grams > maxImportedGrams {
+				entry.Note = fmt.Sprintf("quantity over %d g, not logged", maxImportedGrams)
+				report.Entries = append(report.Entries, entry)
+				continue
+			}
+
+			if meal == nil {
+				if meal, _, err = importMeal(tx, userID, sentence.Date, sentence.Meal); err != nil {
+					return err
+				}
+			}
+			added, merged := meal.added[food.ID]
+			total := meal.logged[food.ID] + entry.Grams
+			if merged {
+				total += added.Grams
+			}
+			if total > maxImportedGrams {
+				entry.Note = fmt.Sprintf("quantity over %d g in the meal, not logged", maxImportedGrams)
+				report.Entries = append(report.Entries, entry)
+				continue
+			}
+			if merged {
+				added.Grams += entry.Grams
+				added.Measure = strings.Trim(added.Measure+" + "+entry.Measure, " +")
+			} else {
+				meal.added[food.ID] = &models.MealFood{MealID: meal.id, FoodID: food.ID, Grams: entry.Grams, Measure: entry.Measure}
+				meal.order = append(meal.order, food.ID)
+			}
+			report.Logged++
+			report.Totals.Add(entry.Nutrients)
+			report.Entries = append(report.Entries, entry)
+		}
+		if meal == nil {
+			return nil
+		}
+
+		for _, foodID := range meal.order {
+			added := meal.added[foodID]
+			if _, ok := meal.logged[foodID]; !ok {
+				if err := tx.Create(added).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			var logged models.MealFood
+			if err := tx.Where("meal_id = ? AND food_id = ?", meal.id, foodID).First(&logged).Error; err != nil {
+				return err
+			}
+			err := tx.Model(&models.MealFood{}).Where("meal_id = ? AND food_id = ?", meal.id, foodID).Updates(map[string]any{
+				"grams":   logged.Grams + added.Grams,
+				"measure": strings.Trim(logged.Measure+" + "+added.Measure, " +"),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if report.DryRun {
+			return errDryRun
+		}
+		report.MealID = meal.id
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// quickLogFood returns the stored food best matching a name for the user,
+// with the values they overrode, and whether there is one
+func quickLogFood(tx *gorm.DB, userID uint, name string) (models.Food, bool, error) {
+	foods, _, err := SearchLocalFoods(tx, FoodSearchQuery{Query: name, Page: 1, PageSize: 1}, userID)
+	if err != nil || len(foods) == 0 {
+		return models.Food{}, false, err
+	}
+	food := foods[0]
+
+	var override models.FoodOverride
+	result := tx.Where("user_id = ? AND food_id = ?", userID, food.ID).Limit(1).Find(&override)
+	if result.Error != nil {
+		return food, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		override.Apply(&food)
+	}
+	return food, true, nil
+}
+
+// quickLogWeight returns the grams an item stands for, with the household
+// measure to log and a note telling how the weight was estimated when the
+// food has no portion in the item's unit
+func quickLogWeight(item QuickLogItem, food models.Food, portions []models.FoodPortion) (float64, string, string) {
+	quantity := item.Quantity
+	round := func(grams float64) float64 {
+		return math.Round(grams*10) / 10
+	}
+
+	unit, ok := findQuickLogUnit(item.Unit)
+	switch {
+	case ok && unit.grams > 0:
+		grams := round(quantity * unit.grams)
+		if unit.name() == "g" {
+			return grams, "", ""
+		}
+		note := ""
+		if unit.liquid {
+			note = "weighed as water"
+		}
+		return grams, formatQuantity(quantity) + " " + unit.name(), note
+
+	case ok:
+		if portion, found := findPortion(portions, unit.words); found {
+			servings := quantity / portionAmount(portion)
+			return round(servings * portion.GramWeight), portionMeasure(servings, portion.Description), ""
+		}
+		switch {
+		case unit.name() == "serving" && food.ServingSize > 0 && isGrams(food.ServingSizeUnit):
+			return round(quantity * food.ServingSize), portionMeasure(quantity, "1 serving"), ""
+		case unit.volume > 0:
+			return round(quantity * unit.volume), portionMeasure(quantity, "1 "+unit.name()),
+				fmt.Sprintf("no %s measure for this food, weighed as water: %g g a %s", unit.name(), unit.volume, unit.name())
+		}
+		return round(quantity * 100), portionMeasure(quantity, "1 "+unit.name()),
+			fmt.Sprintf("no %s measure for this food, 100 g a %s assumed", unit.name(), unit.name())
+	}
+
+	// A number of items is a number of the food's first portion which is
+	// not a volume or a weight, e.g. "1 large"
+	if portion, found := findPortion(portions, nil); found {
+		servings := quantity / portionAmount(portion)
+		return round(servings * portion.GramWeight), portionMeasure(servings, portion.Description), ""
+	}
+	if food.ServingSize > 0 && isGrams(food.ServingSizeUnit) {
+		return round(quantity * food.ServingSize), portionMeasure(quantity, "1 serving"), ""
+	}
+	if !item.HasQuantity {
+		return 100, "", "no quantity given, 100 g assumed"
+	}
+	return round(quantity * 100), "", "no portion for this food, 100 g each assumed"
+}
+
+// findPortion returns the first portion whose description has one of the
+// words, or when words is nil, the first one which is not a measure of
+// volume or weight
+func findPortion(portions []models.FoodPortion, words []string) (models.FoodPortion, bool) {
+	for _, portion := range portions {
+		measured := false
+		for _, t := range tokenize(portion.Description) {
+			unit, ok := findQuickLogUnit(t.word)
+			switch {
+			case words != nil && slices.Contains(words, t.word):
+				return portion, true
+			case ok && (unit.grams > 0 || unit.volume > 0):
+				measured = true
+			}
+		}
+		if words == nil && !measured {
+			return portion, true
+		}
+	}
+	return models.FoodPortion{}, false
+}
+
+// portionAmount returns the number of units a portion is, e.g. 0.25 for
+// "1/4 cup", 1 when it gives none
+func portionAmount(portion models.FoodPortion) float64 {
+	if amount, n := parseQuantity(tokenize(portion.Description)); n > 0 && amount > 0 {
+		return amount
+	}
+	return 1
+}
+
+// portionMeasure returns the measure of servings of a portion, as for a
+// food added to a meal, e.g. "2 x 1 cup"
+func portionMeasure(servings float64, description string) string {
+	if servings == 1 {
+		return description
+	}
+	return formatQuantity(servings) + " x " + description
+}
+
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(math.Round(quantity*100)/100, 'f', -1, 64)
+}
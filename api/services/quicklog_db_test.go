@@ -0,0 +1,67 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+)
+
+func TestQuickLogTwice(t *testing.T) {
+	db := newTestDB(t)
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Age: 36, Weight: 60, Height: 165}
+	rice := models.Food{FdcID: "169756", Name: "Rice, white, raw", DataType: "SR Legacy", Calories: 365}
+	create(t, db, &user, &rice)
+	create(t, db, &models.FoodPortion{FoodID: rice.ID, Description: "1 cup", GramWeight: 185})
+	now := time.Date(2024, time.March, 13, 12, 30, 0, 0, time.UTC)
+
+	quickLog := func(text string) models.QuickLog {
+		t.Helper()
+		sentence, err := services.ParseQuickLog(text, now)
+		if err != nil {
+			t.Fatalf("ParseQuickLog(%q): %v", text, err)
+		}
+		report := models.QuickLog{Text: text}
+		if err := services.QuickLog(db, user.ID, sentence, &report); err != nil {
+			t.Fatalf("QuickLog(%q): %v", text, err)
+		}
+		return report
+	}
+	logged := func() models.MealFood {
+		t.Helper()
+		var meals int64
+		db.Model(&models.Meal{}).Where("user_id = ?", user.ID).Count(&meals)
+		var foods []models.MealFood
+		if err := db.Where("food_id = ?", rice.ID).Find(&foods).Error; err != nil {
+			t.Fatalf("Failed to load the meal: %v", err)
+		}
+		if meals != 1 || len(foods) != 1 {
+			t.Fatalf("got %d meals and %d lines of rice, want the rice in a single meal", meals, len(foods))
+		}
+		return foods[0]
+	}
+
+	// Logging the same sentence twice adds to the food of the meal
+	for range 2 {
+		if report := quickLog("1 cup rice for lunch"); report.Logged != 1 || report.Entries[0].Grams != 185 {
+			t.Fatalf("logged %d entries %+v, want 185 g of rice", report.Logged, report.Entries)
+		}
+	}
+	if got := logged(); got.Grams != 370 || got.Measure != "1 cup + 1 cup" {
+		t.Errorf("rice in the meal = %g g (%q), want 370 g (\"1 cup + 1 cup\")", got.Grams, got.Measure)
+	}
+
+	// Up to 10000 g in all
+	report := quickLog("53 cups rice for lunch")
+	if report.Logged != 0 || report.Entries[0].Note != "quantity over 10000 g in the meal, not logged" {
+		t.Errorf("logged %d entries %+v, want the rice over 10000 g in the meal not logged", report.Logged, report.Entries)
+	}
+	report = quickLog("20 cups rice and 40 cups rice for lunch")
+	if report.Logged != 1 || report.Entries[1].Note == "" {
+		t.Errorf("logged %d entries %+v, want the second quantity over 10000 g not logged", report.Logged, report.Entries)
+	}
+	if got := logged(); got.Grams != 4070 {
+		t.Errorf("rice in the meal = %g g, want 4070 g", got.Grams)
+	}
+}
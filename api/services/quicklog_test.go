@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+)
+
+func TestParseQuickLog(t *testing.T) {
+	// A wednesday, at lunch time
+	now := time.Date(2024, time.March, 13, 12, 30, 0, 0, time.UTC)
+	day := func(daysAgo int) time.Time {
+		return time.Date(2024, time.March, 13-daysAgo, 0, 0, 0, 0, time.UTC)
+	}
+
+	type item struct {
+		quantity    float64
+		hasQuantity bool
+		unit        string
+		food        string
+	}
+	tests := []struct {
+		text        string
+		date        time.Time
+		meal        models.MealType
+		mealGuessed bool
+		items       []item
+	}{
+		{
+			text:  "150g chicken breast and 1 cup rice for lunch yesterday",
+			date:  day(1),
+			meal:  models.Lunch,
+			items: []item{{150, true, "g", "chicken breast"}, {1, true, "cup", "rice"}},
+		},
+		{
+			text:  "2 eggs, toast and 1 banana last night",
+			date:  day(1),
+			meal:  models.Dinner,
+			items: []item{{2, true, "", "eggs"}, {1, false, "", "toast"}, {1, true, "", "banana"}},
+		},
+		{
+			text:  "rice; beans + corn & salsa",
+			date:  day(0),
+			meal:  models.Lunch,
+			items: []item{{1, false, "", "rice"}, {1, false, "", "beans"}, {1, false, "", "corn"}, {1, false, "", "salsa"}},
+			// The meal comes from the time of day
+			mealGuessed: true,
+		},
+		{
+			text:  "mac and cheese for dinner",
+			date:  day(0),
+			meal:  models.Dinner,
+			items: []item{{1, false, "", "mac and cheese"}},
+		},
+		{
+			text:  "coffee with milk and 2 cookies",
+			date:  day(0),
+			meal:  models.Lunch,
+			items: []item{{1, false, "", "coffee with milk"}, {2, true, "", "cookies"}},
+			// The meal comes from the time of day
+			mealGuessed: true,
+		},
+		{
+			text:  "breakfast burrito for dinner",
+			date:  day(0),
+			meal:  models.Dinner,
+			items: []item{{1, false, "", "breakfast burrito"}},
+		},
+		{
+			text:  "breakfast burrito",
+			date:  day(0),
+			meal:  models.Breakfast,
+			items: []item{{1, false, "", "burrito"}},
+		},
+		{
+			text:  "lunch: 200 g salmon",
+			date:  day(0),
+			meal:  models.Lunch,
+			items: []item{{200, true, "g", "salmon"}},
+		},
+		{
+			text:  "2 breakfast sausages at my breakfast",
+			date:  day(0),
+			meal:  models.Breakfast,
+			items: []item{{2, true, "", "breakfast sausages"}},
+		},
+		{
+			text:  "I had one and a half cups of rice",
+			date:  day(0),
+			meal:  models.Lunch,
+			items: []item{{1.5, true, "cup", "rice"}},
+			// The meal comes from the time of day
+			mealGuessed: true,
+		},
+		{
+			text:  "half a cup of orange juice this morning",
+			date:  day(0),
+			meal:  models.Breakfast,
+			items: []item{{0.5, true, "cup", "orange juice"}},
+		},
+		{
+			text:  "1 1/2 slices of bread and ½ avocado",
+			date:  day(0),
+			meal:  models.Lunch,
+			items: []item{{1.5, true, "slice", "bread"}, {0.5, true, "", "avocado"}},
+			// The meal comes from the time of day
+			mealGuessed: true,
+		},
+		{
+			text:  "2 x banana as a snack 3 days ago",
+			date:  day(3),
+			meal:  models.Break,
+			items: []item{{2, true, "", "banana"}},
+		},
+		{
+			text:  "2 cups",
+			date:  day(0),
+			meal:  models.Lunch,
+			items: []item{{2, true, "", "cups"}},
+			// The meal comes from the time of day
+			mealGuessed: true,
+		},
+		{
+			text:  "0g rice for dinner",
+			date:  day(0),
+			meal:  models.Dinner,
+			items: []item{{0, true, "g", "rice"}},
+		},
+		{
+			text:  "oatmeal on monday",
+			date:  day(2),
+			meal:  models.Lunch,
+			items: []item{{1, false, "", "oatmeal"}},
+			// The meal comes from the time of day
+			mealGuessed: true,
+		},
+		{
+			text:  "pizza last wednesday for dinner",
+			date:  day(7),
+			meal:  models.Dinner,
+			items: []item{{1, false, "", "pizza"}},
+		},
+		{
+			text:  "soup for supper 2024-03-01",
+			date:  time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+			meal:  models.Dinner,
+			items: []item{{1, false, "", "soup"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			sentence, err := ParseQuickLog(test.text, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !sentence.Date.Equal(test.date) {
+				t.Errorf("date = %s, want %s", sentence.Date.Format(time.DateOnly), test.date.Format(time.DateOnly))
+			}
+			if sentence.Meal != test.meal || sentence.MealGuessed != test.mealGuessed {
+				t.Errorf("meal = %s (guessed %t), want %s (guessed %t)", sentence.Meal, sentence.MealGuessed, test.meal, test.mealGuessed)
+			}
+			if len(sentence.Items) != len(test.items) {
+				t.Fatalf("got %d items %+v, want %d", len(sentence.Items), sentence.Items, len(test.items))
+			}
+			for i, want := range test.items {
+				got := sentence.Items[i]
+				if got.Quantity != want.quantity || got.HasQuantity != want.hasQuantity || got.Unit != want.unit || got.Food != want.food {
+					t.Errorf("item %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseQuickLogNoFood(t *testing.T) {
+	now := time.Date(2024, time.March, 13, 12, 30, 0, 0, time.UTC)
+	for _, text := range []string{"", "for lunch yesterday", "I had, and"} {
+		if _, err := ParseQuickLog(text, now); !errors.Is(err, ErrInvalidQuickLog) {
+			t.Errorf("ParseQuickLog(%q) error = %v, want ErrInvalidQuickLog", text, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+)
+
+// DefaultReportTolerance is the percentage of a target a day may miss it by
+// and still adhere to it when a report doesn't tell
+const DefaultReportTolerance = 10
+
+// ErrInvalidPeriod is returned when a report's week or month is malformed
+var ErrInvalidPeriod = errors.New("invalid report period")
+
+// ReportPeriod resolves the label of a weekly report, an ISO week such as
+// 2024-W10, or of a monthly one, such as 2024-03, to its first and last
+// days. An empty label is the period of now.
+func ReportPeriod(period, label string, now time.Time) (string, time.Time, time.Time, error) {
+	switch period {
+	case models.ReportWeekly:
+		if label == "" {
+			year, week := now.ISOWeek()
+			label = fmt.Sprintf("%d-W%02d", year, week)
+		}
+		var year, week int
+		if n, err := fmt.Sscanf(label, "%4d-W%2d", &year, &week); err != nil || n != 2 || len(label) != 8 {
+			return "", time.Time{}, time.Time{}, ErrInvalidPeriod
+		}
+		monday := isoWeekMonday(year, week)
+		if y, w := monday.ISOWeek(); y != year || w != week {
+			return "", time.Time{}, time.Time{}, ErrInvalidPeriod
+		}
+		return label, monday, monday.AddDate(0, 0, 6), nil
+
+	case models.ReportMonthly:
+		if label == "" {
+			label = now.Format("2006-01")
+		}
+		first, err := time.Parse("2006-01", label)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, ErrInvalidPeriod
+		}
+		return label, first, first.AddDate(0, 1, -1), nil
+	}
+	return "", time.Time{}, time.Time{}, ErrInvalidPeriod
+}
+
+// isoWeekMonday returns the Monday of the ISO week of year, the week of
+// January 4th being the first one
+func isoWeekMonday(year, week int) time.Time {
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	return jan4.AddDate(0, 0, (week-1)*7-offset)
+}
+
+// NutritionReport builds the user's report of the period between from and
+// to, both inclusive days, its days stopping at today. The adherence is
+// computed with tolerance, a percentage of the targets.
+func NutritionReport(db *gorm.DB, userID uint, period, label string, from, to, today time.Time, tolerance float64) (*models.NutritionReport, error) {
+	report := &models.NutritionReport{
+		Period:    period,
+		Label:     label,
+		From:      from,
+		To:        to,
+		Days:      []models.ReportDay{},
+		Tolerance: tolerance,
+	}
+	last := to
+	if today = truncateDay(today); today.Before(last) {
+		last = today
+	}
+
+	var targets []models.Target
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	if len(targets) > 0 {
+		t := targets[0]
+		report.Targets = &models.Nutrients{Calories: t.Calories, Protein: t.Protein, Carbs: t.Carbs, Fat: t.Fat, Fiber: t.Fiber}
+		report.Adherence = &models.ReportAdherence{}
+	}
+
+	if !last.Before(from) {
+		entries, err := MealEntries(db, userID, from, last)
+		if err != nil {
+			return nil, err
+		}
+		for _, totals := range DailyTotals(entries, from, last) {
+			day := models.ReportDay{Date: totals.Date, Meals: totals.Meals, Entries: totals.Entries, Nutrients: totals.Nutrients}
+			if day.Meals > 0 {
+				addLoggedDay(report, &day)
+			}
+			report.Days = append(report.Days, day)
+		}
+	}
+	for i, day := range report.Days {
+		if day.Deviation == nil {
+			continue
+		}
+		if report.BestDay == nil || *day.Deviation < *report.BestDay.Deviation {
+			report.BestDay = &report.Days[i]
+		}
+		if report.WorstDay == nil || *day.Deviation > *report.WorstDay.Deviation {
+			report.WorstDay = &report.Days[i]
+		}
+	}
+	if len(report.Days) > 0 {
+		report.Completeness = float64(report.LoggedDays) / float64(len(report.Days)) * 100
+	}
+	if report.LoggedDays > 0 {
+		report.Averages = report.Totals.Scale(1 / float64(report.LoggedDays))
+	}
+	report.MacroSplit = macroSplit(report.Totals)
+
+	weight, err := weightChange(db, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report.Weight = weight
+	return report, nil
+}
+
+// addLoggedDay counts a day with meals in the report's totals and, when the
+// user has targets, in the adherence, setting the day's deviation from them
+func addLoggedDay(report *models.NutritionReport, day *models.ReportDay) {
+	report.LoggedDays++
+	report.Meals += day.Meals
+	report.Entries += day.Entries
+	report.Totals.Add(day.Nutrients)
+	if report.Targets == nil {
+		return
+	}
+
+	all, deviations, deviation := true, 0, 0.0
+	for _, nutrient := range []struct {
+		eaten, target float64
+		days          *int
+	}{
+		{day.Calories, report.Targets.Calories, &report.Adherence.Calories},
+		{day.Protein, report.Targets.Protein, &report.Adherence.Protein},
+		{day.Carbs, report.Targets.Carbs, &report.Adherence.Carbs},
+		{day.Fat, report.Targets.Fat, &report.Adherence.Fat},
+		{day.Fiber, report.Targets.Fiber, &report.Adherence.Fiber},
+	} {
+		// A target of 0 is no target
+		if nutrient.target <= 0 {
+			continue
+		}
+		missed := math.Abs(nutrient.eaten-nutrient.target) / nutrient.target * 100
+		if missed <= report.Tolerance {
+			*nutrient.days++
+		} else {
+			all = false
+		}
+		deviation += missed
+		deviations++
+	}
+	if deviations == 0 {
+		return
+	}
+	if all {
+		report.Adherence.All++
+	}
+	deviation /= float64(deviations)
+	day.Deviation = &deviation
+}
+
+// macroSplit returns the share of the calories of the protein and carbs, 4
+// kcal per gram, and of the fat, 9 kcal per gram
+func macroSplit(totals models.Nutrients) models.MacroSplit {
+	protein, carbs, fat := totals.Protein*4, totals.Carbs*4, totals.Fat*9
+	sum := protein + carbs + fat
+	if sum == 0 {
+		return models.MacroSplit{}
+	}
+	return models.MacroSplit{Protein: protein / sum * 100, Carbs: carbs / sum * 100, Fat: fat / sum * 100}
+}
+
+// weightChange returns the change between the user's first and last weight
+// records from from to to, nil without records
+func weightChange(db *gorm.DB, userID uint, from, to time.Time) (*models.WeightChange, error) {
+	var records []models.WeightRecord
+	if err := db.Where("user_id = ? AND date >= ? AND date < ?", userID, from, to.AddDate(0, 0, 1)).
+		Order("date, id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	first, last := records[0], records[len(records)-1]
+	return &models.WeightChange{
+		Start:     first.Weight,
+		End:       last.Weight,
+		Change:    last.Weight - first.Weight,
+		StartDate: first.Date,
+		EndDate:   last.Date,
+		Records:   len(records),
+	}, nil
+}
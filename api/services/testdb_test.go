@@ -0,0 +1,42 @@
+package services_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ZUHOWKS/my-body-tracker/api"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+var testDatabases atomic.Int32
+
+// newTestDB returns a migrated in-memory database of its own for a test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := fmt.Sprintf("file:test%d?mode=memory&cache=shared", testDatabases.Add(1))
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to open the in-memory database: %v", err)
+	}
+	if err := api.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// create stores values in the test's database without their associations,
+// failing the test on error
+func create(t *testing.T, db *gorm.DB, values ...any) {
+	t.Helper()
+	for _, value := range values {
+		if err := db.Omit(clause.Associations).Create(value).Error; err != nil {
+			t.Fatalf("Failed to create %T: %v", value, err)
+		}
+	}
+}
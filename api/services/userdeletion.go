@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+)
+
+// UserRetentionEnv names the variable setting the number of days a deleted
+// user can be restored before being purged
+const UserRetentionEnv = "USER_RETENTION_DAYS"
+
+// DefaultUserRetention is the retention of deleted users when
+// UserRetentionEnv is not set
+const DefaultUserRetention = 30 * 24 * time.Hour
+
+var (
+	// ErrUserNotDeleted is returned when restoring a user which isn't deleted
+	ErrUserNotDeleted = errors.New("user is not deleted")
+	// ErrRestoreExpired is returned when restoring a user deleted for longer
+	// than the retention
+	ErrRestoreExpired = errors.New("user was deleted too long ago to be restored")
+)
+
+// UserRetention returns how long deleted users can be restored, from
+// UserRetentionEnv
+func UserRetention() (time.Duration, error) {
+	value := os.Getenv(UserRetentionEnv)
+	if value == "" {
+		return DefaultUserRetention, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("%s must be a number of days, got %q", UserRetentionEnv, value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// userData are the models holding a user's data, soft-deleted and restored
+// with them. Custom foods are the foods with their user_id too. Favourite
+// foods have no deleted_at: they stay until the user is purged, and are
+// only read through a user who is not deleted.
+var userData = []any{&models.Meal{}, &models.Target{}, &models.WeightRecord{}, &models.FoodOverride{}, &models.Food{}}
+
+// DeleteUser soft-deletes the user and their data at the same time, so that
+// RestoreUser brings back what was deleted with them only. It returns the
+// deleted user, or gorm.ErrRecordNotFound when there is no such user.
+func DeleteUser(db *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, model := range userData {
+			if err := tx.Model(model).Where("user_id = ?", userID).UpdateColumn("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&user).UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+		user.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RestoreUser brings back a user deleted less than retention ago, with the
+// data deleted with them. It returns gorm.ErrRecordNotFound when there is no
+// such user, deleted or not.
+func RestoreUser(db *gorm.DB, userID uint, retention time.Duration) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().First(&user, userID).Error; err != nil {
+			return err
+		}
+		switch {
+		case !user.DeletedAt.Valid:
+			return ErrUserNotDeleted
+		case time.Since(user.DeletedAt.Time) > retention:
+			return ErrRestoreExpired
+		}
+
+		deletedAt := user.DeletedAt.Time
+		for _, model := range userData {
+			err := tx.Unscoped().Model(model).Where("user_id = ? AND deleted_at = ?", userID, deletedAt).
+				UpdateColumn("deleted_at", nil).Error
+			if err != nil {
+				return err
+			}
+		}
+		user.DeletedAt = gorm.DeletedAt{}
+		return tx.Unscoped().Model(&user).UpdateColumn("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PurgeDeletedUsers hard-deletes the users deleted before the given time,
+// with all their data, and returns how many there were
+func PurgeDeletedUsers(db *gorm.DB, before time.Time) (int, error) {
+	var ids []uint
+	err := db.Unscoped().Model(&models.User{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Order("id").Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		meals := tx.Model(&models.Meal{}).Select("id").Where("user_id IN ?", ids)
+		customFoods := tx.Model(&models.Food{}).Select("id").Where("user_id IN ?", ids)
+		// Children first, for the foreign keys
+		for _, purge := range []struct {
+			model any
+			query string
+			args  []any
+		}{
+			{&models.MealFood{}, "meal_id IN (?) OR food_id IN (?)", []any{meals, customFoods}},
+			{&models.Meal{}, "user_id IN ?", []any{ids}},
+			{&models.Target{}, "user_id IN ?", []any{ids}},
+			{&models.WeightRecord{}, "user_id IN ?", []any{ids}},
+			{&models.FoodOverride{}, "user_id IN ? OR food_id IN (?)", []any{ids, customFoods}},
+			{&models.FavoriteFood{}, "user_id IN ? OR food_id IN (?)", []any{ids, customFoods}},
+			{&models.FoodPortion{}, "food_id IN (?)", []any{customFoods}},
+			{&models.Food{}, "user_id IN ?", []any{ids}},
+			{&models.User{}, "id IN ?", []any{ids}},
+		} {
+			if err := tx.Where(purge.query, purge.args...).Delete(purge.model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// RunUserPurge purges the users deleted for longer than retention every
+// interval, until ctx is done
+func RunUserPurge(ctx context.Context, db *gorm.DB, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := PurgeDeletedUsers(db, time.Now().Add(-retention))
+		switch {
+		case err != nil:
+			log.Printf("Failed to purge deleted users: %v", err)
+		case purged > 0:
+			log.Printf("Purged %d user(s) deleted more than %d day(s) ago", purged, int(retention.Hours()/24))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package services_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"gorm.io/gorm"
+)
+
+// createUserData creates a user with a meal, targets, a weight, a custom
+// food with a portion, and an override and a favourite of the shared food
+func createUserData(t *testing.T, db *gorm.DB, name string, shared models.Food) models.User {
+	t.Helper()
+	user := models.User{FirstName: name, LastName: "Test", Age: 30, Weight: 70, Height: 175}
+	create(t, db, &user)
+	custom := models.Food{FdcID: "custom-" + name, Name: name + "'s soup", DataType: services.DataTypeCustom, Calories: 50, UserID: &user.ID}
+	meal := models.Meal{Type: models.Lunch, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), UserID: user.ID}
+	create(t, db, &custom, &meal)
+	calories := 99.0
+	create(t, db,
+		&models.MealFood{MealID: meal.ID, FoodID: shared.ID, Grams: 150},
+		&models.MealFood{MealID: meal.ID, FoodID: custom.ID, Grams: 300},
+		&models.FoodPortion{FoodID: custom.ID, Description: "1 bowl", GramWeight: 300},
+		&models.Target{UserID: user.ID, Calories: 2000},
+		&models.WeightRecord{UserID: user.ID, Weight: 70, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		&models.FoodOverride{UserID: user.ID, FoodID: shared.ID, Calories: &calories},
+		&models.FavoriteFood{UserID: user.ID, FoodID: shared.ID},
+		&models.FavoriteFood{UserID: user.ID, FoodID: custom.ID})
+	return user
+}
+
+// userRows counts the rows of the user's data in each table, deleted or not
+func userRows(t *testing.T, db *gorm.DB, userID uint) map[string]int64 {
+	t.Helper()
+	db = db.Unscoped().Session(&gorm.Session{})
+	meals := db.Model(&models.Meal{}).Select("id").Where("user_id = ?", userID)
+	customFoods := db.Model(&models.Food{}).Select("id").Where("user_id = ?", userID)
+	counts := map[string]int64{}
+	for name, query := range map[string]*gorm.DB{
+		"users":          db.Model(&models.User{}).Where("id = ?", userID),
+		"meals":          db.Model(&models.Meal{}).Where("user_id = ?", userID),
+		"meal foods":     db.Model(&models.MealFood{}).Where("meal_id IN (?)", meals),
+		"targets":        db.Model(&models.Target{}).Where("user_id = ?", userID),
+		"weight records": db.Model(&models.WeightRecord{}).Where("user_id = ?", userID),
+		"overrides":      db.Model(&models.FoodOverride{}).Where("user_id = ?", userID),
+		"favourites":     db.Model(&models.FavoriteFood{}).Where("user_id = ?", userID),
+		"custom foods":   db.Model(&models.Food{}).Where("user_id = ?", userID),
+		"portions":       db.Model(&models.FoodPortion{}).Where("food_id IN (?)", customFoods),
+	} {
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			t.Fatalf("Failed to count the %s: %v", name, err)
+		}
+		counts[name] = count
+	}
+	return counts
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	db := newTestDB(t)
+	rice := models.Food{FdcID: "169756", Name: "Rice", DataType: "SR Legacy", Calories: 365}
+	create(t, db, &rice)
+	now := time.Now()
+	retention := 30 * 24 * time.Hour
+
+	// deleted is how long ago a user was deleted, 0 if they weren't
+	tests := []struct {
+		name    string
+		deleted time.Duration
+		purged  bool
+		user    models.User
+	}{
+		{name: "active"},
+		{name: "recent", deleted: 29 * 24 * time.Hour},
+		{name: "expired", deleted: 31 * 24 * time.Hour, purged: true},
+	}
+	for i, test := range tests {
+		tests[i].user = createUserData(t, db, test.name, rice)
+		if test.deleted == 0 {
+			continue
+		}
+		if _, err := services.DeleteUser(db, tests[i].user.ID); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		err := db.Unscoped().Model(&tests[i].user).UpdateColumn("deleted_at", now.Add(-test.deleted)).Error
+		if err != nil {
+			t.Fatalf("Failed to date the deletion: %v", err)
+		}
+	}
+
+	purged, err := services.PurgeDeletedUsers(db, now.Add(-retention))
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedUsers = %d, %v, want 1 user purged", purged, err)
+	}
+	for _, test := range tests {
+		for table, count := range userRows(t, db, test.user.ID) {
+			if test.purged && count > 0 {
+				t.Errorf("%s user: %d %s left, want none", test.name, count, table)
+			}
+			if !test.purged && count == 0 {
+				t.Errorf("%s user: no %s left", test.name, table)
+			}
+		}
+	}
+	if err := db.First(&models.Food{}, rice.ID).Error; err != nil {
+		t.Errorf("the shared food was purged with the user: %v", err)
+	}
+
+	// The purged user can't be restored anymore, the recent one can
+	if _, err := services.RestoreUser(db, tests[2].user.ID, retention); err == nil {
+		t.Error("RestoreUser of the purged user succeeded")
+	}
+	if _, err := services.RestoreUser(db, tests[1].user.ID, retention); err != nil {
+		t.Errorf("RestoreUser of the user inside the retention: %v", err)
+	}
+}
+
+func TestUserRetention(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": services.DefaultUserRetention, "7": 7 * 24 * time.Hour, "0": 0} {
+		t.Run(fmt.Sprintf("%q", value), func(t *testing.T) {
+			t.Setenv(services.UserRetentionEnv, value)
+			if got, err := services.UserRetention(); err != nil || got != want {
+				t.Errorf("UserRetention() = %s, %v, want %s", got, err, want)
+			}
+		})
+	}
+	for _, value := range []string{"abc", "-1", "1.5"} {
+		t.Setenv(services.UserRetentionEnv, value)
+		if _, err := services.UserRetention(); err == nil {
+			t.Errorf("UserRetention() with %q: no error", value)
+		}
+	}
+}
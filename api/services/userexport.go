@@ -0,0 +1,393 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvalidExportError is returned when importing an export which can't be
+// restored, e.g. with a meal eating a food it doesn't list. Field is the
+// path of the faulty value in the export.
+type InvalidExportError struct {
+	Field   string
+	Message string
+}
+
+func (e *InvalidExportError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ExportUser returns all the data of the user: their profile, targets,
+// weight records, meals, custom foods, favourites and overrides, with the
+// foods they reference. It returns gorm.ErrRecordNotFound when there is no
+// such user.
+func ExportUser(db *gorm.DB, userID uint) (*models.UserExport, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	export := &models.UserExport{
+		Version:    models.UserExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Profile: models.ExportedProfile{
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			Age:           user.Age,
+			Weight:        user.Weight,
+			Height:        user.Height,
+			Goal:          user.Goal,
+			Sex:           user.Sex,
+			ActivityLevel: user.ActivityLevel,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+		},
+		WeightRecords: []models.ExportedWeightRecord{},
+		Meals:         []models.ExportedMeal{},
+		Foods:         []models.ExportedFood{},
+		Favorites:     []models.ExportedFavorite{},
+		Overrides:     []models.ExportedOverride{},
+	}
+
+	var targets []models.Target
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		export.Targets = &models.ExportedTargets{
+			Calories:  target.Calories,
+			Protein:   target.Protein,
+			Carbs:     target.Carbs,
+			Fat:       target.Fat,
+			Fiber:     target.Fiber,
+			CreatedAt: target.CreatedAt,
+			UpdatedAt: target.UpdatedAt,
+		}
+	}
+
+	var records []models.WeightRecord
+	if err := db.Where("user_id = ?", userID).Order("date, id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		export.WeightRecords = append(export.WeightRecords, models.ExportedWeightRecord{
+			Weight:    record.Weight,
+			Date:      record.Date,
+			Note:      record.Note,
+			CreatedAt: record.CreatedAt,
+		})
+	}
+
+	// The foods of the export are the user's custom foods and those their
+	// meals, favourites and overrides reference
+	var foods []models.Food
+	err := db.Unscoped().Preload("Portions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("user_id = ?", userID).
+		Or("id IN (?)", db.Table("meal_foods").Select("meal_foods.food_id").
+			Joins("JOIN meals ON meals.id = meal_foods.meal_id").
+			Where("meals.user_id = ? AND meals.deleted_at IS NULL", userID)).
+		Or("id IN (?)", db.Model(&models.FavoriteFood{}).Select("food_id").Where("user_id = ?", userID)).
+		Or("id IN (?)", db.Model(&models.FoodOverride{}).Select("food_id").Where("user_id = ?", userID)).
+		Order("id").Find(&foods).Error
+	if err != nil {
+		return nil, err
+	}
+	fdcIDs := make(map[uint]string, len(foods))
+	for _, food := range foods {
+		fdcIDs[food.ID] = food.FdcID
+		exported := models.ExportedFood{
+			FdcID:            food.FdcID,
+			Name:             food.Name,
+			DataType:         food.DataType,
+			BrandOwner:       food.BrandOwner,
+			Custom:           food.UserID != nil,
+			Protein:          food.Protein,
+			Carbs:            food.Carbs,
+			Fat:              food.Fat,
+			Calories:         food.Calories,
+			Fiber:            food.Fiber,
+			ServingSize:      food.ServingSize,
+			ServingSizeUnit:  food.ServingSizeUnit,
+			HouseholdServing: food.HouseholdServing,
+		}
+		for _, portion := range food.Portions {
+			exported.Portions = append(exported.Portions, models.ExportedPortion{
+				Description: portion.Description,
+				GramWeight:  portion.GramWeight,
+			})
+		}
+		export.Foods = append(export.Foods, exported)
+	}
+
+	var meals []models.Meal
+	if err := db.Where("user_id = ?", userID).Order("date, id").Find(&meals).Error; err != nil {
+		return nil, err
+	}
+	mealIDs := make([]uint, len(meals))
+	for i, meal := range meals {
+		mealIDs[i] = meal.ID
+	}
+	var entries []models.MealFood
+	if len(mealIDs) > 0 {
+		if err := db.Where("meal_id IN ?", mealIDs).Order("meal_id, food_id").Find(&entries).Error; err != nil {
+			return nil, err
+		}
+	}
+	mealFoods := map[uint][]models.ExportedMealFood{}
+	for _, entry := range entries {
+		mealFoods[entry.MealID] = append(mealFoods[entry.MealID], models.ExportedMealFood{
+			FdcID:   fdcIDs[entry.FoodID],
+			Grams:   entry.Grams,
+			Measure: entry.Measure,
+		})
+	}
+	for _, meal := range meals {
+		foods := mealFoods[meal.ID]
+		if foods == nil {
+			foods = []models.ExportedMealFood{}
+		}
+		export.Meals = append(export.Meals, models.ExportedMeal{
+			Type:      meal.Type,
+			Date:      meal.Date,
+			CreatedAt: meal.CreatedAt,
+			Foods:     foods,
+		})
+	}
+
+	var favorites []models.FavoriteFood
+	if err := db.Where("user_id = ?", userID).Order("created_at, food_id").Find(&favorites).Error; err != nil {
+		return nil, err
+	}
+	for _, favorite := range favorites {
+		export.Favorites = append(export.Favorites, models.ExportedFavorite{
+			FdcID:     fdcIDs[favorite.FoodID],
+			CreatedAt: favorite.CreatedAt,
+		})
+	}
+
+	var overrides []models.FoodOverride
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		export.Overrides = append(export.Overrides, models.ExportedOverride{
+			FdcID:       fdcIDs[override.FoodID],
+			Name:        override.Name,
+			Protein:     override.Protein,
+			Carbs:       override.Carbs,
+			Fat:         override.Fat,
+			Calories:    override.Calories,
+			Fiber:       override.Fiber,
+			ServingSize: override.ServingSize,
+			CreatedAt:   override.CreatedAt,
+			UpdatedAt:   override.UpdatedAt,
+		})
+	}
+	return export, nil
+}
+
+// ImportUser restores an export as a new user, keeping the dates of what it
+// holds. Shared foods the database lacks are created, and custom foods are
+// created for the new user. The export is expected to be valid by its
+// binding rules; an *InvalidExportError is returned when its values don't
+// fit together, and nothing is stored then.
+func ImportUser(db *gorm.DB, export *models.UserExport) (*models.User, error) {
+	if export.Version > models.UserExportVersion {
+		return nil, &InvalidExportError{Field: "version",
+			Message: fmt.Sprintf("must be at most %d, the export is from a newer version", models.UserExportVersion)}
+	}
+
+	profile := export.Profile
+	user := models.User{
+		Model:         gorm.Model{CreatedAt: profile.CreatedAt, UpdatedAt: profile.UpdatedAt},
+		FirstName:     profile.FirstName,
+		LastName:      profile.LastName,
+		Age:           profile.Age,
+		Weight:        profile.Weight,
+		Height:        profile.Height,
+		Goal:          profile.Goal,
+		Sex:           profile.Sex,
+		ActivityLevel: profile.ActivityLevel,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&user).Error; err != nil {
+			return err
+		}
+
+		foodIDs := make(map[string]uint, len(export.Foods))
+		for i, exported := range export.Foods {
+			id, err := importExportedFood(tx, user.ID, exported, fmt.Sprintf("foods[%d]", i))
+			if err != nil {
+				return err
+			}
+			foodIDs[exported.FdcID] = id
+		}
+		foodID := func(fdcID, field string) (uint, error) {
+			id, ok := foodIDs[fdcID]
+			if !ok {
+				return 0, &InvalidExportError{Field: field, Message: fmt.Sprintf("must be the fdcId of one of the foods, got %q", fdcID)}
+			}
+			return id, nil
+		}
+
+		if targets := export.Targets; targets != nil {
+			err := tx.Create(&models.Target{
+				Model:    gorm.Model{CreatedAt: targets.CreatedAt, UpdatedAt: targets.UpdatedAt},
+				UserID:   user.ID,
+				Calories: targets.Calories,
+				Protein:  targets.Protein,
+				Carbs:    targets.Carbs,
+				Fat:      targets.Fat,
+				Fiber:    targets.Fiber,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, record := range export.WeightRecords {
+			err := tx.Create(&models.WeightRecord{
+				Model:  gorm.Model{CreatedAt: record.CreatedAt, UpdatedAt: record.CreatedAt},
+				UserID: user.ID,
+				Weight: record.Weight,
+				Date:   record.Date,
+				Note:   record.Note,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for i, exported := range export.Meals {
+			meal := models.Meal{
+				Model:  gorm.Model{CreatedAt: exported.CreatedAt, UpdatedAt: exported.CreatedAt},
+				Type:   exported.Type,
+				Date:   exported.Date,
+				UserID: user.ID,
+			}
+			if err := tx.Omit(clause.Associations).Create(&meal).Error; err != nil {
+				return err
+			}
+			eaten := map[uint]bool{}
+			for j, food := range exported.Foods {
+				field := fmt.Sprintf("meals[%d].foods[%d].fdcId", i, j)
+				id, err := foodID(food.FdcID, field)
+				if err != nil {
+					return err
+				}
+				if eaten[id] {
+					return &InvalidExportError{Field: field, Message: "must not be twice in the meal"}
+				}
+				eaten[id] = true
+				err = tx.Create(&models.MealFood{MealID: meal.ID, FoodID: id, Grams: food.Grams, Measure: food.Measure}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		starred := map[uint]bool{}
+		for i, favorite := range export.Favorites {
+			id, err := foodID(favorite.FdcID, fmt.Sprintf("favorites[%d].fdcId", i))
+			if err != nil {
+				return err
+			}
+			if starred[id] {
+				continue
+			}
+			starred[id] = true
+			if err := tx.Create(&models.FavoriteFood{UserID: user.ID, FoodID: id, CreatedAt: favorite.CreatedAt}).Error; err != nil {
+				return err
+			}
+		}
+
+		overridden := map[uint]bool{}
+		for i, override := range export.Overrides {
+			field := fmt.Sprintf("overrides[%d].fdcId", i)
+			id, err := foodID(override.FdcID, field)
+			if err != nil {
+				return err
+			}
+			if overridden[id] {
+				return &InvalidExportError{Field: field, Message: "must not be overridden twice"}
+			}
+			overridden[id] = true
+			err = tx.Omit(clause.Associations).Create(&models.FoodOverride{
+				Model:       gorm.Model{CreatedAt: override.CreatedAt, UpdatedAt: override.UpdatedAt},
+				UserID:      user.ID,
+				FoodID:      id,
+				Name:        override.Name,
+				Protein:     override.Protein,
+				Carbs:       override.Carbs,
+				Fat:         override.Fat,
+				Calories:    override.Calories,
+				Fiber:       override.Fiber,
+				ServingSize: override.ServingSize,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// importExportedFood returns the ID of the stored food of an exported one:
+// the shared food with its FDC ID, or a custom food of the user, created
+// when there is none. A shared food deleted here is not brought back: the
+// user gets a custom food of its exported values instead.
+func importExportedFood(tx *gorm.DB, userID uint, exported models.ExportedFood, field string) (uint, error) {
+	food := models.Food{
+		FdcID:            exported.FdcID,
+		Name:             exported.Name,
+		DataType:         exported.DataType,
+		BrandOwner:       exported.BrandOwner,
+		Protein:          exported.Protein,
+		Carbs:            exported.Carbs,
+		Fat:              exported.Fat,
+		Calories:         exported.Calories,
+		Fiber:            exported.Fiber,
+		ServingSize:      exported.ServingSize,
+		ServingSizeUnit:  exported.ServingSizeUnit,
+		HouseholdServing: exported.HouseholdServing,
+	}
+	for _, portion := range exported.Portions {
+		food.Portions = append(food.Portions, models.FoodPortion{Description: portion.Description, GramWeight: portion.GramWeight})
+	}
+	// Custom foods are keyed by their user, which is a new one
+	toCustom := func() {
+		food.DataType, food.UserID = DataTypeCustom, &userID
+		food.FdcID = customFoodID(userID, food)
+	}
+	if exported.Custom {
+		toCustom()
+	}
+
+	// Deleted foods keep their FDC ID, which is unique
+	var stored models.Food
+	result := tx.Unscoped().Where("fdc_id = ?", food.FdcID).Limit(1).Find(&stored)
+	if result.Error == nil && result.RowsAffected > 0 && stored.DeletedAt.Valid && stored.UserID == nil {
+		toCustom()
+		stored = models.Food{}
+		result = tx.Unscoped().Where("fdc_id = ?", food.FdcID).Limit(1).Find(&stored)
+	}
+	switch {
+	case result.Error != nil:
+		return 0, result.Error
+	case result.RowsAffected == 0:
+		if err := tx.Create(&food).Error; err != nil {
+			return 0, err
+		}
+		return food.ID, nil
+	case stored.UserID != nil && (food.UserID == nil || *stored.UserID != userID):
+		return 0, &InvalidExportError{Field: field + ".fdcId", Message: "must not be the fdcId of a custom food of another user"}
+	}
+	return stored.ID, nil
+}
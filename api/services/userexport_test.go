@@ -0,0 +1,103 @@
+package services_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+)
+
+func TestUserExportZipMealsMatchCSVExport(t *testing.T) {
+	db := newTestDB(t)
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Age: 36, Weight: 60, Height: 165}
+	rice := models.Food{FdcID: "169756", Name: "Rice", DataType: "SR Legacy", Calories: 365, Protein: 7.1, Carbs: 80, Fat: 0.7, Fiber: 1.3}
+	egg := models.Food{FdcID: "171287", Name: "Egg", DataType: "SR Legacy", Calories: 143, Protein: 12.6, Carbs: 0.7, Fat: 9.5}
+	create(t, db, &user, &rice, &egg)
+	meal := models.Meal{Type: models.Lunch, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), UserID: user.ID}
+	name, calories := "My rice", 99.0
+	create(t, db, &meal)
+	create(t, db,
+		&models.MealFood{MealID: meal.ID, FoodID: rice.ID, Grams: 150},
+		&models.MealFood{MealID: meal.ID, FoodID: egg.ID, Grams: 50, Measure: "1 large"},
+		&models.FoodOverride{UserID: user.ID, FoodID: rice.ID, Name: &name, Calories: &calories})
+
+	entries, err := services.MealEntries(db, user.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("MealEntries: %v", err)
+	}
+	var want bytes.Buffer
+	if err := services.WriteMealsCSV(&want, entries); err != nil {
+		t.Fatalf("WriteMealsCSV: %v", err)
+	}
+
+	export, err := services.ExportUser(db, user.ID)
+	if err != nil {
+		t.Fatalf("ExportUser: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := services.WriteUserExportZip(&archive, export); err != nil {
+		t.Fatalf("WriteUserExportZip: %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	file, err := reader.Open("meals.csv")
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	defer file.Close()
+	got, _ := io.ReadAll(file)
+
+	if !bytes.Contains(got, []byte("My rice")) || string(got) != want.String() {
+		t.Errorf("meals.csv of the archive:\n%s\nwant, as the meals CSV export:\n%s", got, want.String())
+	}
+}
+
+func TestImportUserDeletedSharedFood(t *testing.T) {
+	db := newTestDB(t)
+	rice := models.Food{FdcID: "169756", Name: "Rice", DataType: "SR Legacy", Calories: 365}
+	create(t, db, &rice)
+	if err := db.Delete(&rice).Error; err != nil {
+		t.Fatalf("Failed to delete the food: %v", err)
+	}
+
+	export := &models.UserExport{
+		Version: models.UserExportVersion,
+		Profile: models.ExportedProfile{FirstName: "Grace", LastName: "Hopper", Age: 45, Weight: 58, Height: 160},
+		Meals: []models.ExportedMeal{{Type: models.Lunch, Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			Foods: []models.ExportedMealFood{{FdcID: "169756", Grams: 80}}}},
+		Foods:     []models.ExportedFood{{FdcID: "169756", Name: "Rice", DataType: "SR Legacy", Calories: 360}},
+		Favorites: []models.ExportedFavorite{{FdcID: "169756"}},
+	}
+	user, err := services.ImportUser(db, export)
+	if err != nil {
+		t.Fatalf("ImportUser: %v", err)
+	}
+
+	// The deleted food stays deleted, and the meal eats a copy of the
+	// exported one
+	var deleted models.Food
+	if err := db.Unscoped().First(&deleted, rice.ID).Error; err != nil || !deleted.DeletedAt.Valid {
+		t.Errorf("shared food: %v, deleted %t, want it still deleted", err, deleted.DeletedAt.Valid)
+	}
+	var eaten models.Food
+	err = db.Joins("JOIN meal_foods ON meal_foods.food_id = foods.id").
+		Joins("JOIN meals ON meals.id = meal_foods.meal_id").
+		Where("meals.user_id = ?", user.ID).First(&eaten).Error
+	if err != nil {
+		t.Fatalf("the imported meal eats no food: %v", err)
+	}
+	if eaten.ID == rice.ID || eaten.UserID == nil || *eaten.UserID != user.ID || eaten.DataType != services.DataTypeCustom || eaten.Calories != 360 {
+		t.Errorf("eaten food = %+v, want a custom food of user %d with the exported values", eaten, user.ID)
+	}
+	var favorites int64
+	db.Model(&models.FavoriteFood{}).Where("user_id = ? AND food_id = ?", user.ID, eaten.ID).Count(&favorites)
+	if favorites != 1 {
+		t.Errorf("got %d favourites of the custom food, want 1", favorites)
+	}
+}
@@ -0,0 +1,560 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+	"golang.org/x/term"
+)
+
+// chartStyle is the set of characters charts are drawn with
+type chartStyle struct {
+	// spark are the levels of a sparkline, lowest first
+	spark []rune
+	// eighths are the partial cells of a bar, one eighth to seven eighths
+	eighths []rune
+	full    rune
+	// over fills the part of a bar beyond its target
+	over   rune
+	empty  rune
+	target rune
+	// shades tell the parts of a stacked bar apart
+	shades []rune
+	// rule and ellipsis draw the dashboard's separators and shortened texts
+	rule     rune
+	ellipsis rune
+}
+
+var (
+	unicodeCharts = chartStyle{
+		spark:   []rune("▁▂▃▄▅▆▇█"),
+		eighths: []rune("▏▎▍▌▋▊▉"),
+		full:    '█', over: '▓', empty: '░', target: '│',
+		shades: []rune("█▓░"),
+		rule:   '─', ellipsis: '…',
+	}
+	asciiCharts = chartStyle{
+		spark: []rune("_.-=+*#"),
+		full:  '#', over: '+', empty: '.', target: '|',
+		shades: []rune("#=-"),
+		rule:   '-', ellipsis: '~',
+	}
+	// charts is the style of the charts, ASCII with --ascii
+	charts = unicodeCharts
+)
+
+// terminalWidth returns the width of the terminal, from $COLUMNS when set, 80
+// when the output is not a terminal
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	return 80
+}
+
+// sparkline draws values as a line of width cells, each the average of the
+// values falling into it. NaN values are gaps.
+func sparkline(values []float64, width int) string {
+	cells := resample(values, width)
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, v := range cells {
+		if !math.IsNaN(v) {
+			low, high = math.Min(low, v), math.Max(high, v)
+		}
+	}
+
+	var b strings.Builder
+	levels := len(charts.spark)
+	for _, v := range cells {
+		switch {
+		case math.IsNaN(v):
+			b.WriteRune(' ')
+		case high == low:
+			b.WriteRune(charts.spark[levels/2])
+		default:
+			b.WriteRune(charts.spark[int(math.Round((v-low)/(high-low)*float64(levels-1)))])
+		}
+	}
+	return b.String()
+}
+
+// resample averages values into at most width cells
+func resample(values []float64, width int) []float64 {
+	if len(values) <= width {
+		return values
+	}
+	cells := make([]float64, width)
+	for i := range cells {
+		sum, n := 0.0, 0
+		for _, v := range values[i*len(values)/width : (i+1)*len(values)/width] {
+			if !math.IsNaN(v) {
+				sum += v
+				n++
+			}
+		}
+		cells[i] = math.NaN()
+		if n > 0 {
+			cells[i] = sum / float64(n)
+		}
+	}
+	return cells
+}
+
+// bar draws value as a bar of up to width cells, width standing for scale.
+// A positive target is marked, and the part of the bar beyond it shaded.
+func bar(value, target, scale float64, width int) string {
+	if scale <= 0 || width <= 0 {
+		return ""
+	}
+	cell := func(v float64) float64 { return math.Min(v/scale*float64(width), float64(width)) }
+	length, mark := cell(math.Max(value, 0)), -1
+	if target > 0 {
+		// A target at the scale is marked on the last cell
+		mark = min(int(cell(target)), width-1)
+	}
+
+	cells := make([]rune, width)
+	for i := range cells {
+		switch {
+		case float64(i+1) <= length && mark >= 0 && i >= mark:
+			cells[i] = charts.over
+		case float64(i+1) <= length:
+			cells[i] = charts.full
+		case float64(i) < length && len(charts.eighths) > 0:
+			if eighth := int((length - float64(i)) * 8); eighth > 0 {
+				cells[i] = charts.eighths[eighth-1]
+			} else {
+				cells[i] = ' '
+			}
+		default:
+			cells[i] = ' '
+		}
+	}
+	if mark >= 0 && mark < width && cells[mark] == ' ' {
+		cells[mark] = charts.target
+	}
+	return strings.TrimRight(string(cells), " ")
+}
+
+// progressBar draws the share of a target reached as a bar of width cells,
+// its rest filled with the empty shade
+func progressBar(value, target float64, width int) string {
+	if target <= 0 || width <= 0 {
+		return ""
+	}
+	filled := int(math.Round(math.Min(math.Max(value/target, 0), 1) * float64(width)))
+	return strings.Repeat(string(charts.full), filled) + strings.Repeat(string(charts.empty), width-filled)
+}
+
+// stackedBar draws the shares as consecutive parts of a bar of width cells,
+// each in its shade
+func stackedBar(shares []float64, width int) string {
+	total := 0.0
+	for _, share := range shares {
+		total += math.Max(share, 0)
+	}
+	if total == 0 {
+		return ""
+	}
+	var b strings.Builder
+	drawn, sum := 0, 0.0
+	for i, share := range shares {
+		// Round the running total so that the parts add up to width
+		sum += math.Max(share, 0)
+		end := int(math.Round(sum / total * float64(width)))
+		b.WriteString(strings.Repeat(string(charts.shades[i%len(charts.shades)]), end-drawn))
+		drawn = end
+	}
+	return b.String()
+}
+
+// chartKinds are the charts drawn by the chart command
+var chartKinds = []string{"weight", "calories", "macros"}
+
+// maxDailyBars is the longest period drawn with a bar per day, longer ones
+// being drawn with a bar per week
+const maxDailyBars = 62
+
+// handleChartCommand draws the selected user's weight trend, daily calories
+// against their target or macro split over a period, the 30 days up to today
+// by default, e.g. `chart calories --from 2024-03-01 --to 2024-03-31`
+func handleChartCommand(args []string) error {
+	fs := newFlagSet("chart")
+	dates := addDateRangeFlags(fs)
+	width := fs.Int("width", 0, "width of the chart in columns (default: the terminal's)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || !slices.Contains(chartKinds, positional[0]) {
+		return usagef("usage: chart <weight|calories|macros> [--from --to --width N]")
+	}
+	from, to, err := chartPeriod(dates)
+	if err != nil {
+		return err
+	}
+	if *width <= 0 {
+		*width = terminalWidth()
+	}
+	userID, err := selectedUserID()
+	if err != nil {
+		return err
+	}
+
+	if positional[0] == "weight" {
+		return chartWeight(userID, from, to, *width)
+	}
+	intake, err := fetchIntake(userID, from, to)
+	if err != nil {
+		return err
+	}
+	if positional[0] == "calories" {
+		return render(intake, func() { printCalorieChart(intake, *width) })
+	}
+	return render(intake, func() { printMacroChart(intake, *width) })
+}
+
+// chartPeriod returns the first and last days of the --from and --to flags,
+// to being today and from 29 days before it by default
+func chartPeriod(dates *dateRangeFlags) (time.Time, time.Time, error) {
+	dateRange, err := dates.dateRange()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, to := dateRange.From, dateRange.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -29)
+	}
+	if formatDate(from) > formatDate(to) {
+		return from, to, usagef("--from must not be after --to")
+	}
+	return from, to, nil
+}
+
+// days returns the days from from to to, both included
+func days(from, to time.Time) []time.Time {
+	var days []time.Time
+	for day := from; formatDate(day) <= formatDate(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// chartWeight draws the weight records of the period as a sparkline
+func chartWeight(userID uint, from, to time.Time, width int) error {
+	history, err := client.All(context.Background(), client.ListOptions{Limit: 200},
+		func(ctx context.Context, opts client.ListOptions) (*client.Page[client.WeightRecord], error) {
+			return apiClient.GetWeightHistory(ctx, userID, client.DateRange{From: from, To: to}, opts)
+		})
+	if err != nil {
+		return fmt.Errorf("error getting weight history: %w", err)
+	}
+	records := make(weightHistory, len(history))
+	for i, record := range history {
+		records[i] = newWeightRecord(record)
+	}
+	records.sort()
+
+	return render(records, func() {
+		if len(records) == 0 {
+			fmt.Printf("No weight records from %s to %s.\n", formatDate(from), formatDate(to))
+			return
+		}
+		low, high := records[0].Weight, records[0].Weight
+		for _, record := range records {
+			low, high = math.Min(low, record.Weight), math.Max(high, record.Weight)
+		}
+		fmt.Printf("Weight from %s to %s\n", formatDate(from), formatDate(to))
+		fmt.Println(weightTrend(records, from, to, width))
+		fmt.Printf("%d record(s), lowest %.1f kg, highest %.1f kg\n", len(records), low, high)
+	})
+}
+
+// weightTrend draws the weight records as a sparkline of the days from from
+// to to, those of the first and last records when zero, between the first
+// and last weights, e.g. "72.4 kg ▅▆▇▆▅▃▂ 70.1 kg (-2.3 kg)"
+func weightTrend(records weightHistory, from, to time.Time, width int) string {
+	records = slices.Clone(records)
+	records.sort()
+	first, last := records[0], records[len(records)-1]
+	if from.IsZero() {
+		from = first.Date
+	}
+	if to.IsZero() {
+		to = last.Date
+	}
+	start := fmt.Sprintf("%.1f kg ", first.Weight)
+	end := fmt.Sprintf(" %.1f kg (%+.1f kg)", last.Weight, last.Weight-first.Weight)
+	return start + sparkline(weightSeries(records, from, to), max(width-len(start)-len(end), 10)) + end
+}
+
+// weightSeries returns the weight of each day from from to to, out of
+// records sorted by date. Days between two records take the weight in
+// between, days before the first or after the last one are NaN.
+func weightSeries(records weightHistory, from, to time.Time) []float64 {
+	var series []float64
+	next := 0
+	for _, day := range days(from, to) {
+		date := formatDate(day)
+		for next < len(records) && formatDate(records[next].Date) < date {
+			next++
+		}
+		switch {
+		case next < len(records) && formatDate(records[next].Date) == date:
+			series = append(series, records[next].Weight)
+		case next == 0 || next == len(records):
+			series = append(series, math.NaN())
+		default:
+			previous, following := records[next-1], records[next]
+			share := day.Sub(previous.Date).Hours() / following.Date.Sub(previous.Date).Hours()
+			series = append(series, previous.Weight+(following.Weight-previous.Weight)*math.Min(math.Max(share, 0), 1))
+		}
+	}
+	return series
+}
+
+// intakeChart is the result of 'chart calories' and 'chart macros': the
+// intake of each day of the period, and the user's targets if set
+type intakeChart struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Targets *Target     `json:"targets"`
+	Days    []dayIntake `json:"days"`
+}
+
+// dayIntake is the intake of a day, summed over its meals
+type dayIntake struct {
+	Date  string `json:"date"`
+	Meals int    `json:"meals"`
+	Nutrients
+}
+
+func (c intakeChart) csvHeader() []string {
+	return []string{"date", "meals", "calories", "protein", "carbs", "fat", "fiber"}
+}
+
+func (c intakeChart) csvRows() [][]string {
+	rows := [][]string{}
+	for _, day := range c.Days {
+		rows = append(rows, []string{day.Date, strconv.Itoa(day.Meals), formatFloat(day.Calories), formatFloat(day.Protein),
+			formatFloat(day.Carbs), formatFloat(day.Fat), formatFloat(day.Fiber)})
+	}
+	return rows
+}
+
+// fetchIntake sums the meals of each day from from to to
+func fetchIntake(userID uint, from, to time.Time) (intakeChart, error) {
+	ctx := context.Background()
+	intake := intakeChart{From: formatDate(from), To: formatDate(to)}
+
+	target, err := apiClient.GetTargets(ctx, userID)
+	switch {
+	case err == nil:
+		targets := newTarget(*target)
+		intake.Targets = &targets
+	case !client.IsNotFound(err):
+		return intake, fmt.Errorf("error getting user targets: %w", err)
+	}
+
+	meals, err := client.All(ctx, client.ListOptions{Limit: 200},
+		func(ctx context.Context, opts client.ListOptions) (*client.Page[client.Meal], error) {
+			return apiClient.GetUserMeals(ctx, userID, client.MealFilter{DateRange: client.DateRange{From: from, To: to}}, opts)
+		})
+	if err != nil {
+		return intake, fmt.Errorf("error getting meals: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, day := range days(from, to) {
+		intake.Days = append(intake.Days, dayIntake{Date: formatDate(day)})
+		index[formatDate(day)] = i
+	}
+	for _, meal := range newMealList(meals) {
+		i, ok := index[formatDate(meal.Date)]
+		if !ok {
+			continue
+		}
+		intake.Days[i].Meals++
+		for _, food := range meal.Foods {
+			intake.Days[i].add(food)
+		}
+	}
+	return intake, nil
+}
+
+// intakeBar is a bar of the calorie and macro charts: a day, or a week of a
+// long period, with its average intake over its days with meals
+type intakeBar struct {
+	label string
+	days  int
+	Nutrients
+}
+
+// bars groups the days of the chart into bars
+func (c intakeChart) bars() []intakeBar {
+	var bars []intakeBar
+	for _, day := range c.Days {
+		label := day.Date
+		if len(c.Days) > maxDailyBars {
+			date, _ := time.Parse("2006-01-02", day.Date)
+			year, week := date.ISOWeek()
+			label = fmt.Sprintf("%d-W%02d", year, week)
+		}
+		if len(bars) == 0 || bars[len(bars)-1].label != label {
+			bars = append(bars, intakeBar{label: label})
+		}
+		if day.Meals > 0 {
+			bar := &bars[len(bars)-1]
+			bar.days++
+			bar.Nutrients = bar.plus(day.Nutrients)
+		}
+	}
+	for i := range bars {
+		if bars[i].days > 1 {
+			bars[i].Nutrients = bars[i].times(1 / float64(bars[i].days))
+		}
+	}
+	return bars
+}
+
+// average returns the average intake over the days with meals, and their
+// number
+func (c intakeChart) average() (Nutrients, int) {
+	var sum Nutrients
+	logged := 0
+	for _, day := range c.Days {
+		if day.Meals > 0 {
+			sum = sum.plus(day.Nutrients)
+			logged++
+		}
+	}
+	if logged == 0 {
+		return sum, 0
+	}
+	return sum.times(1 / float64(logged)), logged
+}
+
+// printCalorieChart draws the calories of each day, or week, of the period
+// as bars against the calorie target
+func printCalorieChart(c intakeChart, width int) {
+	average, logged := c.average()
+	if logged == 0 {
+		fmt.Printf("No meals from %s to %s.\n", c.From, c.To)
+		return
+	}
+	bars, target := c.bars(), 0.0
+	if c.Targets != nil {
+		target = c.Targets.Calories
+	}
+
+	title := fmt.Sprintf("Calories from %s to %s", c.From, c.To)
+	if len(c.Days) > maxDailyBars {
+		title += ", weekly averages"
+	}
+	if target > 0 {
+		title += fmt.Sprintf(", target %.0f kcal", target)
+	}
+	fmt.Println(title)
+
+	scale := target
+	for _, b := range bars {
+		scale = math.Max(scale, b.Calories)
+	}
+	labelWidth := len(bars[0].label)
+	// The label, the bar and the calories, e.g. "2024-03-01 ████▌  1850 kcal"
+	barWidth := max(width-labelWidth-13, 10)
+	for _, b := range bars {
+		value := "-"
+		if b.days > 0 {
+			value = fmt.Sprintf("%.0f kcal", b.Calories)
+		}
+		fmt.Printf("%-*s %-*s %10s\n", labelWidth, b.label, barWidth, bar(b.Calories, target, scale, barWidth), value)
+	}
+
+	summary := fmt.Sprintf("Average %.0f kcal over %d day(s) with meals out of %d", average.Calories, logged, len(c.Days))
+	if target > 0 {
+		summary += fmt.Sprintf(" (%.0f%% of target)\n%c target, %c over it", percentOf(average.Calories, target), charts.target, charts.over)
+	}
+	fmt.Println(summary)
+}
+
+// macroEnergy returns the calories of the protein, carbs and fat of n, at 4,
+// 4 and 9 kcal per gram
+func macroEnergy(n Nutrients) []float64 {
+	return []float64{n.Protein * 4, n.Carbs * 4, n.Fat * 9}
+}
+
+// printMacroChart draws the share of the calories of protein, carbs and fat
+// of each day, or week, of the period, its bars as long as their calories,
+// then the average split against the one of the targets
+func printMacroChart(c intakeChart, width int) {
+	average, logged := c.average()
+	if logged == 0 {
+		fmt.Printf("No meals from %s to %s.\n", c.From, c.To)
+		return
+	}
+	bars := c.bars()
+
+	title := fmt.Sprintf("Macro split from %s to %s, as a share of calories", c.From, c.To)
+	if len(c.Days) > maxDailyBars {
+		title += ", weekly averages"
+	}
+	fmt.Println(title)
+
+	scale := 0.0
+	for _, b := range bars {
+		scale = math.Max(scale, sum(macroEnergy(b.Nutrients)))
+	}
+	labelWidth := max(len(bars[0].label), len("Average"))
+	// The label, the bar and the split, e.g. "2024-03-01 ██▓▓▓▓░░  P25 C45 F30"
+	barWidth := max(width-labelWidth-14, 10)
+	for _, b := range bars {
+		energy, split := macroEnergy(b.Nutrients), "-"
+		if total := sum(energy); total > 0 {
+			split = fmt.Sprintf("P%.0f C%.0f F%.0f", energy[0]/total*100, energy[1]/total*100, energy[2]/total*100)
+		}
+		length := int(math.Round(sum(energy) / scale * float64(barWidth)))
+		fmt.Printf("%-*s %-*s %12s\n", labelWidth, b.label, barWidth, stackedBar(energy, length), split)
+	}
+
+	fmt.Println()
+	fmt.Printf("%-*s %s\n", labelWidth, "Average", stackedBar(macroEnergy(average), barWidth))
+	var targetEnergy []float64
+	if c.Targets != nil {
+		targetEnergy = macroEnergy(Nutrients{Protein: c.Targets.Protein, Carbs: c.Targets.Carbs, Fat: c.Targets.Fat})
+		if sum(targetEnergy) > 0 {
+			fmt.Printf("%-*s %s\n", labelWidth, "Target", stackedBar(targetEnergy, barWidth))
+		}
+	}
+
+	energy, grams := macroEnergy(average), []float64{average.Protein, average.Carbs, average.Fat}
+	for i, name := range []string{"Protein", "Carbs", "Fat"} {
+		line := fmt.Sprintf("%c %-7s %3.0f%%  %.0f g/day", charts.shades[i], name, energy[i]/sum(energy)*100, grams[i])
+		if sum(targetEnergy) > 0 {
+			line += fmt.Sprintf(", target %3.0f%%", targetEnergy[i]/sum(targetEnergy)*100)
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("Over %d day(s) with meals out of %d\n", logged, len(c.Days))
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
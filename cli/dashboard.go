@@ -0,0 +1,636 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+	"golang.org/x/term"
+)
+
+// searchDelay is how long the food picker waits for the next key before
+// searching, so that typing a word sends a single search
+const searchDelay = 250 * time.Millisecond
+
+// maxPickerResults is the number of foods the food picker lists
+const maxPickerResults = 20
+
+// dashboardMode is what the keys of the dashboard act on
+type dashboardMode int
+
+const (
+	// browsing moves between the meals and the days
+	browsing dashboardMode = iota
+	// picking searches for the food to add to the selected meal
+	picking
+	// weighing types the quantity of the picked food
+	weighing
+)
+
+// searchResult is the answer to a search of the food picker
+type searchResult struct {
+	id    int
+	foods []client.Food
+	err   error
+}
+
+// dashboard is the state of the full-screen dashboard
+type dashboard struct {
+	userID uint
+	name   string
+	date   time.Time
+
+	// The day's meals by type, the user's targets, nil if unset, and their
+	// weight records of the last 30 days
+	meals   map[client.MealType]Meal
+	targets *Target
+	weights weightHistory
+
+	mode     dashboardMode
+	selected int
+	// status is the outcome of the last action, shown until the next key
+	status string
+
+	// The food picker's query, its results and the one picked. searchID
+	// tells the answer to the last search from older ones.
+	query     []rune
+	results   []client.Food
+	picked    int
+	searchID  int
+	searching bool
+	searchErr error
+	timer     *time.Timer
+	found     chan searchResult
+	done      chan struct{}
+
+	grams []rune
+}
+
+// handleDashboardCommand runs the full-screen dashboard of the selected user:
+// the day's meals, the macros left to eat, the weight trend and a food picker
+// adding to the selected meal
+func handleDashboardCommand(args []string) error {
+	fs := newFlagSet("dashboard")
+	date := fs.String("date", "today", "day to show: YYYY-MM-DD, today or yesterday")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usagef("usage: dashboard [--date DATE]")
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return usagef("the dashboard needs a terminal")
+	}
+	day, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+	userID, err := selectedUserID()
+	if err != nil {
+		return err
+	}
+
+	user, err := apiClient.GetUser(context.Background(), userID)
+	if client.IsNotFound(err) {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("error getting user: %w", err)
+	}
+	d := &dashboard{
+		userID: userID,
+		name:   user.FirstName + " " + user.LastName,
+		date:   day,
+		found:  make(chan searchResult),
+		done:   make(chan struct{}),
+	}
+	// Before the full screen, so that errors are printed as usual
+	if err := d.load(); err != nil {
+		return err
+	}
+	return d.run()
+}
+
+// load fetches the day's meals, the targets and the weight records
+func (d *dashboard) load() error {
+	ctx := context.Background()
+	meals, err := client.All(ctx, client.ListOptions{Limit: 200},
+		func(ctx context.Context, opts client.ListOptions) (*client.Page[client.Meal], error) {
+			return apiClient.GetUserMeals(ctx, d.userID, client.MealFilter{Date: d.date}, opts)
+		})
+	if err != nil {
+		return fmt.Errorf("error getting meals: %w", err)
+	}
+	d.meals = map[client.MealType]Meal{}
+	for _, meal := range newMealList(meals) {
+		// Imports may have split a meal in several
+		merged := d.meals[client.MealType(meal.Type)]
+		merged.ID, merged.Type = meal.ID, meal.Type
+		merged.Foods = append(merged.Foods, meal.Foods...)
+		d.meals[client.MealType(meal.Type)] = merged
+	}
+
+	target, err := apiClient.GetTargets(ctx, d.userID)
+	switch {
+	case err == nil:
+		targets := newTarget(*target)
+		d.targets = &targets
+	case client.IsNotFound(err):
+		d.targets = nil
+	default:
+		return fmt.Errorf("error getting user targets: %w", err)
+	}
+
+	history, err := client.All(ctx, client.ListOptions{Limit: 200},
+		func(ctx context.Context, opts client.ListOptions) (*client.Page[client.WeightRecord], error) {
+			return apiClient.GetWeightHistory(ctx, d.userID, client.DateRange{From: d.date.AddDate(0, 0, -29), To: d.date}, opts)
+		})
+	if err != nil {
+		return fmt.Errorf("error getting weight history: %w", err)
+	}
+	d.weights = make(weightHistory, len(history))
+	for i, record := range history {
+		d.weights[i] = newWeightRecord(record)
+	}
+	d.weights.sort()
+	return nil
+}
+
+// run shows the dashboard until the user quits
+func (d *dashboard) run() error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("error setting up the terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(leaveAltScreen)
+	defer close(d.done)
+
+	keys, more := make(chan key), make(chan bool)
+	go readKeys(keys, more)
+	// Terminals only tell about resizes with a signal that doesn't exist on
+	// every platform: check the size instead
+	resize := time.NewTicker(250 * time.Millisecond)
+	defer resize.Stop()
+
+	width, height := screenSize()
+	d.draw(width, height)
+	for {
+		select {
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			quit := d.handleKey(k)
+			more <- !quit
+			if quit {
+				return nil
+			}
+		case result := <-d.found:
+			if result.id != d.searchID {
+				continue
+			}
+			d.searching, d.results, d.searchErr, d.picked = false, result.foods, result.err, 0
+		case <-resize.C:
+			if w, h := screenSize(); w == width && h == height {
+				continue
+			}
+		}
+		width, height = screenSize()
+		d.draw(width, height)
+	}
+}
+
+// readKeys sends the keys pressed to keys, waiting after each one to be told
+// whether to read more: the input following the last key is left to the REPL
+func readKeys(keys chan<- key, more <-chan bool) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+			if !<-more {
+				return
+			}
+		}
+	}
+}
+
+// screenSize returns the size of the terminal, 80x24 if unknown
+func screenSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// mealType returns the type of the selected meal
+func (d *dashboard) mealType() client.MealType {
+	return client.MealTypes[d.selected]
+}
+
+// handleKey acts on a key pressed and reports whether to quit
+func (d *dashboard) handleKey(k key) bool {
+	d.status = ""
+	if k.code == keyInterrupt {
+		return true
+	}
+	switch d.mode {
+	case browsing:
+		return d.browse(k)
+	case picking:
+		d.pick(k)
+	case weighing:
+		d.weigh(k)
+	}
+	return false
+}
+
+func (d *dashboard) browse(k key) bool {
+	switch {
+	case k.code == keyEscape || k.r == 'q':
+		return true
+	case k.code == keyUp || k.r == 'k':
+		d.selected = max(d.selected-1, 0)
+	case k.code == keyDown || k.r == 'j':
+		d.selected = min(d.selected+1, len(client.MealTypes)-1)
+	case k.code == keyLeft || k.r == 'h':
+		d.changeDay(d.date.AddDate(0, 0, -1))
+	case k.code == keyRight || k.r == 'l':
+		d.changeDay(d.date.AddDate(0, 0, 1))
+	case k.r == 't':
+		d.changeDay(time.Now())
+	case k.r == 'r':
+		d.reload()
+	case k.code == keyEnter || k.r == 'a' || k.r == '/':
+		d.mode, d.query, d.results, d.searchErr = picking, nil, nil, nil
+		d.search()
+	}
+	return false
+}
+
+func (d *dashboard) changeDay(date time.Time) {
+	d.date = date
+	d.reload()
+}
+
+func (d *dashboard) reload() {
+	if err := d.load(); err != nil {
+		d.status = formatError(err)
+	}
+}
+
+func (d *dashboard) pick(k key) {
+	switch k.code {
+	case keyEscape:
+		d.mode = browsing
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+		// Drop the answer to a search still running
+		d.searchID++
+	case keyUp:
+		d.picked = max(d.picked-1, 0)
+	case keyDown:
+		d.picked = max(min(d.picked+1, len(d.results)-1), 0)
+	case keyEnter:
+		if len(d.results) > 0 && !d.searching {
+			d.mode, d.grams = weighing, nil
+		}
+	case keyBackspace:
+		if len(d.query) > 0 {
+			d.query = d.query[:len(d.query)-1]
+			d.search()
+		}
+	case keyRune:
+		d.query = append(d.query, k.r)
+		d.search()
+	}
+}
+
+// search looks for the foods matching the query once the user stops typing,
+// or for the foods usually eaten at the selected meal when it is empty
+func (d *dashboard) search() {
+	d.searchID++
+	d.searching = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	id, query, mealType := d.searchID, strings.TrimSpace(string(d.query)), d.mealType()
+	d.timer = time.AfterFunc(searchDelay, func() {
+		result := searchResult{id: id}
+		ctx := context.Background()
+		if query == "" {
+			result.foods, result.err = suggestFoods(ctx, d.userID, mealType)
+		} else {
+			response, err := apiClient.SearchFoods(ctx, query, client.FoodSearchOptions{UserID: d.userID, Limit: maxPickerResults})
+			if err != nil {
+				result.err = fmt.Errorf("error searching for food: %w", err)
+			} else {
+				result.foods = response.Foods
+			}
+		}
+		select {
+		case d.found <- result:
+		case <-d.done:
+		}
+	})
+}
+
+func (d *dashboard) weigh(k key) {
+	switch {
+	case k.code == keyEscape:
+		d.mode = picking
+	case k.code == keyBackspace && len(d.grams) > 0:
+		d.grams = d.grams[:len(d.grams)-1]
+	case k.code == keyRune && (k.r >= '0' && k.r <= '9' || k.r == '.') && len(d.grams) < 7:
+		d.grams = append(d.grams, k.r)
+	case k.code == keyEnter:
+		grams, err := d.quantity()
+		if err != nil {
+			d.status = formatError(err)
+			return
+		}
+		d.add(d.results[d.picked], grams)
+	}
+}
+
+// quantity returns the grams typed, 100 when none were
+func (d *dashboard) quantity() (float64, error) {
+	if len(d.grams) == 0 {
+		return 100, nil
+	}
+	grams, err := strconv.ParseFloat(string(d.grams), 64)
+	if err != nil || grams <= 0 || grams > 10000 {
+		return 0, fmt.Errorf("the quantity must be between 0 and 10000 g")
+	}
+	return grams, nil
+}
+
+// add adds the picked food to the selected meal and goes back to the meals
+func (d *dashboard) add(food client.Food, grams float64) {
+	ctx := context.Background()
+	mealType := d.mealType()
+	mealID, err := findOrCreateMeal(ctx, d.userID, mealType, d.date)
+	if err == nil {
+		_, err = apiClient.AddFoodToMeal(ctx, mealID, client.AddFoodRequest{FoodID: food.FdcID, Grams: &grams})
+		if err != nil {
+			err = fmt.Errorf("error adding food to meal: %w", err)
+		}
+	}
+	if err != nil {
+		d.status = formatError(err)
+		return
+	}
+	d.mode = browsing
+	d.status = fmt.Sprintf("Added %s (%.0f g) to your %s", food.Name, grams, mealType)
+	d.reload()
+}
+
+// draw writes the dashboard to the terminal
+func (d *dashboard) draw(width, height int) {
+	c := newCanvas(width, height)
+	if width < 40 || height < 16 {
+		c.text(0, 0, width, "The terminal is too small for the dashboard", stylePlain)
+		c.WriteTo(os.Stdout)
+		return
+	}
+
+	title := fmt.Sprintf(" My Body Tracker - %s - %s", d.name, d.date.Format("Monday 2006-01-02"))
+	if formatDate(d.date) == formatDate(time.Now()) {
+		title += " (today)"
+	}
+	c.text(0, 0, width, title, styleReverse)
+	c.highlight(0, 0, width, styleReverse)
+
+	pickerHeight := max(height/3, 7)
+	top := height - 2 - pickerHeight
+	left := width * 11 / 20
+	d.drawMeals(c, 1, 0, left-1, top)
+	d.drawMacros(c, 1, left, width-left)
+	d.drawWeight(c, 9, left, width-left, top-8)
+	d.drawPicker(c, top+1, width, pickerHeight)
+
+	footer, style := d.help(), styleDim
+	if d.status != "" {
+		footer, style = d.status, styleBold
+	}
+	c.text(height-1, 1, width-2, footer, style)
+	c.WriteTo(os.Stdout)
+}
+
+func (d *dashboard) help() string {
+	switch d.mode {
+	case picking:
+		return "Type to search - Up/Down pick a food - Enter choose it - Esc back"
+	case weighing:
+		return "Type the grams eaten - Enter add the food - Esc back to the foods"
+	}
+	return "Up/Down meal - Left/Right day - t today - a add a food - r refresh - q quit"
+}
+
+// drawMeals draws the day's meals and their foods, scrolled down to the
+// selected one if needed
+func (d *dashboard) drawMeals(c *canvas, row, col, width, height int) {
+	c.rule(row, col, width, "Meals")
+	type line struct {
+		text, amount string
+		style        cellStyle
+	}
+	var lines []line
+	var selectedStart, selectedEnd int
+	var total Nutrients
+	for i, mealType := range client.MealTypes {
+		meal := d.meals[mealType]
+		var sum Nutrients
+		for _, food := range meal.Foods {
+			sum.add(food)
+		}
+		total = total.plus(sum)
+
+		if i == d.selected {
+			selectedStart = len(lines)
+		}
+		header := line{strings.ToUpper(string(mealType[:1])) + string(mealType[1:]), fmt.Sprintf("%.0f kcal", sum.Calories), styleBold}
+		if i == d.selected {
+			header.style = styleReverse
+		}
+		lines = append(lines, header)
+		for _, food := range meal.Foods {
+			lines = append(lines, line{"  " + food.Name + ", " + food.quantity(), fmt.Sprintf("%.0f kcal", food.eaten().Calories), stylePlain})
+		}
+		if len(meal.Foods) == 0 {
+			lines = append(lines, line{"  Nothing yet", "", styleDim})
+		}
+		if i == d.selected {
+			selectedEnd = len(lines)
+		}
+	}
+	lines = append(lines, line{fmt.Sprintf("Total - P %.0f g, C %.0f g, F %.0f g", total.Protein, total.Carbs, total.Fat),
+		fmt.Sprintf("%.0f kcal", total.Calories), styleBold})
+
+	visible := height - 1
+	offset := 0
+	if selectedEnd > visible {
+		offset = min(selectedEnd-visible, selectedStart)
+	}
+	for i, l := range lines[min(offset, len(lines)):] {
+		if i >= visible {
+			break
+		}
+		y := row + 1 + i
+		c.text(y, col+1, width-len(l.amount)-3, l.text, l.style)
+		c.text(y, col+width-len(l.amount)-1, len(l.amount), l.amount, l.style)
+		if l.style == styleReverse {
+			c.highlight(y, col, width, styleReverse)
+		}
+	}
+}
+
+// drawMacros draws the day's intake against the targets
+func (d *dashboard) drawMacros(c *canvas, row, col, width int) {
+	var eaten Nutrients
+	for _, meal := range d.meals {
+		for _, food := range meal.Foods {
+			eaten.add(food)
+		}
+	}
+	if d.targets == nil {
+		c.rule(row, col, width, "Eaten")
+	} else {
+		c.rule(row, col, width, "Left to eat")
+	}
+	nutrients := []struct {
+		name, unit    string
+		eaten, target float64
+	}{
+		{"Calories", "kcal", eaten.Calories, 0},
+		{"Protein", "g", eaten.Protein, 0},
+		{"Carbs", "g", eaten.Carbs, 0},
+		{"Fat", "g", eaten.Fat, 0},
+		{"Fiber", "g", eaten.Fiber, 0},
+	}
+	if d.targets != nil {
+		for i, target := range []float64{d.targets.Calories, d.targets.Protein, d.targets.Carbs, d.targets.Fat, d.targets.Fiber} {
+			nutrients[i].target = target
+		}
+	}
+
+	for i, n := range nutrients {
+		y := row + 1 + i
+		c.text(y, col+1, 9, n.name, styleBold)
+		if n.target <= 0 {
+			c.text(y, col+10, width-11, fmt.Sprintf("%.0f %s", n.eaten, n.unit), stylePlain)
+			continue
+		}
+		left := fmt.Sprintf("%.0f %s left", n.target-n.eaten, n.unit)
+		style := stylePlain
+		if n.eaten > n.target {
+			left, style = fmt.Sprintf("%.0f %s over", n.eaten-n.target, n.unit), styleBold
+		}
+		c.text(y, col+10, 13, fmt.Sprintf("%.0f/%.0f", n.eaten, n.target), stylePlain)
+		c.text(y, col+23, 14, left, style)
+		if barWidth := width - 39; barWidth >= 5 {
+			c.text(y, col+38, barWidth, progressBar(n.eaten, n.target, barWidth), stylePlain)
+		}
+	}
+	if d.targets == nil {
+		c.text(row+6, col+1, width-2, "No targets: set them with 'profile set-targets'", styleDim)
+	}
+}
+
+// drawWeight draws the weight trend of the last 30 days
+func (d *dashboard) drawWeight(c *canvas, row, col, width, height int) {
+	if height < 2 {
+		return
+	}
+	c.rule(row, col, width, "Weight, last 30 days")
+	if len(d.weights) == 0 {
+		c.text(row+1, col+1, width-2, "No weight recorded", styleDim)
+		return
+	}
+	c.text(row+1, col+1, width-2, weightTrend(d.weights, d.date.AddDate(0, 0, -29), d.date, width-2), stylePlain)
+	last := d.weights[len(d.weights)-1]
+	c.text(row+2, col+1, width-2, fmt.Sprintf("Last weighed %.1f kg on %s", last.Weight, formatDate(last.Date)), styleDim)
+}
+
+// drawPicker draws the food picker: its query and results, or the
+// quantity of the food picked
+func (d *dashboard) drawPicker(c *canvas, row, width, height int) {
+	mealType := d.mealType()
+	c.rule(row, 0, width, fmt.Sprintf("Add to %s", mealType))
+	switch d.mode {
+	case browsing:
+		c.text(row+1, 1, width-2, fmt.Sprintf("Press a to search for a food to add to your %s", mealType), styleDim)
+		return
+	case weighing:
+		food := d.results[d.picked]
+		label := fmt.Sprintf("Grams of %s: ", food.Name)
+		n := c.text(row+1, 1, width-2, label, styleBold)
+		n += c.text(row+1, 1+n, width-2-n, string(d.grams), stylePlain)
+		c.text(row+1, 1+n, 1, " ", styleReverse)
+		if len(d.grams) == 0 {
+			c.text(row+1, 2+n, width-3-n, " 100 by default", styleDim)
+		}
+		if grams, err := d.quantity(); err == nil {
+			eaten := newFood(food)
+			eaten.Grams = grams
+			n := eaten.eaten()
+			c.text(row+2, 1, width-2, fmt.Sprintf("%.0f kcal - P %.1f g, C %.1f g, F %.1f g, fiber %.1f g",
+				n.Calories, n.Protein, n.Carbs, n.Fat, n.Fiber), stylePlain)
+		}
+		return
+	}
+
+	n := c.text(row+1, 1, width-2, "Search: ", styleBold)
+	n += c.text(row+1, 1+n, width-2-n, string(d.query), stylePlain)
+	c.text(row+1, 1+n, 1, " ", styleReverse)
+
+	status := ""
+	switch {
+	case d.searching:
+		status = "Searching..."
+	case d.searchErr != nil:
+		status = formatError(d.searchErr)
+	case len(d.results) == 0 && len(d.query) == 0:
+		status = "No usual foods yet: type to search"
+	case len(d.results) == 0:
+		status = "No foods found"
+	case len(d.query) == 0:
+		status = fmt.Sprintf("Your usual foods at %s, or type to search", mealType)
+	default:
+		status = fmt.Sprintf("%d food(s) found", len(d.results))
+	}
+	c.text(row+2, 1, width-2, status, styleDim)
+	if d.searching || len(d.results) == 0 {
+		return
+	}
+
+	first := row + 3
+	visible := height - 3
+	offset := max(d.picked-visible+1, 0)
+	for i, food := range d.results[offset:] {
+		if i >= visible {
+			break
+		}
+		y := first + i
+		nutrients := fmt.Sprintf("%4.0f kcal  P %4.1f  C %4.1f  F %4.1f per 100 g", food.Calories, food.Protein, food.Carbs, food.Fat)
+		c.text(y, 1, width-len(nutrients)-4, food.Name+newFood(food).marks(), stylePlain)
+		c.text(y, width-len(nutrients)-1, len(nutrients), nutrients, stylePlain)
+		if offset+i == d.picked {
+			c.highlight(y, 0, width, styleReverse)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+)
+
+// handleExportCommand exports the selected user's meals, daily totals or
+// weight history as CSV, e.g. `export daily --from 2024-03-01 --out march.csv`
+func handleExportCommand(args []string) error {
+	fs := newFlagSet("export")
+	dates := addDateRangeFlags(fs)
+	out := fs.String("out", "", "file to save the CSV to (default: stdout)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || !slices.Contains([]string{client.MealsCSV, client.DailyCSV, client.WeightCSV}, positional[0]) {
+		return usagef("usage: export <meals|daily|weight> [--from --to --out FILE]")
+	}
+	dateRange, err := dates.dateRange()
+	if err != nil {
+		return err
+	}
+	userID, err := selectedUserID()
+	if err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	if _, err := apiClient.ExportCSV(context.Background(), userID, positional[0], dateRange, &data); err != nil {
+		return fmt.Errorf("error exporting %s: %w", positional[0], err)
+	}
+	if *out == "" {
+		_, err := data.WriteTo(os.Stdout)
+		return err
+	}
+	rows := bytes.Count(data.Bytes(), []byte("\n")) - 1
+	if err := os.WriteFile(*out, data.Bytes(), 0600); err != nil {
+		return fmt.Errorf("error saving export: %w", err)
+	}
+	info("Saved %d row(s) to %s\n", rows, *out)
+	return nil
+}
+
+// exportProfile saves all the data of a user to a file, named after the
+// user and the date unless given, or writes it to stdout with "-"
+func exportProfile(id uint, format, out string) error {
+	if format != "json" && format != "zip" {
+		return usagef("invalid format %q: must be json or zip", format)
+	}
+
+	var data bytes.Buffer
+	filename, err := apiClient.ExportUser(context.Background(), id, format, &data)
+	if client.IsNotFound(err) {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("error exporting profile: %w", err)
+	}
+
+	if out == "-" {
+		_, err := data.WriteTo(os.Stdout)
+		return err
+	}
+	if out == "" {
+		out = filename
+		if out == "" {
+			out = fmt.Sprintf("bodytracker-user-%d.%s", id, format)
+		}
+	}
+	// The export holds the user's health data, keep it private
+	if err := os.WriteFile(out, data.Bytes(), 0600); err != nil {
+		return fmt.Errorf("error saving export: %w", err)
+	}
+
+	result := exportResult{UserID: id, Format: format, File: out, Size: data.Len()}
+	return render(result, func() {
+		fmt.Printf("Exported profile %d to %s (%d bytes)\n", id, out, result.Size)
+	})
+}
+
+// exportResult is the result of `profile export`
+type exportResult struct {
+	UserID uint   `json:"userId"`
+	Format string `json:"format"`
+	File   string `json:"file"`
+	Size   int    `json:"size"`
+}
+
+func (r exportResult) csvHeader() []string {
+	return []string{"userId", "format", "file", "size"}
+}
+
+func (r exportResult) csvRows() [][]string {
+	return [][]string{{formatUint(r.UserID), r.Format, r.File, strconv.Itoa(r.Size)}}
+}
+
+// importProfile restores an export of `profile export`, read from a file or
+// from stdin with "-", as a new user
+func importProfile(path string) error {
+	var export io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error opening export: %w", err)
+		}
+		defer file.Close()
+		export = file
+	}
+
+	user, err := apiClient.ImportUser(context.Background(), export)
+	if err != nil {
+		return fmt.Errorf("error importing profile: %w", err)
+	}
+
+	return render(newUser(*user), func() {
+		fmt.Printf("Imported profile %s %s (ID %d). Use 'profile select %d' to switch to it.\n",
+			user.FirstName, user.LastName, user.ID, user.ID)
+	})
+}
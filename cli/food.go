@@ -1,93 +1,368 @@
 package cli
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
+	"os"
 	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
 )
 
-func handleFoodCommand(args []string) {
-	if len(args) < 2 {
-		fmt.Println("Usage: food search <query>")
-		return
+func handleFoodCommand(args []string) error {
+	if len(args) < 1 {
+		return usagef("usage: food search <query> | show <fdc-id> | favorites | star <fdc-id> | unstar <fdc-id> | overrides | override <fdc-id> | reset <fdc-id>")
 	}
-	if args[0] == "search" {
-		searchFood(strings.Join(args[1:], " "))
+
+	switch args[0] {
+	case "search":
+		fs := newFlagSet("food search")
+		page := fs.Int("page", 1, "page of results")
+		limit := fs.Int("limit", 0, "number of foods per page (default 10)")
+		dataTypes := fs.String("type", "", "comma-separated data types: foundation, sr-legacy, branded, survey")
+		brand := fs.String("brand", "", "only foods of this brand owner")
+		sort := fs.String("sort", "", "relevance (default), or name or dataType prefixed with - for descending")
+		refresh := fs.Bool("refresh", false, "query the food provider even if the query was sent recently")
+		positional, err := parseFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) == 0 {
+			return usagef("usage: food search <query> [--page N] [--limit N] [--type TYPES] [--brand OWNER] [--sort ORDER] [--refresh]")
+		}
+		if *page < 1 || *limit < 0 {
+			return usagef("--page and --limit must be positive")
+		}
+
+		opts := client.FoodSearchOptions{
+			Page:       *page,
+			Limit:      *limit,
+			BrandOwner: *brand,
+			Sort:       *sort,
+			UserID:     getCurrentUserID(),
+			Refresh:    *refresh,
+		}
+		if *dataTypes != "" {
+			opts.DataTypes = strings.Split(*dataTypes, ",")
+		}
+		return searchFood(strings.Join(positional, " "), opts)
+	case "show":
+		if len(args) != 2 {
+			return usagef("usage: food show <fdc-id>")
+		}
+		return showFood(args[1])
+	case "favorites":
+		userID, err := selectedUserID()
+		if err != nil {
+			return err
+		}
+		return listFavoriteFoods(userID)
+	case "star", "unstar", "reset":
+		if len(args) != 2 {
+			return usagef("usage: food %s <fdc-id>", args[0])
+		}
+		userID, err := selectedUserID()
+		if err != nil {
+			return err
+		}
+		switch args[0] {
+		case "star":
+			return starFood(userID, args[1])
+		case "unstar":
+			return unstarFood(userID, args[1])
+		default:
+			return resetFood(userID, args[1])
+		}
+	case "overrides":
+		userID, err := selectedUserID()
+		if err != nil {
+			return err
+		}
+		return listFoodOverrides(userID)
+	case "override":
+		var override client.FoodOverride
+		fs := newFlagSet("food override")
+		name := fs.String("name", "", "your name for the food")
+		values := map[string]*float64{
+			"calories":     fs.Float64("calories", 0, "calories per 100 g"),
+			"protein":      fs.Float64("protein", 0, "protein per 100 g"),
+			"carbs":        fs.Float64("carbs", 0, "carbohydrates per 100 g"),
+			"fat":          fs.Float64("fat", 0, "fat per 100 g"),
+			"fiber":        fs.Float64("fiber", 0, "fiber per 100 g"),
+			"serving-size": fs.Float64("serving-size", 0, "serving size in g"),
+		}
+		positional, err := parseFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		set := flagsSet(fs)
+		if len(positional) != 1 || len(set) == 0 {
+			return usagef("usage: food override <fdc-id> [--name --calories --protein --carbs --fat --fiber --serving-size]\n  values not given keep the shared food's ones")
+		}
+
+		// Only the values given on the command line are overridden
+		if set["name"] {
+			override.Name = name
+		}
+		for flagName, target := range map[string]**float64{
+			"calories":     &override.Calories,
+			"protein":      &override.Protein,
+			"carbs":        &override.Carbs,
+			"fat":          &override.Fat,
+			"fiber":        &override.Fiber,
+			"serving-size": &override.ServingSize,
+		} {
+			if set[flagName] {
+				*target = values[flagName]
+			}
+		}
+
+		userID, err := selectedUserID()
+		if err != nil {
+			return err
+		}
+		return overrideFood(userID, positional[0], override)
+	default:
+		return usagef("unknown food command %q. Available: search, show, favorites, star, unstar, overrides, override, reset", args[0])
 	}
 }
 
-func searchFood(query string) {
-	fmt.Println(apiURL + "/foods/search?q=" + query)
-	resp, err := http.Get(apiURL + "/foods/search?q=" + query)
-	if err != nil {
-		fmt.Println("Error searching food:", err)
-		return
+// selectedUserID returns the selected user's ID, or a usage error when no
+// user is selected
+func selectedUserID() (uint, error) {
+	userID := getCurrentUserID()
+	if userID == 0 {
+		return 0, usagef("no user selected. Use 'profile select <id>' to select one")
 	}
-	defer resp.Body.Close()
+	return userID, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		var message struct {
-			Error string `json:"error"`
+// searchFood prints a page of search results. In text mode, the user can
+// then page through the results unless prompts are disabled.
+func searchFood(query string, opts client.FoodSearchOptions) error {
+	for {
+		results, err := apiClient.SearchFoods(context.Background(), query, opts)
+		if err != nil {
+			return fmt.Errorf("error searching food: %w", err)
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
-			fmt.Println("Error parsing error response:", err)
-		} else {
-			fmt.Printf("Error: Server returned %s : %s\n", resp.Status, message.Error)
+		foods := newFoodList(results.Foods)
+
+		err = render(foods, func() {
+			if len(foods) == 0 {
+				fmt.Println("No foods found matching your query")
+				return
+			}
+
+			fmt.Printf("Page %d of %d, %d foods matching your query:\n\n", results.Page, results.TotalPages, results.TotalHits)
+			for _, food := range foods {
+				fmt.Printf("ID: %s\nName: %s%s\n", food.FdcID, food.Name, food.marks())
+				if food.DataType != "" {
+					fmt.Printf("Type: %s\n", food.describeType())
+				}
+				fmt.Printf("Calories: %.0f\n\n", food.Calories)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		more := results.Page < results.TotalPages
+		if outputFormat != outputText || noInput {
+			if more {
+				// On stderr so that the hint stays out of the listing
+				fmt.Fprintf(os.Stderr, "Page %d of %d. Use --page %d for the next one.\n", results.Page, results.TotalPages, results.Page+1)
+			}
+			return nil
+		}
+		if !more && results.Page <= 1 {
+			return nil
+		}
+
+		page, err := promptPage(results.Page, more)
+		if err != nil || page == 0 {
+			return err
 		}
-		return
+		opts.Page = page
 	}
+}
 
-	// Read the response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return
+// promptPage asks which page of results to show after page, and returns 0
+// when the user is done
+func promptPage(page int, more bool) (int, error) {
+	var choices []string
+	if more {
+		choices = append(choices, "Enter for the next page")
 	}
+	if page > 1 {
+		choices = append(choices, "p for the previous one")
+	}
+	choices = append(choices, "q to quit")
 
-	// First, try to parse the raw JSON to see what we're dealing with
-	var rawData map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &rawData); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
+	for {
+		answer, err := promptString(strings.Join(choices, ", ")+": ", "page")
+		if err != nil {
+			// End of input
+			return 0, nil
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			if !more {
+				return 0, nil
+			}
+			return page + 1, nil
+		case "n":
+			if more {
+				return page + 1, nil
+			}
+		case "p":
+			if page > 1 {
+				return page - 1, nil
+			}
+		case "q":
+			return 0, nil
+		}
+		info("Please choose one of: %s\n", strings.Join(choices, ", "))
 	}
+}
 
-	// Debug: print the raw data structure
-	fmt.Println("Response structure:", rawData)
+// showFood prints a food's nutrients and the household measures it can be
+// logged in with 'meal add --portion'
+func showFood(fdcID string) error {
+	food, err := apiClient.GetFood(context.Background(), fdcID, getCurrentUserID())
+	if err != nil {
+		return fmt.Errorf("error getting food: %w", err)
+	}
+	detail := newFoodDetail(*food)
 
-	// Extract foods from the response
-	var foods []Food
-	
-	// Check if the response has a 'foods' field
-	if foodsData, ok := rawData["foods"]; ok {
-		// Convert the foods data to JSON
-		foodsJSON, err := json.Marshal(foodsData)
-		if err != nil {
-			fmt.Println("Error marshaling foods data:", err)
+	return render(detail, func() {
+		fmt.Printf("ID: %s\nName: %s%s\n", detail.FdcID, detail.Name, detail.marks())
+		if detail.DataType != "" {
+			fmt.Printf("Type: %s\n", detail.describeType())
+		}
+		fmt.Printf("Per 100 g: %.0f calories, %.1fg protein, %.1fg carbs, %.1fg fat, %.1fg fiber\n",
+			detail.Calories, detail.Protein, detail.Carbs, detail.Fat, detail.Fiber)
+		if detail.ServingSize > 0 {
+			fmt.Printf("Serving size: %s %s", formatFloat(detail.ServingSize), detail.ServingSizeUnit)
+			if detail.HouseholdServing != "" {
+				fmt.Printf(" (%s)", detail.HouseholdServing)
+			}
+			fmt.Println()
+		}
+
+		if len(detail.Portions) == 0 {
+			fmt.Println("No household measures")
 			return
 		}
-		
-		// Unmarshal into our foods slice
-		if err := json.Unmarshal(foodsJSON, &foods); err != nil {
-			fmt.Println("Error parsing foods data:", err)
+		fmt.Println("Household measures:")
+		for i, portion := range detail.Portions {
+			fmt.Printf("%d. %s (%s g)\n", i+1, portion.Description, formatFloat(portion.GramWeight))
+		}
+	})
+}
+
+func listFavoriteFoods(userID uint) error {
+	favorites, err := apiClient.ListFavoriteFoods(context.Background(), userID)
+	if err != nil {
+		return fmt.Errorf("error getting favourite foods: %w", err)
+	}
+	foods := newFoodList(favorites)
+
+	return render(foods, func() {
+		if len(foods) == 0 {
+			fmt.Println("No favourite foods. Use 'food star <fdc-id>' to add one")
 			return
 		}
-	} else {
-		// Try parsing the whole response as an array of foods
-		if err := json.Unmarshal(bodyBytes, &foods); err != nil {
-			fmt.Println("Could not parse response as foods array:", err)
+
+		fmt.Println("Favourite foods:")
+		for _, food := range foods {
+			fmt.Printf("%s - %s%s (%.0f calories)\n", food.FdcID, food.Name, food.marks(), food.Calories)
+		}
+	})
+}
+
+func starFood(userID uint, fdcID string) error {
+	starred, err := apiClient.AddFavoriteFood(context.Background(), userID, fdcID)
+	if err != nil {
+		return fmt.Errorf("error starring food: %w", err)
+	}
+	food := newFood(*starred)
+
+	return render(foodList{food}, func() {
+		fmt.Printf("Added %s to your favourite foods\n", food.Name)
+	})
+}
+
+func unstarFood(userID uint, fdcID string) error {
+	if err := apiClient.RemoveFavoriteFood(context.Background(), userID, fdcID); err != nil {
+		return fmt.Errorf("error unstarring food: %w", err)
+	}
+	info("Removed %s from your favourite foods\n", fdcID)
+	return nil
+}
+
+func listFoodOverrides(userID uint) error {
+	result, err := apiClient.ListFoodOverrides(context.Background(), userID)
+	if err != nil {
+		return fmt.Errorf("error getting food overrides: %w", err)
+	}
+
+	overrides := make(foodOverrideList, len(result))
+	for i, override := range result {
+		overrides[i] = newFoodOverride(override)
+	}
+
+	return render(overrides, func() {
+		if len(overrides) == 0 {
+			fmt.Println("No food overrides. Use 'food override <fdc-id>' to set your own values for a food")
 			return
 		}
+
+		for _, o := range overrides {
+			fmt.Printf("%s - %s\n", o.FdcID, o.SharedName)
+			printOverride(o)
+		}
+	})
+}
+
+func overrideFood(userID uint, fdcID string, override client.FoodOverride) error {
+	saved, err := apiClient.SetFoodOverride(context.Background(), userID, fdcID, override)
+	if err != nil {
+		return fmt.Errorf("error overriding food: %w", err)
 	}
+	o := newFoodOverride(*saved)
 
-	if len(foods) == 0 {
-		fmt.Println("No foods found matching your query")
-		return
+	return render(foodOverrideList{o}, func() {
+		fmt.Printf("Your values for %s:\n", o.SharedName)
+		printOverride(o)
+	})
+}
+
+// printOverride prints the values of an override that replace shared ones
+func printOverride(o FoodOverride) {
+	if o.Name != nil {
+		fmt.Printf("  name: %s\n", *o.Name)
 	}
+	for _, value := range []struct {
+		label string
+		value *float64
+	}{
+		{"calories", o.Calories},
+		{"protein", o.Protein},
+		{"carbs", o.Carbs},
+		{"fat", o.Fat},
+		{"fiber", o.Fiber},
+		{"serving size", o.ServingSize},
+	} {
+		if value.value != nil {
+			fmt.Printf("  %s: %s\n", value.label, formatFloat(*value.value))
+		}
+	}
+}
 
-	fmt.Printf("Found %d foods matching your query:\n\n", len(foods))
-	for _, food := range foods {
-		fmt.Printf("ID: %s\nName: %s\nCalories: %.0f\n\n", food.FdcID, food.Name, food.Calories)
+func resetFood(userID uint, fdcID string) error {
+	if err := apiClient.DeleteFoodOverride(context.Background(), userID, fdcID); err != nil {
+		return fmt.Errorf("error resetting food: %w", err)
 	}
+	info("Food %s is back to its shared values\n", fdcID)
+	return nil
 }
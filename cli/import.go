@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+)
+
+// handleImportCommand imports a food log exported by another tracker, e.g.
+// `import servings.csv --dry-run`
+func handleImportCommand(args []string) error {
+	fs := newFlagSet("import")
+	format := fs.String("format", "", "mfp or cronometer, detected from the file's columns by default")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without saving anything")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("usage: import <file.csv|-> [--format mfp|cronometer] [--dry-run]")
+	}
+
+	userID, err := selectedUserID()
+	if err != nil {
+		return err
+	}
+
+	var log io.Reader = os.Stdin
+	if positional[0] != "-" {
+		file, err := os.Open(positional[0])
+		if err != nil {
+			return fmt.Errorf("error opening food log: %w", err)
+		}
+		defer file.Close()
+		log = file
+	}
+
+	report, err := apiClient.ImportFoodLog(context.Background(), userID, log, client.FoodLogImportOptions{Format: *format, DryRun: *dryRun})
+	if err != nil {
+		return fmt.Errorf("error importing food log: %w", err)
+	}
+	result := importReport{*report}
+
+	return render(result, func() {
+		verb := "Imported"
+		if result.DryRun {
+			fmt.Println("Dry run, nothing was saved.")
+			verb = "Would import"
+		}
+		fmt.Printf("%s %d food(s) from a %s log into your meals, creating %d meal(s) and %d custom food(s)\n",
+			verb, result.Entries, formatNames[result.Format], result.Meals, result.CustomFoods)
+		if len(result.Unmatched) > 0 {
+			fmt.Println("\nNo matching food, logged as custom foods:")
+			for _, line := range result.Unmatched {
+				fmt.Printf("  line %d: %s\n", line.Line, line.Food)
+			}
+		}
+		if len(result.Skipped) > 0 {
+			fmt.Println("\nSkipped:")
+			for _, line := range result.Skipped {
+				fmt.Printf("  line %d: %s - %s\n", line.Line, strings.TrimSpace(line.Food), line.Reason)
+			}
+		}
+	})
+}
+
+// formatNames are the names of the trackers of the food log formats
+var formatNames = map[string]string{"mfp": "MyFitnessPal", "cronometer": "Cronometer"}
+
+// importReport is the result of `import`, with a CSV row per unmatched or
+// skipped line
+type importReport struct {
+	client.FoodLogImport
+}
+
+func (r importReport) csvHeader() []string {
+	return []string{"line", "food", "status", "reason"}
+}
+
+func (r importReport) csvRows() [][]string {
+	rows := [][]string{}
+	for _, line := range r.Unmatched {
+		rows = append(rows, []string{strconv.Itoa(line.Line), line.Food, "unmatched", ""})
+	}
+	for _, line := range r.Skipped {
+		rows = append(rows, []string{strconv.Itoa(line.Line), line.Food, "skipped", line.Reason})
+	}
+	return rows
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stdin is shared by the REPL and the interactive prompts so that neither
+// loses input buffered by the other
+var stdin = bufio.NewReader(os.Stdin)
+
+// noInput disables interactive prompts: missing values become usage errors
+var noInput bool
+
+// usageError reports a command invoked with missing or invalid arguments
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func isUsageError(err error) bool {
+	var uerr *usageError
+	return errors.As(err, &uerr)
+}
+
+// newFlagSet creates a flag set for a command which reports errors instead of
+// exiting, so it can be used from the REPL as well as from the command line
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags parses flags placed anywhere among the arguments and returns the
+// remaining positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usagef("%s: %v", fs.Name(), err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// flagsSet returns the names of the flags given explicitly
+func flagsSet(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+func readLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptString asks for a value, or fails with a usage error naming the flag
+// that provides it when prompts are disabled
+func promptString(label, flagName string) (string, error) {
+	if noInput {
+		return "", usagef("missing --%s", flagName)
+	}
+	info("%s", label)
+	return readLine()
+}
+
+func promptInt(label, flagName string) (int, error) {
+	for {
+		value, err := promptString(label, flagName)
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(value)
+		if err == nil {
+			return n, nil
+		}
+		info("Please enter a whole number\n")
+	}
+}
+
+func promptFloat(label, flagName string) (float64, error) {
+	for {
+		value, err := promptString(label, flagName)
+		if err != nil {
+			return 0, err
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return f, nil
+		}
+		info("Please enter a number\n")
+	}
+}
+
+// parseDate accepts YYYY-MM-DD, 'today' or 'yesterday'
+func parseDate(dateStr string) (time.Time, error) {
+	switch dateStr {
+	case "", "today":
+		return time.Now(), nil
+	case "yesterday":
+		return time.Now().AddDate(0, 0, -1), nil
+	}
+
+	date, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return time.Time{}, usagef("invalid date format. Use YYYY-MM-DD, 'today' or 'yesterday'")
+	}
+	return date, nil
+}
+
+// parseMealType validates a meal type given on the command line
+func parseMealType(mealType string) (string, error) {
+	mealType = strings.ToLower(mealType)
+	switch mealType {
+	case "breakfast", "lunch", "break", "dinner":
+		return mealType, nil
+	}
+	return "", usagef("invalid meal type. Must be one of: breakfast, lunch, break, dinner")
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+)
+
+// listFlags are the paging and sorting flags of the list commands
+type listFlags struct {
+	page  *int
+	limit *int
+	sort  *string
+}
+
+func addListFlags(fs *flag.FlagSet, sorts string) *listFlags {
+	return &listFlags{
+		page:  fs.Int("page", 0, "fetch only this page (default: every page)"),
+		limit: fs.Int("limit", 0, "number of items per page"),
+		sort:  fs.String("sort", "", "sort order: "+sorts+", prefixed with - for descending"),
+	}
+}
+
+func (f *listFlags) options() (client.ListOptions, error) {
+	if *f.page < 0 || *f.limit < 0 {
+		return client.ListOptions{}, usagef("--page and --limit must be positive")
+	}
+	return client.ListOptions{Page: *f.page, Limit: *f.limit, Sort: *f.sort}, nil
+}
+
+// fetchList fetches the page requested with --page, or every page when the
+// flag is not set
+func fetchList[T any](f *listFlags, fetch func(context.Context, client.ListOptions) (*client.Page[T], error)) ([]T, error) {
+	opts, err := f.options()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	if opts.Page == 0 {
+		if opts.Limit == 0 {
+			// Fewer round trips when walking through every page
+			opts.Limit = 200
+		}
+		return client.All(ctx, opts, fetch)
+	}
+
+	page, err := fetch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if page.Next != 0 {
+		// On stderr so that the hint stays out of the listing
+		fmt.Fprintf(os.Stderr, "Page %d, %d items in total. Use --page %d for the next one.\n", opts.Page, page.Total, page.Next)
+	}
+	return page.Items, nil
+}
+
+// dateRangeFlags are the --from and --to flags of the list commands
+type dateRangeFlags struct {
+	from *string
+	to   *string
+}
+
+func addDateRangeFlags(fs *flag.FlagSet) *dateRangeFlags {
+	return &dateRangeFlags{
+		from: fs.String("from", "", "first day: YYYY-MM-DD, today or yesterday"),
+		to:   fs.String("to", "", "last day: YYYY-MM-DD, today or yesterday"),
+	}
+}
+
+func (f *dateRangeFlags) dateRange() (client.DateRange, error) {
+	var dates client.DateRange
+	var err error
+	if *f.from != "" {
+		if dates.From, err = parseDate(*f.from); err != nil {
+			return dates, err
+		}
+	}
+	if *f.to != "" {
+		if dates.To, err = parseDate(*f.to); err != nil {
+			return dates, err
+		}
+	}
+	return dates, nil
+}
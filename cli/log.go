@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+)
+
+// handleLogCommand logs the foods of a sentence, e.g.
+// `log 150g chicken breast and 1 cup rice for lunch yesterday`, after
+// showing how it reads it and asking for confirmation
+func handleLogCommand(args []string) error {
+	fs := newFlagSet("log")
+	yes := fs.Bool("yes", false, "log without asking for confirmation")
+	dryRun := fs.Bool("dry-run", false, "show how the sentence is read without logging anything")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	text := strings.Join(positional, " ")
+	if strings.TrimSpace(text) == "" {
+		return usagef(`usage: log <what you ate> [--yes] [--dry-run], e.g. log "150g chicken breast and 1 cup rice for lunch yesterday"`)
+	}
+
+	userID, err := selectedUserID()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	preview, err := quickLog(ctx, userID, text, true)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		return render(preview, preview.print)
+	}
+	if preview.Logged == 0 {
+		preview.print()
+		return fmt.Errorf("nothing to log: no food matches")
+	}
+
+	shown := false
+	if !*yes {
+		if noInput {
+			return usagef("missing --yes")
+		}
+		if outputFormat == outputText {
+			preview.print()
+			shown = true
+		}
+		answer, err := promptString(fmt.Sprintf("Log %d food(s)? [Y/n] ", preview.Logged), "yes")
+		if err != nil {
+			return err
+		}
+		if answer != "" && !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			info("Nothing logged\n")
+			return nil
+		}
+	}
+
+	result, err := quickLog(ctx, userID, text, false)
+	if err != nil {
+		return err
+	}
+	return render(result, func() {
+		if !shown {
+			result.print()
+		}
+		fmt.Printf("Logged %d food(s) in your %s for %s (%.0f calories)\n",
+			result.Logged, result.MealType, formatDate(result.Date), result.Totals.Calories)
+	})
+}
+
+func quickLog(ctx context.Context, userID uint, text string, dryRun bool) (quickLogResult, error) {
+	log, err := apiClient.QuickLog(ctx, userID, text, dryRun)
+	if client.IsNotFound(err) {
+		return quickLogResult{}, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return quickLogResult{}, fmt.Errorf("error logging %q: %w", text, err)
+	}
+	return quickLogResult{*log}, nil
+}
+
+// quickLogResult is the result of `log`, with a CSV row per food of the
+// sentence
+type quickLogResult struct {
+	client.QuickLog
+}
+
+// print shows how the sentence was read: the meal and each food with the
+// stored food it matches and its quantity
+func (r quickLogResult) print() {
+	guessed := ""
+	if r.MealGuessed {
+		guessed = " (guessed from the time of day)"
+	}
+	info("%s, %s%s:\n", r.MealType, formatDate(r.Date), guessed)
+	for _, entry := range r.Entries {
+		if entry.FdcID == "" {
+			info("  %s: %s\n", entry.Text, entry.Note)
+			continue
+		}
+		quantity := fmt.Sprintf("%.0f g", entry.Grams)
+		if entry.Measure != "" {
+			quantity = entry.Measure + ", " + quantity
+		}
+		info("  %s: %s, %s (%.0f calories)\n", entry.Text, entry.Name, quantity, entry.Nutrients.Calories)
+		if entry.Note != "" {
+			info("    %s\n", entry.Note)
+		}
+	}
+	info("Total: %.0f calories, %.1fg protein, %.1fg carbs, %.1fg fat, %.1fg fiber\n",
+		r.Totals.Calories, r.Totals.Protein, r.Totals.Carbs, r.Totals.Fat, r.Totals.Fiber)
+}
+
+func (r quickLogResult) csvHeader() []string {
+	return []string{"date", "mealType", "text", "fdcId", "food", "grams", "measure", "calories", "protein", "carbs", "fat", "fiber", "note"}
+}
+
+func (r quickLogResult) csvRows() [][]string {
+	rows := [][]string{}
+	for _, entry := range r.Entries {
+		name := entry.Name
+		if name == "" {
+			name = entry.Food
+		}
+		rows = append(rows, []string{formatDate(r.Date), string(r.MealType), entry.Text, entry.FdcID, name,
+			formatFloat(entry.Grams), entry.Measure, formatFloat(entry.Nutrients.Calories), formatFloat(entry.Nutrients.Protein),
+			formatFloat(entry.Nutrients.Carbs), formatFloat(entry.Nutrients.Fat), formatFloat(entry.Nutrients.Fiber), entry.Note})
+	}
+	return rows
+}
@@ -1,56 +1,204 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+)
+
+// Exit codes of the one-shot mode
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
 )
 
-const apiURL = "http://localhost:8080"
+var apiURL = defaultAPIURL()
+
+// apiClient is the API client shared by all commands
+var apiClient *client.Client
 
+func defaultAPIURL() string {
+	if url := os.Getenv("BODYTRACKER_API_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// Entrypoint runs a single command when one is given on the command line,
+// e.g. `bodytracker meal add --type lunch --date today --food rice --pick 1`,
+// and the interactive REPL otherwise
 func Entrypoint() {
-	fmt.Println("Welcome to My Body Tracker CLI!")
-	fmt.Println("Available commands:")
-	fmt.Println("  profile create - Create a new user profile")
+	os.Exit(run(os.Args[1:]))
+}
 
-	fmt.Println("  profile view <id> - View user profile and health statistics")
-	fmt.Println("  profile list - List available users")
+func run(args []string) int {
+	fs := newFlagSet("bodytracker")
+	fs.StringVar(&apiURL, "api-url", apiURL, "base URL of the API")
+	userID := fs.Uint("user", 0, "user ID to act as, overriding the selected user")
+	fs.BoolVar(&noInput, "no-input", false, "never prompt, fail when a value is missing")
+	ascii := fs.Bool("ascii", false, "draw charts with ASCII characters only")
+	output := fs.String("output", outputText, "output format: text, json or csv")
+	fs.StringVar(output, "o", outputText, "shorthand for --output")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, formatError(err))
+		printUsage()
+		return exitUsage
+	}
+	apiClient = client.New(apiURL)
+	if *ascii {
+		charts = asciiCharts
+	}
+	if err := setOutputFormat(*output); err != nil {
+		fmt.Fprintln(os.Stderr, formatError(err))
+		return exitUsage
+	}
 
-	fmt.Println("  profile set-targets <id> - Creater user targets")
-	fmt.Println("  profile targets <id> - View user targets")
+	if err := loadSession(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading session:", err)
+	}
+	if *userID != 0 {
+		currentSession = &Session{CurrentUserID: *userID}
+	}
 
-	fmt.Println("  profile weight <id> - Record user weight")
-	fmt.Println("  profile weight-history <id> - View user weight history")
+	if fs.NArg() == 0 || fs.Arg(0) == "repl" {
+		runREPL()
+		return exitOK
+	}
+
+	if err := handleCommand(fs.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, formatError(err))
+		if isUsageError(err) {
+			return exitUsage
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+// commandHelp is an entry of the help: a command with its arguments, what it
+// does and its flags, if any
+type commandHelp struct {
+	usage   string
+	summary string
+	flags   string
+}
 
-	fmt.Println("  profile select <id> - Select a user")
-	fmt.Println("  food search <query> - Search for food in database")
+var commandHelps = []commandHelp{
+	{"profile create", "Create a new user profile", "--first-name --last-name --age --weight --height --sex --activity --goal"},
+	{"profile view [id]", "View user profile and health statistics", ""},
+	{"profile list", "List available users", "--sort --page --limit"},
+	{"profile set-targets [id]", "Create user targets", "--calories --protein --carbs --fat --fiber"},
+	{"profile targets [id]", "View user targets", ""},
+	{"profile weight [id]", "Record user weight", "--weight --note --date"},
+	{"profile weight-history [id]", "View user weight history", "--from --to --sort --page --limit"},
+	{"profile export [id]", "Save all your data to a file", "--format json|zip --out FILE"},
+	{"profile import <file>", "Restore an exported profile as a new user", ""},
+	{"profile delete [id]", "Delete a user and their data", "--yes"},
+	{"profile restore <id>", "Bring back a deleted user", ""},
+	{"profile select <id>", "Select a user", ""},
+	{"profile whoami", "Show the selected user", ""},
+	{"profile deselect", "Forget the selected user", ""},
 
-	fmt.Println("  meal add <type> <date> <food_name> - Add food to meal")
-	fmt.Println("  meal view <type> <date> - View meal details and nutrients")
+	{"food search <query>", "Search for food in database, page by page",
+		"--page N --limit N --type foundation,sr-legacy,branded,survey --brand OWNER --sort name|-name|dataType|-dataType --refresh"},
+	{"food show <fdc-id>", "Show a food's nutrients and household measures", ""},
+	{"food favorites", "List your favourite foods (marked with *)", ""},
+	{"food star <fdc-id>", "Add a favourite food", ""},
+	{"food unstar <fdc-id>", "Remove a favourite food", ""},
+	{"food override <fdc-id>", "Use your own values for a food", "--name --calories --protein --carbs --fat --fiber --serving-size"},
+	{"food overrides", "List your own food values", ""},
+	{"food reset <fdc-id>", "Go back to a food's shared values", ""},
 
-	fmt.Println("  exit")
+	{"meal add <type> <date> [food_name]", "Add food to meal, offering usual foods first",
+		"--type --date --food --pick N | --fdc-id ID] [--grams G | --portion N --servings S"},
+	{"meal view <type> <date>", "View meal details and nutrients", "--type --date"},
+	{"meal list [type] [date]", "List meals", "--type --date --from --to --sort --page --limit"},
+	{"log <what you ate>", `Log foods from a sentence, e.g. log 150g chicken breast and 1 cup rice for lunch yesterday`, "--yes --dry-run"},
 
-	scanner := bufio.NewScanner(os.Stdin)
+	{"import <file.csv|->", "Import a MyFitnessPal or Cronometer food log export into your meals", "--format mfp|cronometer --dry-run"},
+	{"export <meals|daily|weight>", "Export your meal log, daily totals against your targets or weight history as CSV", "--from --to --out FILE"},
+	{"report <weekly|monthly> [week|month|date]", "Sum up your intake, adherence to your targets and weight change",
+		"--tolerance PCT --out FILE.md|FILE.html"},
+	{"report print", "Save a printable summary of your profile, weight, intake and meals as PDF or HTML", "--from --to --format pdf|html --out FILE"},
+	{"chart <weight|calories|macros>", "Chart your weight trend, daily calories against your target or macro split", "--from --to --width N"},
+	{"dashboard", "Full-screen view of the day's meals, macros left and weight trend, with a food picker", "--date"},
+
+	{"help [command]", "Show the commands, or those of a command", ""},
+	{"exit", "Leave the REPL", ""},
+}
+
+func printUsage() {
+	fmt.Println("Usage: bodytracker [--api-url URL] [--user ID] [--no-input] [--ascii] [--output text|json|csv] <command> [args...]")
+	fmt.Println("       bodytracker [repl]")
+	fmt.Println("Available commands:")
+	printCommandHelps(commandHelps)
+	fmt.Println("  (commands taking [id] default to the selected user)")
+}
+
+func printCommandHelps(helps []commandHelp) {
+	for _, help := range helps {
+		fmt.Printf("  %s - %s\n", help.usage, help.summary)
+		if help.flags != "" {
+			fmt.Printf("      [%s]\n", help.flags)
+		}
+	}
+}
+
+// printHelp shows the commands starting with the words of topic, e.g. "meal"
+// or "meal add", or all of them without a topic
+func printHelp(topic []string) error {
+	if len(topic) == 0 {
+		printUsage()
+		return nil
+	}
+	prefix := strings.Join(topic, " ") + " "
+	var helps []commandHelp
+	for _, help := range commandHelps {
+		if strings.HasPrefix(help.usage+" ", prefix) {
+			helps = append(helps, help)
+		}
+	}
+	if len(helps) == 0 {
+		return usagef("unknown command %q. Type 'help' for available commands", strings.Join(topic, " "))
+	}
+	printCommandHelps(helps)
+	if topic[0] == "profile" {
+		fmt.Println("  (commands taking [id] default to the selected user)")
+	}
+	return nil
+}
+
+func runREPL() {
+	fmt.Println("Welcome to My Body Tracker CLI!")
+	printUsage()
+
+	lines := newLineReader()
 	for {
-		fmt.Print("> ")
-		if !scanner.Scan() {
+		input, err := lines.readLine(prompt())
+		if err != nil {
 			break
 		}
 
-		input := scanner.Text()
 		if input == "exit" {
 			break
 		}
 
-		handleCommand(input)
+		args, err := splitArgs(input)
+		if err == nil {
+			err = handleCommand(args)
+		}
+		if err != nil {
+			fmt.Println(formatError(err))
+		}
 	}
 }
 
-func handleCommand(input string) {
-	parts := strings.Fields(input)
+func handleCommand(parts []string) error {
 	if len(parts) == 0 {
-		return
+		return nil
 	}
 
 	command := parts[0]
@@ -59,22 +207,62 @@ func handleCommand(input string) {
 	switch command {
 	case "profile":
 		if len(args) == 0 {
-			fmt.Println("Usage: profile <create|view> [id]")
-			return
+			return usagef("usage: profile <create|list|select|whoami|deselect|view|targets|set-targets|weight|weight-history|export|import|delete|restore> [args...]")
 		}
-		handleProfileCommand(args)
+		return handleProfileCommand(args)
 
 	case "food":
-		handleFoodCommand(args)
+		return handleFoodCommand(args)
 
 	case "meal":
 		if len(args) == 0 {
-			fmt.Println("Usage: meal <create|add|view> [args...]")
-			return
+			return usagef("usage: meal <add|view|list> [args...]")
 		}
-		handleMealCommand(args)
+		return handleMealCommand(args)
+
+	case "log":
+		return handleLogCommand(args)
+
+	case "import":
+		return handleImportCommand(args)
+
+	case "export":
+		return handleExportCommand(args)
+
+	case "report":
+		return handleReportCommand(args)
+
+	case "chart":
+		return handleChartCommand(args)
+
+	case "dashboard":
+		return handleDashboardCommand(args)
+
+	case "help":
+		return printHelp(args)
 
 	default:
-		fmt.Println("Unknown command. Type 'help' for available commands")
+		return usagef("unknown command %q. Type 'help' for available commands", command)
+	}
+}
+
+// formatError renders an error as a sentence, e.g. "Error listing users: ..."
+func formatError(err error) string {
+	msg := err.Error()
+	if msg == "" {
+		return msg
+	}
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
+// prompt shows the selected user, if any, before the input marker
+func prompt() string {
+	userID := getCurrentUserID()
+	if userID == 0 {
+		return "> "
+	}
+	if name := getCurrentUserName(); name != "" {
+		return fmt.Sprintf("[%s] > ", name)
 	}
+	return fmt.Sprintf("[user %d] > ", userID)
 }
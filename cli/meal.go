@@ -1,480 +1,567 @@
 package cli
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
 )
 
-func handleMealCommand(args []string) {
+func handleMealCommand(args []string) error {
 	if len(args) < 1 {
-		fmt.Println("Usage: meal add <type> <date> <food_name> | view <date> <type> | list [date] [type]")
-		return
+		return usagef("usage: meal add <type> <date> <food_name> | view <type> <date> | list [type] [date]")
 	}
 
+	fs := newFlagSet("meal " + args[0])
+	mealType := fs.String("type", "", "meal type: breakfast, lunch, break, dinner")
+	date := fs.String("date", "", "date: YYYY-MM-DD, today or yesterday")
+
 	switch args[0] {
 	case "add":
-		if len(args) != 4 {
-			fmt.Println("Usage: meal add <type> <date> <food_name>")
-			fmt.Println("  type: breakfast, lunch, break, dinner")
-			fmt.Println("  date: YYYY-MM-DD or 'today'")
-			fmt.Println("  food_name: name of the food to search for")
-			return
+		food := fs.String("food", "", "name of the food to search for")
+		pick := fs.Int("pick", 0, "number of the search result to add, skipping the selection prompt")
+		fdcID := fs.String("fdc-id", "", "FDC ID of the food, skipping the search")
+		var quantity mealQuantity
+		fs.Float64Var(&quantity.grams, "grams", 0, "quantity eaten in grams (default 100)")
+		fs.IntVar(&quantity.portion, "portion", 0, "number of the food's household measure, as listed by 'food show'")
+		fs.Float64Var(&quantity.servings, "servings", 0, "number of household measures eaten (default 1)")
+		positional, err := parseFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		switch len(positional) {
+		case 3:
+			*food = positional[2]
+			fallthrough
+		case 2:
+			*mealType, *date = positional[0], positional[1]
+		}
+		if (len(positional) != 0 && len(positional) != 2 && len(positional) != 3) || *mealType == "" {
+			return usagef(`usage: meal add <type> <date> [food_name]
+       meal add --type <type> [--date <date>] [--food <food_name>] [--pick N | --fdc-id ID]
+                [--grams G | --portion N [--servings S]]
+  type: breakfast, lunch, break, dinner
+  date: YYYY-MM-DD, 'today' or 'yesterday' (default today)
+  food_name: name of the food to search for. Without it, the foods you
+             usually eat at that meal are offered first
+  quantity: in grams, or in one of the food's household measures. It is
+            asked for when not given, 100 g with --no-input`)
 		}
-		addFoodToMealType(args[1], args[2], args[3])
+		if quantity.grams < 0 || quantity.portion < 0 || quantity.servings < 0 {
+			return usagef("--grams, --portion and --servings must be positive")
+		}
+		if quantity.grams > 0 && (quantity.portion > 0 || quantity.servings > 0) {
+			return usagef("--grams can't be used with --portion or --servings")
+		}
+		return addFoodToMealType(*mealType, *date, *food, *pick, *fdcID, quantity)
 	case "view":
-		if len(args) != 3 {
-			fmt.Println("Usage: meal view <type> <date>")
-			fmt.Println("  date: YYYY-MM-DD or 'today'")
-			fmt.Println("  type: breakfast, lunch, break, dinner")
-			return
+		positional, err := parseFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) == 2 {
+			*mealType, *date = positional[0], positional[1]
+		} else if len(positional) != 0 || *mealType == "" {
+			return usagef(`usage: meal view <type> <date>
+       meal view --type <type> [--date <date>]
+  date: YYYY-MM-DD, 'today' or 'yesterday' (default today)
+  type: breakfast, lunch, break, dinner`)
 		}
-		viewMeal(args[1], args[2])
+		return viewMeal(*mealType, *date)
 	case "list":
-		userID := getCurrentUserID()
-		if userID == 0 {
-			fmt.Println("No user selected. Use 'profile select <id>' to select one.")
-			return
+		dates := addDateRangeFlags(fs)
+		paging := addListFlags(fs, "date, type")
+		positional, err := parseFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) > 0 {
+			*mealType = positional[0]
 		}
-		var date, mealType string
-		if len(args) > 1 {
-			mealType = args[1]
+		if len(positional) > 1 {
+			*date = positional[1]
 		}
-		if len(args) > 2 {
-			date = args[2]
+		if len(positional) > 2 {
+			return usagef("usage: meal list [type] [date] [--from --to --sort --page --limit]")
 		}
-		listMeals(fmt.Sprint(userID), date, mealType)
+		userID := getCurrentUserID()
+		if userID == 0 {
+			return usagef("no user selected. Use 'profile select <id>' to select one")
+		}
+		return listMeals(userID, *date, *mealType, dates, paging)
 	default:
-		fmt.Println("Unknown meal command")
+		return usagef("unknown meal command %q", args[0])
+	}
+}
+
+// mealView is the result of 'meal view': a meal's foods and their totals
+type mealView struct {
+	Type  string    `json:"type"`
+	Date  string    `json:"date"`
+	Foods foodList  `json:"foods"`
+	Total Nutrients `json:"total"`
+}
+
+func (v mealView) csvHeader() []string {
+	return append([]string{"type", "date"}, Food{}.csvHeader()...)
+}
+
+func (v mealView) csvRows() [][]string {
+	rows := [][]string{}
+	for _, row := range v.Foods.csvRows() {
+		rows = append(rows, append([]string{v.Type, v.Date}, row...))
 	}
+	return rows
 }
 
-func viewMeal(mealType, dateStr string) {
+func viewMeal(mealType, dateStr string) error {
 	// Check if a user is selected
 	userID := getCurrentUserID()
 	if userID == 0 {
-		fmt.Println("No user selected. Use 'profile list' to see available users and 'profile select <id>' to select one.")
-		return
+		return usagef("no user selected. Use 'profile list' to see available users and 'profile select <id>' to select one")
 	}
 
-	// Parse date
-	var date time.Time
-	if dateStr == "today" {
-		date = time.Now()
-	} else {
-		var err error
-		date, err = time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			fmt.Println("Invalid date format. Use YYYY-MM-DD or 'today'")
-			return
-		}
+	date, err := parseDate(dateStr)
+	if err != nil {
+		return err
 	}
 
-	// Validate meal type
-	mealType = strings.ToLower(mealType)
-	validTypes := map[string]bool{"breakfast": true, "lunch": true, "break": true, "dinner": true}
-	if !validTypes[mealType] {
-		fmt.Println("Invalid meal type. Must be one of: breakfast, lunch, break, dinner")
-		return
+	if mealType, err = parseMealType(mealType); err != nil {
+		return err
 	}
 
-	// Get meal details
-	resp, err := http.Get(fmt.Sprintf("%s/meals/user/%d?date=%s&type=%s", apiURL, userID, date.Format("2006-01-02"), mealType))
+	filter := client.MealFilter{Date: date, Type: client.MealType(mealType)}
+	page, err := apiClient.GetUserMeals(context.Background(), userID, filter, client.ListOptions{})
 	if err != nil {
-		fmt.Println("Error getting meal:", err)
-		return
+		return fmt.Errorf("error getting meal: %w", err)
 	}
-	defer resp.Body.Close()
+	meals := page.Items
 
-	// Read the response body for debugging
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return
+	view := mealView{Type: mealType, Date: date.Format("2006-01-02"), Foods: foodList{}}
+	for _, meal := range meals {
+		for _, food := range meal.Foods {
+			view.Foods = append(view.Foods, newFood(food))
+			view.Total.add(newFood(food))
+		}
 	}
-	fmt.Println("Raw API response:", string(respBody))
 
-	// Create a new reader with the response body for decoding
-	reader := bytes.NewReader(respBody)
-
-	// Utiliser une structure plus flexible pour le décodage
-	var rawMeals []map[string]interface{}
-	if err := json.NewDecoder(reader).Decode(&rawMeals); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
-	}
+	return render(view, func() {
+		if len(meals) == 0 {
+			fmt.Printf("No %s found for %s\n", view.Type, view.Date)
+			return
+		}
 
-	if len(rawMeals) == 0 {
-		fmt.Printf("No %s found for %s\n", mealType, date.Format("2006-01-02"))
-		return
-	}
+		fmt.Printf("\n%s - %s\n", view.Type, view.Date)
+		fmt.Println("Foods:")
+		for _, food := range view.Foods {
+			eaten := food.eaten()
+			fmt.Printf("- %s%s, %s (%.0f calories, %.1fg protein, %.1fg carbs, %.1fg fat, %.1fg fiber)\n",
+				food.Name, food.marks(), food.quantity(), eaten.Calories, eaten.Protein, eaten.Carbs, eaten.Fat, eaten.Fiber)
+		}
 
-	// Afficher les informations du repas
-	meal := rawMeals[0]
-	fmt.Printf("\n%s - %s\n", meal["type"], meal["date"])
-	fmt.Println("Foods:")
+		// Display total nutrients
+		fmt.Println("\nTotal Nutrients:")
+		fmt.Printf("Calories: %.0f\n", view.Total.Calories)
+		fmt.Printf("Protein: %.1fg\n", view.Total.Protein)
+		fmt.Printf("Carbs: %.1fg\n", view.Total.Carbs)
+		fmt.Printf("Fat: %.1fg\n", view.Total.Fat)
+		fmt.Printf("Fiber: %.1fg\n", view.Total.Fiber)
+	})
+}
 
-	// Track total nutrients
-	var totalNutrients struct {
-		Calories float64
-		Protein  float64
-		Carbs    float64
-		Fat      float64
-		Fiber    float64
+func listMeals(userID uint, dateStr, mealType string, dates *dateRangeFlags, paging *listFlags) error {
+	var filter client.MealFilter
+	var err error
+	if filter.DateRange, err = dates.dateRange(); err != nil {
+		return err
+	}
+	if dateStr != "" {
+		date, err := parseDate(dateStr)
+		if err != nil {
+			return err
+		}
+		filter.Date = date
+	}
+	if mealType != "" {
+		mealType, err := parseMealType(mealType)
+		if err != nil {
+			return err
+		}
+		filter.Type = client.MealType(mealType)
 	}
 
-	// List foods and accumulate nutrients
-	foods, ok := meal["foods"].([]interface{})
-	if !ok {
-		fmt.Println("Error: foods field is not an array")
-		return
+	meals, err := fetchList(paging, func(ctx context.Context, opts client.ListOptions) (*client.Page[client.Meal], error) {
+		return apiClient.GetUserMeals(ctx, userID, filter, opts)
+	})
+	if err != nil {
+		return fmt.Errorf("error getting meals: %w", err)
 	}
 
-	for _, foodInterface := range foods {
-		food, ok := foodInterface.(map[string]interface{})
-		if !ok {
-			fmt.Println("Error: food item is not an object")
-			continue
-		}
+	list := newMealList(meals)
+	return render(list, func() {
+		for _, meal := range list {
+			fmt.Printf("\nMeal ID: %d\nType: %s\nDate: %s\n", meal.ID, meal.Type, meal.Date.Format("2006-01-02"))
 
-		name, _ := food["name"].(string)
-		calories, _ := food["calories"].(float64)
-		protein, _ := food["protein"].(float64)
-		carbs, _ := food["carbs"].(float64)
-		fat, _ := food["fat"].(float64)
-		fiber, _ := food["fiber"].(float64)
+			if len(meal.Foods) > 0 {
+				fmt.Println("Foods:")
+				for _, food := range meal.Foods {
+					fmt.Printf("  - %s%s, %s (%.0f calories)\n", food.Name, food.marks(), food.quantity(), food.eaten().Calories)
+				}
+			}
+		}
+	})
+}
 
-		fmt.Printf("- %s (%.0f calories, %.1fg protein, %.1fg carbs, %.1fg fat, %.1fg fiber)\n",
-			name, calories, protein, carbs, fat, fiber)
+// maxSuggestions is the number of foods offered by 'meal add' before searching
+const maxSuggestions = 8
 
-		totalNutrients.Calories += calories
-		totalNutrients.Protein += protein
-		totalNutrients.Carbs += carbs
-		totalNutrients.Fat += fat
-		totalNutrients.Fiber += fiber
+// suggestFoods returns the user's favourite foods and the foods they eat
+// most often at that meal, followed by the ones eaten there recently
+func suggestFoods(ctx context.Context, userID uint, mealType client.MealType) ([]client.Food, error) {
+	favorites, err := apiClient.ListFavoriteFoods(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting favourite foods: %w", err)
 	}
 
-	// Display total nutrients
-	fmt.Println("\nTotal Nutrients:")
-	fmt.Printf("Calories: %.0f\n", totalNutrients.Calories)
-	fmt.Printf("Protein: %.1fg\n", totalNutrients.Protein)
-	fmt.Printf("Carbs: %.1fg\n", totalNutrients.Carbs)
-	fmt.Printf("Fat: %.1fg\n", totalNutrients.Fat)
-	fmt.Printf("Fiber: %.1fg\n", totalNutrients.Fiber)
-}
-
-func listMeals(userID, date, mealType string) {
-	url := fmt.Sprintf("%s/meals/user/%s", apiURL, userID)
-	if date != "" {
-		url += "?date=" + date
+	filter := client.FoodUsageFilter{Type: mealType, Limit: maxSuggestions}
+	frequent, err := apiClient.FrequentFoods(ctx, userID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error getting frequent foods: %w", err)
 	}
-	if mealType != "" {
-		if strings.Contains(url, "?") {
-			url += "&"
-		} else {
-			url += "?"
+	recent, err := apiClient.RecentFoods(ctx, userID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error getting recent foods: %w", err)
+	}
+
+	var foods []client.Food
+	seen := map[uint]bool{}
+	suggest := func(food client.Food) {
+		if len(foods) < maxSuggestions && !seen[food.ID] {
+			seen[food.ID] = true
+			foods = append(foods, food)
 		}
-		url += "type=" + mealType
 	}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		fmt.Println("Error getting meals:", err)
-		return
+	// Favourites that were eaten at that meal first, then half frequent
+	// foods, recent ones, frequent ones again and other favourites
+	for _, usage := range slices.Concat(frequent, recent) {
+		if usage.Food.Favorite {
+			suggest(usage.Food)
+		}
+	}
+	for _, usage := range slices.Concat(frequent[:min(len(frequent), maxSuggestions/2)], recent, frequent) {
+		suggest(usage.Food)
+	}
+	for _, food := range favorites {
+		suggest(food)
 	}
-	defer resp.Body.Close()
+	return foods, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		var message struct {
-			Error string `json:"error"`
+// pickSuggestion returns the FDC ID of the suggested food chosen by number,
+// with pick or interactively, or the name of the food to search for when the
+// user types one instead
+func pickSuggestion(suggestions []client.Food, mealType string, pick int) (fdcID, query string, err error) {
+	if pick == 0 && len(suggestions) > 0 {
+		info("\nYour usual foods for %s:\n", mealType)
+		for i, food := range suggestions {
+			info("%d. %s%s (%.0f calories)\n", i+1, food.Name, newFood(food).marks(), food.Calories)
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
-			fmt.Println("Error parsing error response:", err)
+
+		answer, err := promptString("\nSelect a food (enter number) or type a name to search: ", "food")
+		if err != nil {
+			return "", "", err
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil {
+			query = answer
 		} else {
-			fmt.Printf("Error: Server returned %s : %s\n", resp.Status, message.Error)
+			pick = n
+		}
+	} else if pick == 0 {
+		if query, err = promptString("Food name: ", "food"); err != nil {
+			return "", "", err
 		}
-		return
 	}
 
-	// Read the response body for debugging
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return
+	if query != "" {
+		return "", query, nil
 	}
-	fmt.Println("Raw API response:", string(respBody))
-
-	// Create a new reader with the response body for decoding
-	reader := bytes.NewReader(respBody)
-
-	// Utiliser une structure plus flexible pour le décodage
-	var rawMeals []map[string]interface{}
-	if err := json.NewDecoder(reader).Decode(&rawMeals); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
+	if pick < 1 || pick > len(suggestions) {
+		return "", "", usagef("invalid selection")
 	}
+	return suggestions[pick-1].FdcID, "", nil
+}
 
-	for _, meal := range rawMeals {
-		id, _ := meal["id"].(float64)
-		name, _ := meal["name"].(string)
-		mealType, _ := meal["type"].(string)
-		dateStr, _ := meal["date"].(string)
-
-		fmt.Printf("\nMeal ID: %.0f\nName: %s\nType: %s\nDate: %s\n",
-			id, name, mealType, dateStr)
+// pickSearchResult searches for a food and returns the FDC ID of the result
+// chosen by number, with pick on the first page or interactively, where n and
+// p move between the pages of results
+func pickSearchResult(ctx context.Context, query string, userID uint, pick int) (string, error) {
+	opts := client.FoodSearchOptions{UserID: userID, Page: 1}
+	for {
+		results, err := apiClient.SearchFoods(ctx, query, opts)
+		if err != nil {
+			return "", fmt.Errorf("error searching for food: %w", err)
+		}
+		foods := results.Foods
 
-		foods, ok := meal["foods"].([]interface{})
-		if !ok {
-			fmt.Println("Error: foods field is not an array")
+		if len(foods) == 0 {
+			if opts.Page == 1 {
+				return "", fmt.Errorf("no foods found matching your query")
+			}
+			// The provider may count more results than it returns
+			info("No more foods\n")
+			opts.Page--
 			continue
 		}
 
-		if len(foods) > 0 {
-			fmt.Println("Foods:")
-			for _, foodInterface := range foods {
-				food, ok := foodInterface.(map[string]interface{})
-				if !ok {
-					fmt.Println("Error: food item is not an object")
+		if pick == 0 {
+			// Display food options
+			info("\nFound foods (page %d of %d):\n", results.Page, results.TotalPages)
+			for i, food := range foods {
+				info("%d. %s%s (%.0f calories)\n", i+1, food.Name, newFood(food).marks(), food.Calories)
+			}
+
+			// Get user selection
+			label := "\nSelect a food (enter number"
+			if results.Page < results.TotalPages {
+				label += ", n for the next page"
+			}
+			if results.Page > 1 {
+				label += ", p for the previous one"
+			}
+			answer, err := promptString(label+"): ", "pick")
+			if err != nil {
+				return "", err
+			}
+			switch strings.ToLower(answer) {
+			case "n":
+				if results.Page < results.TotalPages {
+					opts.Page++
 					continue
 				}
-
-				foodName, _ := food["name"].(string)
-				calories, _ := food["calories"].(float64)
-
-				fmt.Printf("  - %s (%.0f calories)\n", foodName, calories)
+			case "p":
+				if results.Page > 1 {
+					opts.Page--
+					continue
+				}
+			}
+			if pick, err = strconv.Atoi(answer); err != nil {
+				info("Please enter a whole number\n")
+				pick = 0
+				continue
 			}
 		}
-	}
-}
 
-func addFoodToMealType(mealType, dateStr, foodQuery string) {
-	// Check if a user is selected
-	userID := getCurrentUserID()
-	if userID == 0 {
-		fmt.Println("No user selected. Use 'profile list' to see available users and 'profile select <id>' to select one.")
-		return
+		if pick < 1 || pick > len(foods) {
+			return "", usagef("invalid selection")
+		}
+		return foods[pick-1].FdcID, nil
 	}
+}
 
-	// Validate meal type
-	mealType = strings.ToLower(mealType)
-	validTypes := map[string]bool{"breakfast": true, "lunch": true, "break": true, "dinner": true}
-	if !validTypes[mealType] {
-		fmt.Println("Invalid meal type. Must be one of: breakfast, lunch, break, dinner")
-		return
-	}
+// mealQuantity is the quantity given to 'meal add': grams, or servings of
+// the food's household measure numbered portion. Zero fields are not given.
+type mealQuantity struct {
+	grams    float64
+	portion  int
+	servings float64
+}
 
-	// Parse date
-	var date time.Time
-	if dateStr == "today" {
-		date = time.Now()
-	} else {
-		var err error
-		date, err = time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			fmt.Println("Invalid date format. Use YYYY-MM-DD or 'today'")
-			return
+// request returns the request adding the food in that quantity, asking for
+// it when it isn't given and prompts are enabled
+func (q mealQuantity) request(ctx context.Context, fdcID string, userID uint) (client.AddFoodRequest, error) {
+	request := client.AddFoodRequest{FoodID: fdcID}
+	if q.grams > 0 {
+		request.Grams = &q.grams
+		return request, nil
+	}
+	if q.portion == 0 && noInput {
+		if q.servings > 0 {
+			return request, usagef("--servings requires --portion")
 		}
+		return request, nil
 	}
 
-	// Search for food
-	resp, err := http.Get(fmt.Sprintf("%s/foods/search?q=%s", apiURL, url.QueryEscape(foodQuery)))
+	// Fetching the food also stores it when it was never searched for
+	food, err := apiClient.GetFood(ctx, fdcID, userID)
 	if err != nil {
-		fmt.Println("Error searching for food:", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Read the response body
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return
-	}
-
-	// Define food item structure
-	type FoodItem struct {
-		FdcID    string  `json:"fdcId"`
-		Name     string  `json:"name"`
-		Calories float64 `json:"calories"`
-	}
-
-	// First, try to parse the raw JSON to see what we're dealing with
-	var rawData map[string]interface{}
-	if err := json.Unmarshal(respBody, &rawData); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
+		return request, fmt.Errorf("error getting food: %w", err)
 	}
 
-	// Extract foods from the response
-	var foods []FoodItem
-
-	// Check if the response has a 'foods' field
-	if foodsData, ok := rawData["foods"]; ok {
-		// Convert the foods data to JSON
-		foodsJSON, err := json.Marshal(foodsData)
-		if err != nil {
-			fmt.Println("Error marshaling foods data:", err)
-			return
+	if q.portion == 0 {
+		if len(food.Portions) > 0 {
+			info("\nHousehold measures of %s:\n", food.Name)
+			for i, portion := range food.Portions {
+				info("%d. %s (%s g)\n", i+1, portion.Description, formatFloat(portion.GramWeight))
+			}
 		}
-
-		// Unmarshal into our foods slice
-		if err := json.Unmarshal(foodsJSON, &foods); err != nil {
-			fmt.Println("Error parsing foods data:", err)
-			return
+		for request.Grams == nil && q.portion == 0 {
+			label := "\nQuantity in grams (default 100): "
+			if len(food.Portions) > 0 {
+				label = "\nSelect a measure (enter number) or type a weight like 150g (default 100g): "
+			}
+			answer, err := promptString(label, "grams")
+			if err != nil {
+				return request, err
+			}
+			answer = strings.ToLower(answer)
+			switch {
+			case answer == "":
+				return request, nil
+			case strings.HasSuffix(answer, "g") || len(food.Portions) == 0:
+				grams, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(answer, "g")), 64)
+				if err == nil && grams > 0 {
+					request.Grams = &grams
+				}
+			default:
+				q.portion, _ = strconv.Atoi(answer)
+				if q.portion < 1 || q.portion > len(food.Portions) {
+					q.portion = 0
+				}
+			}
+			if request.Grams == nil && q.portion == 0 {
+				info("Please enter a measure number or a weight in grams\n")
+			}
+		}
+		if request.Grams != nil {
+			return request, nil
 		}
-	} else {
-		// Try parsing the whole response as an array of foods
-		if err := json.Unmarshal(respBody, &foods); err != nil {
-			// If that fails, try with a results field
-			var resultsResponse struct {
-				Results []FoodItem `json:"results"`
+		for q.servings <= 0 {
+			answer, err := promptString("How many? (default 1): ", "servings")
+			if err != nil {
+				return request, err
 			}
-			if err := json.Unmarshal(respBody, &resultsResponse); err != nil {
-				fmt.Println("Error parsing food search results:", err)
-				return
+			if answer == "" {
+				answer = "1"
+			}
+			if q.servings, err = strconv.ParseFloat(answer, 64); err != nil || q.servings <= 0 {
+				info("Please enter a positive number\n")
+				q.servings = 0
 			}
-			foods = resultsResponse.Results
 		}
 	}
 
-	if len(foods) == 0 {
-		fmt.Println("No foods found matching your query")
-		return
+	if q.portion > len(food.Portions) {
+		return request, usagef("%s has %d household measures, see 'food show %s'", food.Name, len(food.Portions), fdcID)
 	}
-
-	// Display food options
-	fmt.Println("\nFound foods:")
-	for i, food := range foods {
-		fmt.Printf("%d. %s (%.0f calories)\n", i+1, food.Name, food.Calories)
+	request.PortionID = &food.Portions[q.portion-1].ID
+	if q.servings > 0 {
+		request.Servings = &q.servings
 	}
+	return request, nil
+}
 
-	// Get user selection
-	fmt.Print("\nSelect a food (enter number): ")
-	var selection int
-	fmt.Scanf("%d\n", &selection)
-	selection-- // Convert to 0-based index
+// addedFood is the result of 'meal add'
+type addedFood struct {
+	MealID uint   `json:"mealId"`
+	Type   string `json:"type"`
+	Date   string `json:"date"`
+	Food   Food   `json:"food"`
+}
 
-	if selection < 0 || selection >= len(foods) {
-		fmt.Println("Invalid selection")
-		return
-	}
+func (a addedFood) csvHeader() []string {
+	return append([]string{"mealId", "type", "date"}, Food{}.csvHeader()...)
+}
 
-	// Create or get meal for the given type and date
-	meal := struct {
-		ID     uint      `json:"id"`
-		Name   string    `json:"name"`
-		Type   string    `json:"type"`
-		Date   time.Time `json:"date"`
-		UserID uint      `json:"userId"`
-	}{
-		Name:   fmt.Sprintf("%s on %s", mealType, date.Format("2006-01-02")),
-		Type:   mealType,
-		Date:   date,
-		UserID: userID,
-	}
+func (a addedFood) csvRows() [][]string {
+	return [][]string{append([]string{formatUint(a.MealID), a.Type, a.Date}, a.Food.csvRow()...)}
+}
 
-	// Try to find existing meal
-	mealURL := fmt.Sprintf("%s/meals/user/%d?date=%s&type=%s", apiURL, userID, date.Format("2006-01-02"), mealType)
-	fmt.Println(mealURL)
-	resp, err = http.Get(mealURL)
-	if err != nil {
-		fmt.Println("Error checking for existing meal:", err)
-		return
+// addFoodToMealType adds a food to the selected user's meal of the given type
+// and date, creating the meal if needed. The food is searched by name and
+// picked from the results, by number when pick is set or interactively
+// otherwise; fdcID skips the search entirely. Without a name, the foods the
+// user usually eats at that meal are offered first.
+func addFoodToMealType(mealType, dateStr, foodQuery string, pick int, fdcID string, quantity mealQuantity) error {
+	ctx := context.Background()
+
+	// Check if a user is selected
+	userID := getCurrentUserID()
+	if userID == 0 {
+		return usagef("no user selected. Use 'profile list' to see available users and 'profile select <id>' to select one")
 	}
-	defer resp.Body.Close()
 
-	// Read the meal check response
-	mealRespBody, err := io.ReadAll(resp.Body)
+	mealType, err := parseMealType(mealType)
 	if err != nil {
-		fmt.Println("Error reading meal check response:", err)
-		return
+		return err
 	}
 
-	// Initialize mealID
-	var mealID uint
-
-	// First check if we got an error response
-	var errorResp struct {
-		Error string `json:"error"`
-	}
-	if err := json.Unmarshal(mealRespBody, &errorResp); err == nil && errorResp.Error != "" {
-		fmt.Println("Server returned an error:", errorResp.Error)
-		// We'll create a new meal since we couldn't find an existing one
-	} else {
-		// Try to parse as an array of meals
-		var existingMeals []struct {
-			ID uint `json:"id"`
-		}
-		if err := json.Unmarshal(mealRespBody, &existingMeals); err == nil && len(existingMeals) > 0 {
-			mealID = existingMeals[0].ID
-		}
+	date, err := parseDate(dateStr)
+	if err != nil {
+		return err
 	}
 
-	// If no existing meal was found, create a new one
-	if mealID == 0 {
-		// Create new meal
-		payload, err := json.Marshal(meal)
+	if fdcID == "" && foodQuery == "" {
+		// Offer the foods usually eaten at that meal before searching
+		suggestions, err := suggestFoods(ctx, userID, client.MealType(mealType))
 		if err != nil {
-			fmt.Println("Error preparing meal creation request:", err)
-			return
-		}
-
-		resp, err = http.Post(apiURL+"/meals", "application/json", bytes.NewBuffer(payload))
-		if err != nil {
-			fmt.Println("Error creating meal:", err)
-			return
+			return err
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated {
-			fmt.Println("Error: Server returned", resp.Status)
-			return
+		if fdcID, foodQuery, err = pickSuggestion(suggestions, mealType, pick); err != nil {
+			return err
 		}
+		// The pick applied to the suggestions, not to the search results
+		pick = 0
+	}
 
-		var createdMeal struct {
-			ID uint `json:"id"`
+	if fdcID == "" {
+		if fdcID, err = pickSearchResult(ctx, foodQuery, userID, pick); err != nil {
+			return err
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&createdMeal); err != nil {
-			fmt.Println("Error parsing meal creation response:", err)
-			return
-		}
-		mealID = createdMeal.ID
 	}
 
-	// Add food to meal
-	addFoodURL := fmt.Sprintf("%s/meals/%d/foods", apiURL, mealID)
-	payload := map[string]string{"foodId": foods[selection].FdcID}
+	request, err := quantity.request(ctx, fdcID, userID)
+	if err != nil {
+		return err
+	}
 
-	jsonPayload, err := json.Marshal(payload)
+	mealID, err := findOrCreateMeal(ctx, userID, client.MealType(mealType), date)
 	if err != nil {
-		fmt.Println("Error preparing food addition request:", err)
-		return
+		return err
 	}
 
-	fmt.Println("Adding food with ID:", foods[selection].FdcID)
-	resp, err = http.Post(addFoodURL, "application/json", bytes.NewBuffer(jsonPayload))
+	updated, err := apiClient.AddFoodToMeal(ctx, mealID, request)
 	if err != nil {
-		fmt.Println("Error adding food to meal:", err)
-		return
+		return fmt.Errorf("error adding food to meal: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		var message struct {
-			Error string `json:"error"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
-			fmt.Println("Error parsing error response:", err)
-		} else {
-			fmt.Printf("Error: Server returned %s : %s\n", resp.Status, message.Error)
+	result := addedFood{MealID: mealID, Type: mealType, Date: date.Format("2006-01-02"), Food: Food{FdcID: fdcID}}
+	for _, food := range updated.Foods {
+		if food.FdcID == fdcID {
+			result.Food = newFood(food)
 		}
-		return
 	}
 
-	fmt.Printf("Added %s to your %s for %s\n", foods[selection].Name, mealType, date.Format("2006-01-02"))
+	return render(result, func() {
+		fmt.Printf("Added %s (%s) to your %s for %s\n", result.Food.Name, result.Food.quantity(), mealType, result.Date)
+	})
+}
+
+// findOrCreateMeal returns the ID of the user's meal of that type and date,
+// creating it if needed
+func findOrCreateMeal(ctx context.Context, userID uint, mealType client.MealType, date time.Time) (uint, error) {
+	filter := client.MealFilter{Date: date, Type: mealType}
+	existingMeals, err := apiClient.GetUserMeals(ctx, userID, filter, client.ListOptions{Limit: 1})
+	if err != nil {
+		return 0, fmt.Errorf("error checking for existing meal: %w", err)
+	}
+	if len(existingMeals.Items) > 0 {
+		return existingMeals.Items[0].ID, nil
+	}
+
+	meal := client.Meal{
+		Type:   mealType,
+		Date:   date,
+		UserID: userID,
+	}
+	created, err := apiClient.CreateMeal(ctx, meal)
+	if err != nil {
+		return 0, fmt.Errorf("error creating meal: %w", err)
+	}
+	return created.ID, nil
 }
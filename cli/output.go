@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Output formats selected with --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputCSV  = "csv"
+)
+
+var outputFormat = outputText
+
+func setOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON, outputCSV:
+		outputFormat = format
+		return nil
+	}
+	return usagef("invalid output format %q. Must be one of: text, json, csv", format)
+}
+
+// table is a command result which can be rendered as CSV. Its JSON encoding
+// and CSV columns are the stable, machine-readable schema of the command.
+type table interface {
+	csvHeader() []string
+	csvRows() [][]string
+}
+
+// render prints a command's result in the selected output format, calling
+// text to print the human-readable form
+func render(result table, text func()) error {
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case outputCSV:
+		w := csv.NewWriter(os.Stdout)
+		if err := w.Write(result.csvHeader()); err != nil {
+			return err
+		}
+		return w.WriteAll(result.csvRows())
+	default:
+		text()
+		return nil
+	}
+}
+
+// info prints a human-readable message that is not part of a command's
+// result, on stderr when the output is meant for another program
+func info(format string, args ...any) {
+	if outputFormat == outputText {
+		fmt.Printf(format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatOptionalFloat formats f, or returns an empty string when it is nil
+func formatOptionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat(*f)
+}
+
+func formatUint(n uint) string {
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func (u User) csvHeader() []string {
+	return []string{"id", "firstName", "lastName", "age", "weight", "height", "sex", "goal"}
+}
+
+func (u User) csvRow() []string {
+	return []string{formatUint(u.ID), u.FirstName, u.LastName, strconv.Itoa(u.Age),
+		formatFloat(u.Weight), strconv.Itoa(u.Height), strconv.Itoa(u.Sex), u.Goal}
+}
+
+func (u User) csvRows() [][]string {
+	return [][]string{u.csvRow()}
+}
+
+type userList []User
+
+func (l userList) csvHeader() []string {
+	return User{}.csvHeader()
+}
+
+func (l userList) csvRows() [][]string {
+	rows := make([][]string, len(l))
+	for i, user := range l {
+		rows[i] = user.csvRow()
+	}
+	return rows
+}
+
+func (t Target) csvHeader() []string {
+	return []string{"calories", "protein", "carbs", "fat", "fiber"}
+}
+
+func (t Target) csvRows() [][]string {
+	return [][]string{{formatFloat(t.Calories), formatFloat(t.Protein), formatFloat(t.Carbs),
+		formatFloat(t.Fat), formatFloat(t.Fiber)}}
+}
+
+func (r WeightRecord) csvHeader() []string {
+	return []string{"id", "date", "weight", "note"}
+}
+
+func (r WeightRecord) csvRow() []string {
+	return []string{formatUint(r.ID), formatDate(r.Date), formatFloat(r.Weight), r.Note}
+}
+
+func (r WeightRecord) csvRows() [][]string {
+	return [][]string{r.csvRow()}
+}
+
+type weightHistory []WeightRecord
+
+// sort orders the records by date, oldest first
+func (h weightHistory) sort() {
+	slices.SortStableFunc(h, func(a, b WeightRecord) int { return a.Date.Compare(b.Date) })
+}
+
+func (h weightHistory) csvHeader() []string {
+	return WeightRecord{}.csvHeader()
+}
+
+func (h weightHistory) csvRows() [][]string {
+	rows := make([][]string, len(h))
+	for i, record := range h {
+		rows[i] = record.csvRow()
+	}
+	return rows
+}
+
+func (f Food) csvHeader() []string {
+	return []string{"fdcId", "name", "dataType", "brandOwner", "calories", "protein", "carbs", "fat", "fiber", "servingSize", "favorite", "overridden", "grams", "measure"}
+}
+
+func (f Food) csvRow() []string {
+	return []string{f.FdcID, f.Name, f.DataType, f.BrandOwner, formatFloat(f.Calories), formatFloat(f.Protein), formatFloat(f.Carbs),
+		formatFloat(f.Fat), formatFloat(f.Fiber), formatFloat(f.ServingSize),
+		strconv.FormatBool(f.Favorite), strconv.FormatBool(f.Overridden), formatFloat(f.Grams), f.Measure}
+}
+
+// marks returns the text output's markers for a starred or overridden food
+func (f Food) marks() string {
+	marks := ""
+	if f.Favorite {
+		marks += " *"
+	}
+	if f.Overridden {
+		marks += " (your values)"
+	}
+	return marks
+}
+
+// quantity returns the text output's description of the quantity of a
+// meal's food, e.g. "2 x 1 cup, 370 g"
+func (f Food) quantity() string {
+	grams := fmt.Sprintf("%.0f g", f.Grams)
+	if f.Measure == "" {
+		return grams
+	}
+	return f.Measure + ", " + grams
+}
+
+// describeType returns the food's data type, with its brand owner if any
+func (f Food) describeType() string {
+	if f.BrandOwner == "" {
+		return f.DataType
+	}
+	return fmt.Sprintf("%s (%s)", f.DataType, f.BrandOwner)
+}
+
+// FoodDetail's CSV has a row per household measure
+func (d FoodDetail) csvHeader() []string {
+	return append(d.Food.csvHeader(), "portion", "portionGrams")
+}
+
+func (d FoodDetail) csvRows() [][]string {
+	if len(d.Portions) == 0 {
+		return [][]string{append(d.Food.csvRow(), "", "")}
+	}
+	rows := make([][]string, len(d.Portions))
+	for i, portion := range d.Portions {
+		rows[i] = append(d.Food.csvRow(), portion.Description, formatFloat(portion.GramWeight))
+	}
+	return rows
+}
+
+type foodOverrideList []FoodOverride
+
+func (l foodOverrideList) csvHeader() []string {
+	return []string{"fdcId", "sharedName", "name", "calories", "protein", "carbs", "fat", "fiber", "servingSize"}
+}
+
+func (l foodOverrideList) csvRows() [][]string {
+	rows := make([][]string, len(l))
+	for i, o := range l {
+		name := ""
+		if o.Name != nil {
+			name = *o.Name
+		}
+		rows[i] = []string{o.FdcID, o.SharedName, name, formatOptionalFloat(o.Calories), formatOptionalFloat(o.Protein),
+			formatOptionalFloat(o.Carbs), formatOptionalFloat(o.Fat), formatOptionalFloat(o.Fiber), formatOptionalFloat(o.ServingSize)}
+	}
+	return rows
+}
+
+type foodList []Food
+
+func (l foodList) csvHeader() []string {
+	return Food{}.csvHeader()
+}
+
+func (l foodList) csvRows() [][]string {
+	rows := make([][]string, len(l))
+	for i, food := range l {
+		rows[i] = food.csvRow()
+	}
+	return rows
+}
+
+// mealList is rendered as one CSV row per food of each meal, and a row with
+// empty food columns for a meal without food
+type mealList []Meal
+
+func (l mealList) csvHeader() []string {
+	return append([]string{"mealId", "type", "date"}, Food{}.csvHeader()...)
+}
+
+func (l mealList) csvRows() [][]string {
+	rows := [][]string{}
+	for _, meal := range l {
+		columns := []string{formatUint(meal.ID), meal.Type, formatDate(meal.Date)}
+		if len(meal.Foods) == 0 {
+			rows = append(rows, append(columns, make([]string, len(Food{}.csvHeader()))...))
+			continue
+		}
+		for _, food := range meal.Foods {
+			rows = append(rows, append(slices.Clip(columns), food.csvRow()...))
+		}
+	}
+	return rows
+}
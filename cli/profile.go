@@ -1,430 +1,665 @@
 package cli
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
 )
 
-func handleProfileCommand(args []string) {
+func handleProfileCommand(args []string) error {
 	if len(args) < 1 {
-		fmt.Println("Usage: profile create | list | select <id> | view <id> | targets <id> | set-targets <id> | weight <id> | weight-history <id>")
-		return
+		return usagef("usage: profile create | list | select <id> | whoami | deselect | view [id] | targets [id] | set-targets [id] | weight [id] | weight-history [id] | export [id] | import <file> | delete [id] | restore <id>")
 	}
 
 	switch args[0] {
 	case "create":
-		user := promptUserProfile()
-		createProfile(user)
+		user, err := promptUserProfile(args[1:])
+		if err != nil {
+			return err
+		}
+		return createProfile(user)
 	case "list":
-		listProfiles()
+		fs := newFlagSet("profile list")
+		paging := addListFlags(fs, "id, name, created")
+		if positional, err := parseFlags(fs, args[1:]); err != nil {
+			return err
+		} else if len(positional) > 0 {
+			return usagef("usage: profile list [--sort --page --limit]")
+		}
+		return listProfiles(paging)
 	case "select":
 		if len(args) != 2 {
-			fmt.Println("Usage: profile select <id>")
-			return
+			return usagef("usage: profile select <id>")
 		}
-		selectProfile(args[1])
+		return selectProfile(args[1])
+	case "whoami":
+		return whoami()
+	case "deselect":
+		return deselectProfile()
 	case "view":
-		if len(args) != 2 {
-			fmt.Println("Usage: profile view <id>")
-			return
+		id, _, err := resolveUserID(args[1:], nil, "usage: profile view [id]")
+		if err != nil {
+			return err
 		}
-		viewProfile(args[1])
+		return viewProfile(id)
 	case "targets":
-		if len(args) != 2 {
-			fmt.Println("Usage: profile targets <id>")
-			return
+		id, _, err := resolveUserID(args[1:], nil, "usage: profile targets [id]")
+		if err != nil {
+			return err
 		}
-		viewTargets(args[1])
+		return viewTargets(id)
 	case "set-targets":
-		if len(args) != 2 {
-			fmt.Println("Usage: profile set-targets <id>")
-			return
+		fs := newFlagSet("profile set-targets")
+		target := targetFlags(fs)
+		id, set, err := resolveUserID(args[1:], fs, "usage: profile set-targets [id] [--calories --protein --carbs --fat --fiber]")
+		if err != nil {
+			return err
 		}
-		setTargets(args[1])
+		return setTargets(id, target, set)
 	case "weight":
-		if len(args) != 2 {
-			fmt.Println("Usage: profile weight <id>")
-			return
+		fs := newFlagSet("profile weight")
+		weight := fs.Float64("weight", 0, "weight in kg")
+		note := fs.String("note", "", "optional note")
+		date := fs.String("date", "today", "date of the measure (YYYY-MM-DD, today, yesterday)")
+		id, set, err := resolveUserID(args[1:], fs, "usage: profile weight [id] [--weight KG] [--note TEXT] [--date YYYY-MM-DD]")
+		if err != nil {
+			return err
 		}
-		recordWeight(args[1])
+		return recordWeight(id, *weight, *note, *date, set)
 	case "weight-history":
+		fs := newFlagSet("profile weight-history")
+		dates := addDateRangeFlags(fs)
+		paging := addListFlags(fs, "date, weight")
+		id, _, err := resolveUserID(args[1:], fs, "usage: profile weight-history [id] [--from --to --sort --page --limit]")
+		if err != nil {
+			return err
+		}
+		return viewWeightHistory(id, dates, paging)
+	case "export":
+		fs := newFlagSet("profile export")
+		format := fs.String("format", "json", "json, or zip for an archive with CSV files")
+		out := fs.String("out", "", "file to save the export to, - for stdout (default: named after the user and date)")
+		id, _, err := resolveUserID(args[1:], fs, "usage: profile export [id] [--format json|zip] [--out FILE]")
+		if err != nil {
+			return err
+		}
+		return exportProfile(id, *format, *out)
+	case "delete":
+		fs := newFlagSet("profile delete")
+		yes := fs.Bool("yes", false, "delete without asking for confirmation")
+		id, _, err := resolveUserID(args[1:], fs, "usage: profile delete [id] [--yes]")
+		if err != nil {
+			return err
+		}
+		return deleteProfile(id, *yes)
+	case "restore":
 		if len(args) != 2 {
-			fmt.Println("Usage: profile weight-history <id>")
-			return
+			return usagef("usage: profile restore <id>")
+		}
+		return restoreProfile(args[1])
+	case "import":
+		if len(args) != 2 {
+			return usagef("usage: profile import <file.json|file.zip|->")
 		}
-		viewWeightHistory(args[1])
+		return importProfile(args[1])
 	default:
-		fmt.Println("Unknown profile command. Available: create, list, select, view, targets, set-targets, weight, weight-history")
+		return usagef("unknown profile command %q. Available: create, list, select, whoami, deselect, view, targets, set-targets, weight, weight-history, export, import, delete, restore", args[0])
 	}
 }
 
-func promptUserProfile() User {
-	reader := bufio.NewReader(os.Stdin)
-	var user User
-
-	fmt.Print("First Name: ")
-	user.FirstName, _ = reader.ReadString('\n')
-	user.FirstName = strings.TrimSpace(user.FirstName)
-
-	fmt.Print("Last Name: ")
-	user.LastName, _ = reader.ReadString('\n')
-	user.LastName = strings.TrimSpace(user.LastName)
+// resolveUserID parses the command's flags and returns the user ID given as
+// positional argument, or the selected user's ID when it is omitted. Flags
+// must be declared on fs before calling it; fs may be nil for commands
+// without flags.
+func resolveUserID(args []string, fs *flag.FlagSet, usage string) (uint, map[string]bool, error) {
+	if fs == nil {
+		fs = newFlagSet("profile")
+	}
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 0, nil, err
+	}
 
-	fmt.Print("Age: ")
-	fmt.Scanf("%d\n", &user.Age)
+	switch len(positional) {
+	case 1:
+		userID, err := parseID(positional[0])
+		return userID, flagsSet(fs), err
+	case 0:
+		userID := getCurrentUserID()
+		if userID == 0 {
+			return 0, nil, usagef("no user selected. Use 'profile select <id>' to select one or pass an ID")
+		}
+		return userID, flagsSet(fs), nil
+	default:
+		return 0, nil, usagef("%s", usage)
+	}
+}
 
-	fmt.Print("Weight (kg): ")
-	fmt.Scanf("%f\n", &user.Weight)
+func parseID(id string) (uint, error) {
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || n == 0 {
+		return 0, usagef("invalid ID %q", id)
+	}
+	return uint(n), nil
+}
 
-	fmt.Print("Height (cm): ")
-	fmt.Scanf("%d\n", &user.Height)
+func promptUserProfile(args []string) (client.User, error) {
+	var user client.User
+
+	fs := newFlagSet("profile create")
+	fs.StringVar(&user.FirstName, "first-name", "", "first name")
+	fs.StringVar(&user.LastName, "last-name", "", "last name")
+	fs.IntVar(&user.Age, "age", 0, "age in years")
+	fs.Float64Var(&user.Weight, "weight", 0, "weight in kg")
+	fs.IntVar(&user.Height, "height", 0, "height in cm")
+	fs.IntVar(&user.Sex, "sex", 0, "0 for female, 1 for male")
+	fs.IntVar(&user.ActivityLevel, "activity", 0, "days of physical activity per week")
+	fs.StringVar(&user.Goal, "goal", "", "goal, e.g. weight loss")
+	if positional, err := parseFlags(fs, args); err != nil {
+		return user, err
+	} else if len(positional) > 0 {
+		return user, usagef("usage: profile create [--first-name --last-name --age --weight --height --sex --activity --goal]")
+	}
+	set := flagsSet(fs)
+
+	var err error
+	if !set["first-name"] {
+		if user.FirstName, err = promptString("First Name: ", "first-name"); err != nil {
+			return user, err
+		}
+	}
 
-	fmt.Print("Sex (0 for female, 1 for male): ")
-	fmt.Scanf("%d\n", &user.Sex)
+	if !set["last-name"] {
+		if user.LastName, err = promptString("Last Name: ", "last-name"); err != nil {
+			return user, err
+		}
+	}
 
-	fmt.Print("Activity Level (0-7 days per week): ")
-	for {
-		fmt.Scanf("%d\n", &user.ActivityLevel)
-		if user.ActivityLevel >= 0 && user.ActivityLevel <= 7 {
-			break
+	if !set["age"] {
+		if user.Age, err = promptInt("Age: ", "age"); err != nil {
+			return user, err
 		}
-		fmt.Print("Please enter a number between 0 and 7: ")
 	}
 
-	fmt.Print("Goal (e.g., weight loss, muscle gain): ")
-	user.Goal, _ = reader.ReadString('\n')
-	user.Goal = strings.TrimSpace(user.Goal)
+	if !set["weight"] {
+		if user.Weight, err = promptFloat("Weight (kg): ", "weight"); err != nil {
+			return user, err
+		}
+	}
 
-	return user
-}
+	if !set["height"] {
+		if user.Height, err = promptInt("Height (cm): ", "height"); err != nil {
+			return user, err
+		}
+	}
 
-func createProfile(user User) {
-	payload, err := json.Marshal(user)
-	if err != nil {
-		fmt.Println("Error creating profile:", err)
-		return
+	if !set["sex"] {
+		if user.Sex, err = promptInt("Sex (0 for female, 1 for male): ", "sex"); err != nil {
+			return user, err
+		}
 	}
 
-	resp, err := http.Post(apiURL+"/users", "application/json", bytes.NewBuffer(payload))
-	if err != nil {
-		fmt.Println("Error creating profile:", err)
-		return
+	if !set["activity"] {
+		label := "Activity Level (0-7 days per week): "
+		for {
+			if user.ActivityLevel, err = promptInt(label, "activity"); err != nil {
+				return user, err
+			}
+			if user.ActivityLevel >= 0 && user.ActivityLevel <= 7 {
+				break
+			}
+			label = "Please enter a number between 0 and 7: "
+		}
+	} else if user.ActivityLevel < 0 || user.ActivityLevel > 7 {
+		return user, usagef("--activity must be between 0 and 7")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		fmt.Println("Error: Server returned", resp.Status)
-		return
+	if !set["goal"] {
+		if user.Goal, err = promptString("Goal (e.g., weight loss, muscle gain): ", "goal"); err != nil {
+			return user, err
+		}
 	}
 
-	fmt.Println("Profile created successfully!")
+	return user, nil
 }
 
-func listProfiles() {
-	resp, err := http.Get(fmt.Sprintf("%s/users", apiURL))
+func createProfile(user client.User) error {
+	created, err := apiClient.CreateUser(context.Background(), user)
 	if err != nil {
-		fmt.Println("Error listing users:", err)
-		return
+		return fmt.Errorf("error creating profile: %w", err)
 	}
-	defer resp.Body.Close()
 
-	var users []User
-	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
+	return render(newUser(*created), func() {
+		fmt.Printf("Profile created successfully! (ID %d)\n", created.ID)
+	})
+}
+
+func listProfiles(paging *listFlags) error {
+	users, err := fetchList(paging, apiClient.ListUsers)
+	if err != nil {
+		return fmt.Errorf("error listing users: %w", err)
 	}
 
-	fmt.Println("Available users:")
-	for _, user := range users {
-		fmt.Printf("ID: %d - %s %s\n", user.ID, user.FirstName, user.LastName)
+	list := make(userList, len(users))
+	for i, user := range users {
+		list[i] = newUser(user)
 	}
+
+	return render(list, func() {
+		fmt.Println("Available users:")
+		for _, user := range list {
+			fmt.Printf("ID: %d - %s %s\n", user.ID, user.FirstName, user.LastName)
+		}
+	})
 }
 
-func selectProfile(id string) {
-	userID, err := strconv.ParseUint(id, 10, 32)
+func selectProfile(id string) error {
+	userID, err := parseID(id)
 	if err != nil {
-		fmt.Println("Invalid user ID")
-		return
+		return err
 	}
 
 	// Verify that the user exists
-	resp, err := http.Get(fmt.Sprintf("%s/users/%s", apiURL, id))
+	user, err := apiClient.GetUser(context.Background(), userID)
+	if client.IsNotFound(err) {
+		return fmt.Errorf("user not found")
+	}
 	if err != nil {
-		fmt.Println("Error selecting user:", err)
-		return
+		return fmt.Errorf("error selecting user: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("User not found")
-		return
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if err := setCurrentUser(userID, name); err != nil {
+		return fmt.Errorf("error saving session: %w", err)
 	}
 
-	if err := setCurrentUserID(uint(userID)); err != nil {
-		fmt.Println("Error saving session:", err)
-		return
-	}
+	return render(currentSessionInfo(), func() {
+		fmt.Printf("Selected user with ID %d\n", userID)
+	})
+}
 
-	fmt.Printf("Selected user with ID %s\n", id)
+// sessionInfo is the result of the commands managing the selected user
+type sessionInfo struct {
+	Selected bool   `json:"selected"`
+	UserID   uint   `json:"userId,omitempty"`
+	Name     string `json:"name,omitempty"`
 }
 
-func viewProfile(id string) {
-	resp, err := http.Get(fmt.Sprintf("%s/users/%s", apiURL, id))
-	if err != nil {
-		fmt.Println("Error getting user profile:", err)
-		return
+func currentSessionInfo() sessionInfo {
+	userID := getCurrentUserID()
+	return sessionInfo{Selected: userID != 0, UserID: userID, Name: getCurrentUserName()}
+}
+
+func (s sessionInfo) csvHeader() []string {
+	return []string{"selected", "userId", "name"}
+}
+
+func (s sessionInfo) csvRows() [][]string {
+	return [][]string{{strconv.FormatBool(s.Selected), formatUint(s.UserID), s.Name}}
+}
+
+func whoami() error {
+	session := currentSessionInfo()
+	return render(session, func() {
+		switch {
+		case !session.Selected:
+			fmt.Println("No user selected. Use 'profile select <id>' to select one.")
+		case session.Name != "":
+			fmt.Printf("Selected user: %s (ID %d)\n", session.Name, session.UserID)
+		default:
+			fmt.Printf("Selected user: ID %d\n", session.UserID)
+		}
+	})
+}
+
+func deselectProfile() error {
+	wasSelected := getCurrentUserID() != 0
+	if wasSelected {
+		if err := clearSession(); err != nil {
+			return fmt.Errorf("error clearing session: %w", err)
+		}
 	}
-	defer resp.Body.Close()
 
-	var user User
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
+	return render(currentSessionInfo(), func() {
+		if wasSelected {
+			fmt.Println("User deselected")
+		} else {
+			fmt.Println("No user selected.")
+		}
+	})
+}
+
+// deleteProfile deletes a user after confirmation, unless yes is set, and
+// deselects them
+func deleteProfile(id uint, yes bool) error {
+	if !yes {
+		answer, err := promptString(fmt.Sprintf("Delete user %d with all their meals, targets and weight records? [y/N] ", id), "yes")
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			info("Nothing deleted\n")
+			return nil
+		}
 	}
 
-	gender := "Female"
-	if user.Sex == 1 {
-		gender = "Male"
+	deletion, err := apiClient.DeleteUser(context.Background(), id)
+	if client.IsNotFound(err) {
+		return fmt.Errorf("user not found")
 	}
+	if err != nil {
+		return fmt.Errorf("error deleting profile: %w", err)
+	}
+	if getCurrentUserID() == id {
+		if err := clearSession(); err != nil {
+			return fmt.Errorf("error clearing session: %w", err)
+		}
+	}
+
+	result := userDeletion(*deletion)
+	return render(result, func() {
+		fmt.Printf("Deleted user %d. Use 'profile restore %d' to bring them back until %s.\n",
+			id, id, result.RestoreUntil.Local().Format("2006-01-02 15:04"))
+	})
+}
 
-	fmt.Printf("Name: %s %s\nAge: %d\nHeight: %d cm\nWeight: %.2f kg\nGender: %s\n",
-		user.FirstName, user.LastName, user.Age, user.Height, user.Weight, gender)
+// userDeletion is the result of 'profile delete'
+type userDeletion client.UserDeletion
 
-	// Afficher les statistiques de l'utilisateur
-	viewUserStats(id)
+func (d userDeletion) csvHeader() []string {
+	return []string{"userId", "deletedAt", "restoreUntil"}
 }
 
-func viewUserStats(id string) {
-	resp, err := http.Get(fmt.Sprintf("%s/users/%s/stats", apiURL, id))
+func (d userDeletion) csvRows() [][]string {
+	return [][]string{{formatUint(d.UserID), d.DeletedAt.Format(time.RFC3339), d.RestoreUntil.Format(time.RFC3339)}}
+}
+
+func restoreProfile(id string) error {
+	userID, err := parseID(id)
 	if err != nil {
-		fmt.Println("Error getting user stats:", err)
-		return
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Error: Server returned", resp.Status)
-		return
+	user, err := apiClient.RestoreUser(context.Background(), userID)
+	if err != nil {
+		return fmt.Errorf("error restoring profile: %w", err)
 	}
 
-	var stats struct {
-		Height int     `json:"height"`
-		Weight float64 `json:"weight"`
-		BMI    float64 `json:"bmi"`
-		BFP    float64 `json:"bfp"`
-		IMG    float64 `json:"img"`
-		BMR    float64 `json:"bmr"`
-	}
+	return render(newUser(*user), func() {
+		fmt.Printf("Restored user %s %s (ID %d)\n", user.FirstName, user.LastName, user.ID)
+	})
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
-		fmt.Println("Error parsing stats response:", err)
-		return
-	}
+// profileView is the result of 'profile view'
+type profileView struct {
+	User
+	Stats UserStats `json:"stats"`
+}
+
+func (v profileView) csvHeader() []string {
+	return append(v.User.csvHeader(), "bmi", "bfp", "img", "bmr")
+}
 
-	fmt.Println("\nHealth Statistics:")
-	fmt.Printf("BMI: %.2f\n", stats.BMI)
-	fmt.Printf("Body Fat Percentage: %.2f%%\n", stats.BFP)
-	fmt.Printf("Body Fat Mass Index: %.2f\n", stats.IMG)
-	fmt.Printf("Basal Metabolic Rate: %.2f kcal/day\n", stats.BMR)
+func (v profileView) csvRows() [][]string {
+	row := append(v.User.csvRow(), formatFloat(v.Stats.BMI), formatFloat(v.Stats.BFP),
+		formatFloat(v.Stats.IMG), formatFloat(v.Stats.BMR))
+	return [][]string{row}
 }
 
-func viewTargets(id string) {
-	resp, err := http.Get(fmt.Sprintf("%s/users/%s/targets", apiURL, id))
+func viewProfile(id uint) error {
+	ctx := context.Background()
+
+	user, err := apiClient.GetUser(ctx, id)
 	if err != nil {
-		fmt.Println("Error getting user targets:", err)
-		return
+		return fmt.Errorf("error getting user profile: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		fmt.Println("No targets found for this user. Use 'profile set-targets <id>' to set targets.")
-		return
+	// Récupérer les statistiques de l'utilisateur
+	stats, err := apiClient.GetUserStats(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error getting user stats: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Error: Server returned", resp.Status)
-		return
-	}
+	view := profileView{User: newUser(*user), Stats: newUserStats(*stats)}
+	return render(view, func() {
+		user, stats := view.User, view.Stats
+		gender := "Female"
+		if user.Sex == 1 {
+			gender = "Male"
+		}
 
-	var target Target
-	if err := json.NewDecoder(resp.Body).Decode(&target); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
-	}
+		fmt.Printf("Name: %s %s\nAge: %d\nHeight: %d cm\nWeight: %.2f kg\nGender: %s\n",
+			user.FirstName, user.LastName, user.Age, user.Height, user.Weight, gender)
 
-	today := time.Now().Format("2006-01-02")
-	mealsResp, err := http.Get(fmt.Sprintf("%s/meals/user/%s?date=%s", apiURL, id, today))
-	if err != nil {
-		fmt.Println("Error getting today's meals:", err)
-		return
-	}
-	defer mealsResp.Body.Close()
+		fmt.Println("\nHealth Statistics:")
+		fmt.Printf("BMI: %.2f\n", stats.BMI)
+		fmt.Printf("Body Fat Percentage: %.2f%%\n", stats.BFP)
+		fmt.Printf("Body Fat Mass Index: %.2f\n", stats.IMG)
+		fmt.Printf("Basal Metabolic Rate: %.2f kcal/day\n", stats.BMR)
+	})
+}
 
-	var meals []struct {
-		ID    uint   `json:"id"`
-		Type  string `json:"type"`
-		Foods []Food `json:"foods"`
-	}
+// targetProgress is the result of 'profile targets': the day's intake against
+// the user's targets
+type targetProgress struct {
+	Date     string    `json:"date"`
+	Targets  Target    `json:"targets"`
+	Consumed Nutrients `json:"consumed"`
+	Meals    []Meal    `json:"meals"`
+}
 
-	if err := json.NewDecoder(mealsResp.Body).Decode(&meals); err != nil {
-		fmt.Println("Error parsing meals response:", err)
-		return
-	}
+func (p targetProgress) csvHeader() []string {
+	return []string{"date", "nutrient", "consumed", "target", "percent"}
+}
 
-	var consumed struct {
-		Calories float64
-		Protein  float64
-		Carbs    float64
-		Fat      float64
-		Fiber    float64
+func (p targetProgress) csvRows() [][]string {
+	rows := [][]string{}
+	for _, n := range p.nutrients() {
+		rows = append(rows, []string{p.Date, n.name, formatFloat(n.consumed), formatFloat(n.target),
+			strconv.FormatFloat(percentOf(n.consumed, n.target), 'f', 1, 64)})
 	}
+	return rows
+}
 
-	for _, meal := range meals {
-		for _, food := range meal.Foods {
-			consumed.Calories += food.Calories
-			consumed.Protein += food.Protein
-			consumed.Carbs += food.Carbs
-			consumed.Fat += food.Fat
-			consumed.Fiber += food.Fiber
-		}
-	}
+type nutrientProgress struct {
+	name     string
+	consumed float64
+	target   float64
+}
 
-	fmt.Println("\nDaily Nutrition Targets and Progress:")
-	fmt.Printf("Calories: %.0f/%.0f kcal (%.1f%%)\n", consumed.Calories, target.Calories, (consumed.Calories/target.Calories)*100)
-	fmt.Printf("Protein:  %.1f/%.1f g (%.1f%%)\n", consumed.Protein, target.Protein, (consumed.Protein/target.Protein)*100)
-	fmt.Printf("Carbs:    %.1f/%.1f g (%.1f%%)\n", consumed.Carbs, target.Carbs, (consumed.Carbs/target.Carbs)*100)
-	fmt.Printf("Fat:      %.1f/%.1f g (%.1f%%)\n", consumed.Fat, target.Fat, (consumed.Fat/target.Fat)*100)
-	fmt.Printf("Fiber:    %.1f/%.1f g (%.1f%%)\n", consumed.Fiber, target.Fiber, (consumed.Fiber/target.Fiber)*100)
+func (p targetProgress) nutrients() []nutrientProgress {
+	return []nutrientProgress{
+		{"calories", p.Consumed.Calories, p.Targets.Calories},
+		{"protein", p.Consumed.Protein, p.Targets.Protein},
+		{"carbs", p.Consumed.Carbs, p.Targets.Carbs},
+		{"fat", p.Consumed.Fat, p.Targets.Fat},
+		{"fiber", p.Consumed.Fiber, p.Targets.Fiber},
+	}
+}
 
-	fmt.Println("\nToday's Meals:")
-	for _, meal := range meals {
-		fmt.Printf("\n%s:\n", meal.Type)
-		for _, food := range meal.Foods {
-			fmt.Printf("- %s (%.0f kcal)\n", food.Name, food.Calories)
-		}
+func percentOf(value, total float64) float64 {
+	if total == 0 {
+		return 0
 	}
+	return value / total * 100
 }
 
-func setTargets(id string) {
-	target := promptUserTargets()
+func viewTargets(id uint) error {
+	ctx := context.Background()
 
-	payload, err := json.Marshal(target)
+	target, err := apiClient.GetTargets(ctx, id)
+	if client.IsNotFound(err) {
+		return fmt.Errorf("no targets found for this user. Use 'profile set-targets [id]' to set targets")
+	}
 	if err != nil {
-		fmt.Println("Error preparing targets:", err)
-		return
+		return fmt.Errorf("error getting user targets: %w", err)
 	}
 
-	resp, err := http.Post(fmt.Sprintf("%s/users/%s/targets", apiURL, id), "application/json", bytes.NewBuffer(payload))
+	today := time.Now()
+	meals, err := apiClient.GetUserMeals(ctx, id, client.MealFilter{Date: today}, client.ListOptions{})
 	if err != nil {
-		fmt.Println("Error setting targets:", err)
-		return
+		return fmt.Errorf("error getting today's meals: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Error: Server returned", resp.Status)
-		return
+	progress := targetProgress{
+		Date:    today.Format("2006-01-02"),
+		Targets: newTarget(*target),
+		Meals:   newMealList(meals.Items),
+	}
+	for _, meal := range progress.Meals {
+		for _, food := range meal.Foods {
+			progress.Consumed.add(food)
+		}
 	}
 
-	fmt.Println("Targets set successfully!")
+	return render(progress, func() {
+		c, t := progress.Consumed, progress.Targets
+		fmt.Println("\nDaily Nutrition Targets and Progress:")
+		// The bars fill the room left by the longest line, e.g.
+		// "Calories: 1850/2000 kcal (92.5%) "
+		width := min(max(terminalWidth()-36, 10), 40)
+		fmt.Printf("Calories: %-20s %s\n", fmt.Sprintf("%.0f/%.0f kcal (%.1f%%)", c.Calories, t.Calories, percentOf(c.Calories, t.Calories)), progressBar(c.Calories, t.Calories, width))
+		fmt.Printf("Protein:  %-20s %s\n", fmt.Sprintf("%.1f/%.1f g (%.1f%%)", c.Protein, t.Protein, percentOf(c.Protein, t.Protein)), progressBar(c.Protein, t.Protein, width))
+		fmt.Printf("Carbs:    %-20s %s\n", fmt.Sprintf("%.1f/%.1f g (%.1f%%)", c.Carbs, t.Carbs, percentOf(c.Carbs, t.Carbs)), progressBar(c.Carbs, t.Carbs, width))
+		fmt.Printf("Fat:      %-20s %s\n", fmt.Sprintf("%.1f/%.1f g (%.1f%%)", c.Fat, t.Fat, percentOf(c.Fat, t.Fat)), progressBar(c.Fat, t.Fat, width))
+		fmt.Printf("Fiber:    %-20s %s\n", fmt.Sprintf("%.1f/%.1f g (%.1f%%)", c.Fiber, t.Fiber, percentOf(c.Fiber, t.Fiber)), progressBar(c.Fiber, t.Fiber, width))
+
+		fmt.Println("\nToday's Meals:")
+		for _, meal := range progress.Meals {
+			fmt.Printf("\n%s:\n", meal.Type)
+			for _, food := range meal.Foods {
+				fmt.Printf("- %s, %s (%.0f kcal)\n", food.Name, food.quantity(), food.eaten().Calories)
+			}
+		}
+	})
 }
 
-func promptUserTargets() Target {
-	var target Target
-
-	fmt.Print("Daily Calories Target (kcal): ")
-	fmt.Scanf("%f\n", &target.Calories)
+func setTargets(id uint, target *client.Target, set map[string]bool) error {
+	if err := promptUserTargets(target, set); err != nil {
+		return err
+	}
 
-	fmt.Print("Daily Protein Target (g): ")
-	fmt.Scanf("%f\n", &target.Protein)
+	saved, err := apiClient.SetTargets(context.Background(), id, *target)
+	if err != nil {
+		return fmt.Errorf("error setting targets: %w", err)
+	}
 
-	fmt.Print("Daily Carbs Target (g): ")
-	fmt.Scanf("%f\n", &target.Carbs)
+	return render(newTarget(*saved), func() {
+		fmt.Println("Targets set successfully!")
+	})
+}
 
-	fmt.Print("Daily Fat Target (g): ")
-	fmt.Scanf("%f\n", &target.Fat)
+// targetFlags declares the flags of 'profile set-targets' on fs
+func targetFlags(fs *flag.FlagSet) *client.Target {
+	var target client.Target
+	fs.Float64Var(&target.Calories, "calories", 0, "daily calories target (kcal)")
+	fs.Float64Var(&target.Protein, "protein", 0, "daily protein target (g)")
+	fs.Float64Var(&target.Carbs, "carbs", 0, "daily carbs target (g)")
+	fs.Float64Var(&target.Fat, "fat", 0, "daily fat target (g)")
+	fs.Float64Var(&target.Fiber, "fiber", 0, "daily fiber target (g)")
+	return &target
+}
 
-	fmt.Print("Daily Fiber Target (g): ")
-	fmt.Scanf("%f\n", &target.Fiber)
+// promptUserTargets asks for the targets not given as flags
+func promptUserTargets(target *client.Target, set map[string]bool) error {
+	fields := []struct {
+		flag  string
+		label string
+		value *float64
+	}{
+		{"calories", "Daily Calories Target (kcal): ", &target.Calories},
+		{"protein", "Daily Protein Target (g): ", &target.Protein},
+		{"carbs", "Daily Carbs Target (g): ", &target.Carbs},
+		{"fat", "Daily Fat Target (g): ", &target.Fat},
+		{"fiber", "Daily Fiber Target (g): ", &target.Fiber},
+	}
+
+	for _, field := range fields {
+		if set[field.flag] {
+			continue
+		}
+		v, err := promptFloat(field.label, field.flag)
+		if err != nil {
+			return err
+		}
+		*field.value = v
+	}
 
-	return target
+	return nil
 }
 
-func recordWeight(id string) {
-	var weight float64
-	var note string
+func recordWeight(id uint, weight float64, note, dateStr string, set map[string]bool) error {
+	date, err := parseDate(dateStr)
+	if err != nil {
+		return err
+	}
 
-	fmt.Print("Enter your weight (kg): ")
-	fmt.Scanf("%f\n", &weight)
+	if !set["weight"] {
+		if weight, err = promptFloat("Enter your weight (kg): ", "weight"); err != nil {
+			return err
+		}
+	}
 
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Enter a note (optional, press Enter to skip): ")
-	note, _ = reader.ReadString('\n')
-	note = strings.TrimSpace(note)
+	if !set["note"] && !noInput {
+		if note, err = promptString("Enter a note (optional, press Enter to skip): ", "note"); err != nil {
+			return err
+		}
+	}
 
-	record := WeightRecord{
+	record := client.WeightRecord{
 		Weight: weight,
-		Date:   time.Now(),
+		Date:   date,
 		Note:   note,
 	}
 
-	payload, err := json.Marshal(record)
+	saved, err := apiClient.RecordWeight(context.Background(), id, record)
 	if err != nil {
-		fmt.Println("Error preparing weight record:", err)
-		return
-	}
-
-	resp, err := http.Post(fmt.Sprintf("%s/users/%s/weight", apiURL, id), "application/json", bytes.NewBuffer(payload))
-	if err != nil {
-		fmt.Println("Error recording weight:", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Error: Server returned", resp.Status)
-		return
+		return fmt.Errorf("error recording weight: %w", err)
 	}
 
-	fmt.Println("Weight recorded successfully!")
+	return render(newWeightRecord(*saved), func() {
+		fmt.Println("Weight recorded successfully!")
+	})
 }
 
-func viewWeightHistory(id string) {
-	resp, err := http.Get(fmt.Sprintf("%s/users/%s/weight/history", apiURL, id))
+func viewWeightHistory(id uint, dates *dateRangeFlags, paging *listFlags) error {
+	dateRange, err := dates.dateRange()
 	if err != nil {
-		fmt.Println("Error getting weight history:", err)
-		return
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Error: Server returned", resp.Status)
-		return
+	history, err := fetchList(paging, func(ctx context.Context, opts client.ListOptions) (*client.Page[client.WeightRecord], error) {
+		return apiClient.GetWeightHistory(ctx, id, dateRange, opts)
+	})
+	if err != nil {
+		return fmt.Errorf("error getting weight history: %w", err)
 	}
 
-	var records []WeightRecord
-	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
-		fmt.Println("Error parsing response:", err)
-		return
+	records := make(weightHistory, len(history))
+	for i, record := range history {
+		records[i] = newWeightRecord(record)
 	}
 
-	if len(records) == 0 {
-		fmt.Println("No weight records found.")
-		return
-	}
+	return render(records, func() {
+		if len(records) == 0 {
+			fmt.Println("No weight records found.")
+			return
+		}
 
-	fmt.Println("\nWeight History:")
-	fmt.Println("Date\t\tWeight\tNote")
-	fmt.Println("----------------------------------------")
-	for _, record := range records {
-		dateStr := record.Date.Format("2006-01-02")
-		fmt.Printf("%s\t%.1f kg\t%s\n", dateStr, record.Weight, record.Note)
-	}
+		fmt.Println("\nWeight History:")
+		if len(records) > 1 {
+			fmt.Println(weightTrend(records, time.Time{}, time.Time{}, terminalWidth()))
+		}
+		fmt.Println("Date\t\tWeight\tNote")
+		fmt.Println("----------------------------------------")
+		for _, record := range records {
+			dateStr := record.Date.Format("2006-01-02")
+			fmt.Printf("%s\t%.1f kg\t%s\n", dateStr, record.Weight, record.Note)
+		}
+	})
 }
@@ -0,0 +1,391 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+	"golang.org/x/term"
+)
+
+// maxHistory is the number of lines kept in the REPL's history file
+const maxHistory = 500
+
+// shellWords splits line into words like a shell does: quotes keep spaces in
+// a word, a backslash escapes the next character outside single quotes. It
+// also returns the quote left open, if any, whether the line ends inside a
+// word and the offset that word starts at.
+func shellWords(line string) (words []string, quote rune, inWord bool, start int) {
+	var word strings.Builder
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		default:
+			word.WriteRune(r)
+		}
+		if !inWord {
+			inWord, start = true, i
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, quote, inWord, start
+}
+
+// splitArgs splits a line of the REPL into arguments, e.g.
+// `meal add lunch today "brown rice"` into 4 of them
+func splitArgs(line string) ([]string, error) {
+	words, quote, _, _ := shellWords(line)
+	if quote != 0 {
+		return nil, usagef("missing closing %c", quote)
+	}
+	return words, nil
+}
+
+// quoteWord quotes a word containing spaces or quotes so that shellWords
+// reads it back, leaving the quote open unless closed is set
+func quoteWord(word string, quote rune, closed bool) string {
+	if quote == 0 && !strings.ContainsAny(word, " \t'\"\\") {
+		return word
+	}
+	if quote == 0 {
+		quote = '"'
+	}
+	if quote == '"' {
+		word = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(word)
+	} else {
+		word = strings.ReplaceAll(word, `'`, `'\''`)
+	}
+	if closed {
+		return string(quote) + word + string(quote)
+	}
+	return string(quote) + word
+}
+
+// lineReader reads the lines of the REPL
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads lines piped to the REPL
+type plainReader struct{}
+
+func (plainReader) readLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	return readLine()
+}
+
+// replIO is the terminal of the line editor
+type replIO struct {
+	io.Reader
+	io.Writer
+}
+
+// lineEditor reads the lines of the REPL from a terminal, which it puts in
+// raw mode while a line is typed: they can be edited, taken from the history
+// with the up and down keys and completed with tab
+type lineEditor struct {
+	terminal *term.Terminal
+	// recentFoods are the names of the foods the user logged lately, fetched
+	// on the first completion of a food after each line
+	recentFoods []string
+}
+
+func newLineReader() lineReader {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return plainReader{}
+	}
+
+	e := &lineEditor{terminal: term.NewTerminal(&replIO{Reader: stdin, Writer: os.Stdout}, "")}
+	e.terminal.History = &replHistory{lines: loadHistory()}
+	e.terminal.AutoCompleteCallback = e.complete
+	return e
+}
+
+// replHistory holds the lines the line editor takes from with the up and
+// down keys, those of the history file first
+type replHistory struct {
+	// lines are the last maxHistory lines, oldest first
+	lines []string
+}
+
+func (h *replHistory) Add(line string) {
+	if line = strings.TrimSpace(line); line == "" {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > maxHistory {
+		h.lines = slices.Delete(h.lines, 0, len(h.lines)-maxHistory)
+	}
+}
+
+func (h *replHistory) Len() int {
+	return len(h.lines)
+}
+
+// At returns the line i lines before the last one
+func (h *replHistory) At(i int) string {
+	return h.lines[len(h.lines)-1-i]
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, state)
+	if width, height, err := term.GetSize(fd); err == nil {
+		e.terminal.SetSize(width, height)
+	}
+
+	e.terminal.SetPrompt(prompt)
+	line, err := e.terminal.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	e.recentFoods = nil
+	line = strings.TrimSpace(line)
+	if err := appendHistory(line); err != nil {
+		fmt.Fprintf(e.terminal, "Error saving history: %v\n", err)
+	}
+	return line, nil
+}
+
+// complete completes the word before the cursor when tab is pressed, or
+// lists the candidates when there are several
+func (e *lineEditor) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	head, tail := line[:pos], line[pos:]
+	words, quote, inWord, start := shellWords(head)
+	partial := ""
+	if inWord {
+		partial, words = words[len(words)-1], words[:len(words)-1]
+	} else {
+		start = len(head)
+	}
+
+	var matches []string
+	for _, candidate := range e.candidates(words) {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(partial)) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", 0, false
+	case 1:
+		head = head[:start] + quoteWord(matches[0], quote, true) + " "
+		return head + tail, len(head), true
+	}
+
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(strings.ToLower(match), strings.ToLower(common)) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(partial) {
+		head = head[:start] + quoteWord(common, quote, false)
+		return head + tail, len(head), true
+	}
+	fmt.Fprintln(e.terminal, strings.Join(matches, "  "))
+	return "", 0, false
+}
+
+// candidates returns the words that may follow words on the command line
+func (e *lineEditor) candidates(words []string) []string {
+	if len(words) > 0 && words[0] == "help" {
+		words = words[1:]
+		if len(words) > 1 {
+			return nil
+		}
+	}
+	switch len(words) {
+	case 0:
+		return commandNames(nil)
+	case 1:
+		if subcommands := commandNames(words[:1]); len(subcommands) > 0 {
+			return subcommands
+		}
+	}
+
+	switch words[len(words)-1] {
+	case "--type":
+		return mealTypeNames()
+	case "--date", "--from", "--to":
+		return []string{"today", "yesterday"}
+	case "--food":
+		return e.foods()
+	}
+	if words[0] == "meal" && len(words) > 1 && slices.Contains([]string{"add", "view", "list"}, words[1]) {
+		// The positional arguments of the meal commands, skipping the flags
+		// and their values
+		n := 0
+		for i, word := range words[2:] {
+			if !strings.HasPrefix(word, "-") && (i == 0 || !strings.HasPrefix(words[i+1], "--")) {
+				n++
+			}
+		}
+		switch {
+		case n == 0:
+			return mealTypeNames()
+		case n == 1:
+			return []string{"today", "yesterday"}
+		case n == 2 && words[1] == "add":
+			return e.foods()
+		}
+	}
+	return nil
+}
+
+// commandNames returns the commands, or the subcommands of the command given
+func commandNames(command []string) []string {
+	var names []string
+	for _, help := range commandHelps {
+		usage := strings.Fields(help.usage)
+		if len(usage) <= len(command) || !slices.Equal(usage[:len(command)], command) {
+			continue
+		}
+		for _, name := range subcommandNames(usage[len(command)]) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+var subcommandPattern = regexp.MustCompile(`^[a-z][a-z-]*$`)
+
+// subcommandNames returns the names a word of a usage stands for: itself, or
+// its choices, e.g. weekly and monthly for "<weekly|monthly>". Other
+// arguments have none.
+func subcommandNames(word string) []string {
+	if !strings.HasPrefix(word, "<") {
+		if strings.HasPrefix(word, "[") {
+			return nil
+		}
+		return []string{word}
+	}
+	choices := strings.Split(strings.Trim(word, "<>"), "|")
+	for _, choice := range choices {
+		if !subcommandPattern.MatchString(choice) {
+			return nil
+		}
+	}
+	return choices
+}
+
+func mealTypeNames() []string {
+	names := make([]string, len(client.MealTypes))
+	for i, mealType := range client.MealTypes {
+		names[i] = string(mealType)
+	}
+	return names
+}
+
+// foods returns the names of the foods the selected user logged lately
+func (e *lineEditor) foods() []string {
+	userID := getCurrentUserID()
+	if e.recentFoods == nil && userID != 0 {
+		e.recentFoods = []string{}
+		recent, err := apiClient.RecentFoods(context.Background(), userID, client.FoodUsageFilter{Limit: 20})
+		if err != nil {
+			return nil
+		}
+		for _, usage := range recent {
+			e.recentFoods = append(e.recentFoods, usage.Food.Name)
+		}
+	}
+	return e.recentFoods
+}
+
+func historyFilePath() (string, error) {
+	sessionFile, err := sessionFilePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(sessionFile), "history"), nil
+}
+
+// loadHistory returns the lines of the history file, oldest first, trimming
+// the file to the last maxHistory lines
+func loadHistory() []string {
+	historyFile, err := historyFilePath()
+	if err != nil {
+		return nil
+	}
+	file, err := os.Open(historyFile)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		if err := os.WriteFile(historyFile, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error trimming history: %v\n", err)
+		}
+	}
+	return lines
+}
+
+// appendHistory adds a line to the history file. Like the commands, the
+// lines may hold health data: the file is kept private.
+func appendHistory(line string) error {
+	if line == "" {
+		return nil
+	}
+	historyFile, err := historyFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(historyFile), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
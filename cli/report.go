@@ -0,0 +1,340 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
+)
+
+var (
+	isoWeekPattern = regexp.MustCompile(`^\d{4}-W\d{2}$`)
+	monthPattern   = regexp.MustCompile(`^\d{4}-\d{2}$`)
+)
+
+// handleReportCommand prints the selected user's weekly or monthly nutrition
+// report, or saves it as Markdown or HTML, e.g. `report weekly 2024-W10
+// --out week.md`
+func handleReportCommand(args []string) error {
+	if len(args) > 0 && args[0] == "print" {
+		return handlePrintableReport(args[1:])
+	}
+	fs := newFlagSet("report")
+	tolerance := fs.Float64("tolerance", 10, "percentage of a target a day may miss it by and still adhere to it")
+	out := fs.String("out", "", "file to save the report to, as Markdown (.md) or HTML (.html)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 || len(positional) > 2 || (positional[0] != client.WeeklyReport && positional[0] != client.MonthlyReport) {
+		return usagef("usage: report <weekly|monthly> [week|month|date] [--tolerance PCT --out FILE.md|FILE.html]\n       report print [--from --to --format pdf|html --out FILE]")
+	}
+	period := positional[0]
+	opts := client.ReportOptions{Tolerance: tolerance}
+	if len(positional) == 2 {
+		if opts.Period, err = reportPeriod(period, positional[1]); err != nil {
+			return err
+		}
+	}
+	var write func(*bytes.Buffer, reportView) error
+	switch strings.ToLower(filepath.Ext(*out)) {
+	case "":
+		if *out != "" {
+			return usagef("report files must end with .md or .html")
+		}
+	case ".md", ".markdown":
+		write = writeMarkdownReport
+	case ".html", ".htm":
+		write = writeHTMLReport
+	default:
+		return usagef("report files must end with .md or .html")
+	}
+	userID, err := selectedUserID()
+	if err != nil {
+		return err
+	}
+
+	report, err := apiClient.GetReport(context.Background(), userID, period, opts)
+	if err != nil {
+		return fmt.Errorf("error getting report: %w", err)
+	}
+	view := newReportView(*report)
+	if write != nil {
+		var data bytes.Buffer
+		if err := write(&data, view); err != nil {
+			return fmt.Errorf("error rendering report: %w", err)
+		}
+		if err := os.WriteFile(*out, data.Bytes(), 0600); err != nil {
+			return fmt.Errorf("error saving report: %w", err)
+		}
+		info("Saved the %s report to %s\n", report.Label, *out)
+		return nil
+	}
+	return render(nutritionReport{*report}, func() {
+		printReport(view)
+	})
+}
+
+// handlePrintableReport saves the selected user's printable report of a
+// period as a PDF or an HTML page, e.g. `report print --from 2024-03-01
+// --out march.pdf`
+func handlePrintableReport(args []string) error {
+	fs := newFlagSet("report print")
+	dates := addDateRangeFlags(fs)
+	format := fs.String("format", "", "pdf or html (default: from the extension of --out, else pdf)")
+	out := fs.String("out", "", "file to save the report to (default: named after the user and the period)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usagef("usage: report print [--from --to --format pdf|html --out FILE]")
+	}
+	if *format == "" {
+		*format = client.PrintablePDF
+		if ext := strings.ToLower(filepath.Ext(*out)); ext == ".html" || ext == ".htm" {
+			*format = client.PrintableHTML
+		}
+	}
+	if *format != client.PrintablePDF && *format != client.PrintableHTML {
+		return usagef("invalid format %q: must be pdf or html", *format)
+	}
+	dateRange, err := dates.dateRange()
+	if err != nil {
+		return err
+	}
+	userID, err := selectedUserID()
+	if err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	filename, err := apiClient.PrintableReport(context.Background(), userID, *format, dateRange, &data)
+	if err != nil {
+		return fmt.Errorf("error getting report: %w", err)
+	}
+	if *out == "" {
+		*out = filename
+		if *out == "" {
+			*out = fmt.Sprintf("report-%d.%s", userID, *format)
+		}
+	}
+	// The report holds the user's health data, keep it private
+	if err := os.WriteFile(*out, data.Bytes(), 0600); err != nil {
+		return fmt.Errorf("error saving report: %w", err)
+	}
+	info("Saved the report to %s\n", *out)
+	return nil
+}
+
+// reportPeriod returns the ISO week or the month of a report given as such,
+// or as a day within it
+func reportPeriod(period, arg string) (string, error) {
+	if (period == client.WeeklyReport && isoWeekPattern.MatchString(arg)) ||
+		(period == client.MonthlyReport && monthPattern.MatchString(arg)) {
+		return arg, nil
+	}
+	day, err := parseDate(arg)
+	if err != nil {
+		return "", usagef("invalid period. Use YYYY-Www for a week, YYYY-MM for a month, or a day: YYYY-MM-DD, today or yesterday")
+	}
+	if period == client.MonthlyReport {
+		return day.Format("2006-01"), nil
+	}
+	year, week := day.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week), nil
+}
+
+// nutritionReport is the result of `report`, with a CSV row per day
+type nutritionReport struct {
+	client.NutritionReport
+}
+
+func (r nutritionReport) csvHeader() []string {
+	return []string{"date", "meals", "entries", "calories", "protein", "carbs", "fat", "fiber", "deviation"}
+}
+
+func (r nutritionReport) csvRows() [][]string {
+	rows := make([][]string, len(r.Days))
+	for i, day := range r.Days {
+		rows[i] = []string{formatDate(day.Date), strconv.Itoa(day.Meals), strconv.Itoa(day.Entries),
+			formatFloat(day.Calories), formatFloat(day.Protein), formatFloat(day.Carbs), formatFloat(day.Fat),
+			formatFloat(day.Fiber), formatOptionalFloat(day.Deviation)}
+	}
+	return rows
+}
+
+// reportView is a report laid out for reading: a table of the days followed
+// by their average, the targets and the adherence to them, printed as text,
+// Markdown or HTML
+type reportView struct {
+	Title   string
+	Summary []string
+	Columns []string
+	Rows    [][]string
+	Notes   []string
+}
+
+func newReportView(r client.NutritionReport) reportView {
+	view := reportView{
+		Title: fmt.Sprintf("%s report %s", strings.ToUpper(r.Period[:1])+r.Period[1:], r.Label),
+		Summary: []string{
+			fmt.Sprintf("From %s to %s", formatDate(r.From), formatDate(r.To)),
+			fmt.Sprintf("Logged %d/%d days (%.0f%%), %d meals, %d foods", r.LoggedDays, len(r.Days), r.Completeness, r.Meals, r.Entries),
+		},
+		Columns: []string{"Date", "Meals", "Calories", "Protein (g)", "Carbs (g)", "Fat (g)", "Fiber (g)"},
+	}
+	amounts := func(n client.Nutrients) []string {
+		return []string{fmt.Sprintf("%.0f", n.Calories), fmt.Sprintf("%.1f", n.Protein), fmt.Sprintf("%.1f", n.Carbs),
+			fmt.Sprintf("%.1f", n.Fat), fmt.Sprintf("%.1f", n.Fiber)}
+	}
+	for _, day := range r.Days {
+		if day.Meals == 0 {
+			view.Rows = append(view.Rows, []string{formatDate(day.Date), "-", "", "", "", "", ""})
+			continue
+		}
+		view.Rows = append(view.Rows, append([]string{formatDate(day.Date), strconv.Itoa(day.Meals)}, amounts(day.Nutrients)...))
+	}
+	view.Rows = append(view.Rows, append([]string{"Average", ""}, amounts(r.Averages)...))
+
+	if r.Targets != nil && r.Adherence != nil {
+		view.Rows = append(view.Rows, append([]string{"Target", ""}, amounts(*r.Targets)...))
+		adherence := []string{fmt.Sprintf("Within %s%%", formatFloat(r.Tolerance)), ""}
+		for _, nutrient := range []struct {
+			target float64
+			days   int
+		}{
+			{r.Targets.Calories, r.Adherence.Calories},
+			{r.Targets.Protein, r.Adherence.Protein},
+			{r.Targets.Carbs, r.Adherence.Carbs},
+			{r.Targets.Fat, r.Adherence.Fat},
+			{r.Targets.Fiber, r.Adherence.Fiber},
+		} {
+			if nutrient.target <= 0 {
+				adherence = append(adherence, "-")
+				continue
+			}
+			adherence = append(adherence, fmt.Sprintf("%d/%d days", nutrient.days, r.LoggedDays))
+		}
+		view.Rows = append(view.Rows, adherence)
+		view.Notes = append(view.Notes, fmt.Sprintf("Days within %s%% of all targets: %d/%d", formatFloat(r.Tolerance), r.Adherence.All, r.LoggedDays))
+	} else {
+		view.Notes = append(view.Notes, "No targets set: use 'profile set-targets' to track your adherence")
+	}
+
+	if r.LoggedDays > 0 {
+		view.Notes = append(view.Notes, fmt.Sprintf("Macro split: protein %.0f%%, carbs %.0f%%, fat %.0f%%",
+			r.MacroSplit.Protein, r.MacroSplit.Carbs, r.MacroSplit.Fat))
+	}
+	for _, day := range []struct {
+		name string
+		day  *client.ReportDay
+	}{{"Best day", r.BestDay}, {"Worst day", r.WorstDay}} {
+		if day.day != nil && day.day.Deviation != nil {
+			view.Notes = append(view.Notes, fmt.Sprintf("%s: %s, %.0f%% off the targets on average (%.0f kcal)",
+				day.name, formatDate(day.day.Date), *day.day.Deviation, day.day.Calories))
+		}
+	}
+	if w := r.Weight; w != nil {
+		view.Notes = append(view.Notes, fmt.Sprintf("Weight: %.1f kg on %s to %.1f kg on %s (%+.1f kg, %d records)",
+			w.Start, formatDate(w.StartDate), w.End, formatDate(w.EndDate), w.Change, w.Records))
+	} else {
+		view.Notes = append(view.Notes, "Weight: no records")
+	}
+	return view
+}
+
+// printReport prints the view as text, its table's columns aligned
+func printReport(view reportView) {
+	fmt.Println(view.Title)
+	for _, line := range view.Summary {
+		fmt.Println(line)
+	}
+
+	widths := make([]int, len(view.Columns))
+	for _, row := range append([][]string{view.Columns}, view.Rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	fmt.Println()
+	for _, row := range append([][]string{view.Columns}, view.Rows...) {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			// Amounts are right-aligned
+			if i == 0 {
+				cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+			} else {
+				cells[i] = fmt.Sprintf("%*s", widths[i], cell)
+			}
+		}
+		fmt.Println(strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+
+	fmt.Println()
+	for _, note := range view.Notes {
+		fmt.Println(note)
+	}
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"row": func(cells []string) string {
+		return "| " + strings.Join(cells, " | ") + " |"
+	},
+	"separator": func(columns []string) string {
+		return "|" + strings.Repeat(" --- |", len(columns))
+	},
+}).Parse(`# {{.Title}}
+{{range .Summary}}
+{{.}}
+{{end}}
+{{row .Columns}}
+{{separator .Columns}}
+{{range .Rows}}{{row .}}
+{{end}}
+{{range .Notes}}- {{.}}
+{{end}}`))
+
+func writeMarkdownReport(w *bytes.Buffer, view reportView) error {
+	return markdownReport.Execute(w, view)
+}
+
+// htmlReport is a self-contained page, to be opened in a browser or printed
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Summary}}<p>{{.}}</p>
+{{end}}<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+<ul>
+{{range .Notes}}<li>{{.}}</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+func writeHTMLReport(w *bytes.Buffer, view reportView) error {
+	return htmlReport.Execute(w, view)
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences of the full-screen mode
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	cursorHome     = "\x1b[H"
+)
+
+// cellStyle is the look of a cell of the screen
+type cellStyle uint8
+
+const (
+	stylePlain cellStyle = iota
+	styleBold
+	styleDim
+	styleReverse
+)
+
+// sgr are the escape sequences selecting each style
+var sgr = map[cellStyle]string{
+	stylePlain:   "\x1b[0m",
+	styleBold:    "\x1b[0;1m",
+	styleDim:     "\x1b[0;2m",
+	styleReverse: "\x1b[0;7m",
+}
+
+type cell struct {
+	r     rune
+	style cellStyle
+}
+
+// canvas is a frame of the full screen, drawn cell by cell before being
+// written at once
+type canvas struct {
+	width, height int
+	cells         [][]cell
+}
+
+func newCanvas(width, height int) *canvas {
+	c := &canvas{width: width, height: height, cells: make([][]cell, height)}
+	for row := range c.cells {
+		c.cells[row] = make([]cell, width)
+		for col := range c.cells[row] {
+			c.cells[row][col] = cell{r: ' '}
+		}
+	}
+	return c
+}
+
+// text writes s from col on row, shortened with an ellipsis to fit in width
+// cells, and returns the number of cells written
+func (c *canvas) text(row, col, width int, s string, style cellStyle) int {
+	if row < 0 || row >= c.height || col >= c.width {
+		return 0
+	}
+	width = min(width, c.width-col)
+	if width <= 0 {
+		return 0
+	}
+	runes := []rune(s)
+	if len(runes) > width {
+		runes = append(runes[:width-1], charts.ellipsis)
+	}
+	for i, r := range runes {
+		c.cells[row][col+i] = cell{r: r, style: style}
+	}
+	return len(runes)
+}
+
+// highlight sets the style of width cells from col on row
+func (c *canvas) highlight(row, col, width int, style cellStyle) {
+	if row < 0 || row >= c.height {
+		return
+	}
+	for i := max(col, 0); i < min(col+width, c.width); i++ {
+		c.cells[row][i].style = style
+	}
+}
+
+// rule draws a horizontal line with a title, e.g. "── Meals ─────"
+func (c *canvas) rule(row, col, width int, title string) {
+	line := strings.Repeat(string(charts.rule), width)
+	c.text(row, col, width, line, styleDim)
+	if title != "" {
+		c.text(row, col+2, width-4, " "+title+" ", styleBold)
+	}
+}
+
+// WriteTo writes the frame over the previous one
+func (c *canvas) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	out.WriteString(cursorHome)
+	for row, cells := range c.cells {
+		if row > 0 {
+			// The terminal is in raw mode: a new line doesn't return
+			out.WriteString("\r\n")
+		}
+		style := cellStyle(255)
+		for _, cell := range cells {
+			if cell.style != style {
+				style = cell.style
+				out.WriteString(sgr[style])
+			}
+			out.WriteRune(cell.r)
+		}
+	}
+	out.WriteString(sgr[stylePlain])
+	return out.WriteTo(w)
+}
+
+// keyCode is a key of the keyboard other than a printable character
+type keyCode uint8
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyEnter
+	keyBackspace
+	keyEscape
+	keyInterrupt
+	keyUnknown
+)
+
+// key is a key pressed, its character for printable ones
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// parseKeys decodes the keys pressed out of what the terminal sent in raw
+// mode
+func parseKeys(input []byte) []key {
+	var keys []key
+	for len(input) > 0 {
+		switch {
+		case input[0] == 0x1b && len(input) >= 3 && (input[1] == '[' || input[1] == 'O'):
+			// Arrow keys are ESC [ A to D, other sequences are skipped up to
+			// their final byte
+			end := 2
+			for end < len(input) && (input[end] < 0x40 || input[end] > 0x7e) {
+				end++
+			}
+			code := keyUnknown
+			if end < len(input) {
+				switch input[end] {
+				case 'A':
+					code = keyUp
+				case 'B':
+					code = keyDown
+				case 'C':
+					code = keyRight
+				case 'D':
+					code = keyLeft
+				}
+			}
+			keys = append(keys, key{code: code})
+			input = input[min(end+1, len(input)):]
+		case input[0] == 0x1b:
+			keys = append(keys, key{code: keyEscape})
+			input = input[1:]
+		case input[0] == '\r' || input[0] == '\n':
+			keys = append(keys, key{code: keyEnter})
+			input = input[1:]
+		case input[0] == 0x7f || input[0] == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+			input = input[1:]
+		case input[0] == 0x03 || input[0] == 0x04:
+			keys = append(keys, key{code: keyInterrupt})
+			input = input[1:]
+		case input[0] < 0x20:
+			keys = append(keys, key{code: keyUnknown})
+			input = input[1:]
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, key{code: keyRune, r: r})
+			input = input[size:]
+		}
+	}
+	return keys
+}
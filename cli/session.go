@@ -2,32 +2,65 @@ package cli
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 )
 
 type Session struct {
-	CurrentUserID uint `json:"currentUserId"`
+	CurrentUserID   uint   `json:"currentUserId"`
+	CurrentUserName string `json:"currentUserName,omitempty"`
 }
 
 var currentSession *Session
 
+func sessionFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".config", "bodytracker", "session.json"), nil
+}
+
+// loadSession restores the session saved by a previous run, if any
+func loadSession() error {
+	sessionFile, err := sessionFilePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(sessionFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return err
+	}
+
+	currentSession = &session
+	return nil
+}
+
 func saveSession() error {
 	if currentSession == nil {
 		return nil
 	}
 
-	homeDir, err := os.UserHomeDir()
+	sessionFile, err := sessionFilePath()
 	if err != nil {
 		return err
 	}
 
-	configDir := filepath.Join(homeDir, ".config", "bodytracker")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(sessionFile), 0755); err != nil {
 		return err
 	}
 
-	sessionFile := filepath.Join(configDir, "session.json")
 	data, err := json.Marshal(currentSession)
 	if err != nil {
 		return err
@@ -36,6 +69,21 @@ func saveSession() error {
 	return os.WriteFile(sessionFile, data, 0644)
 }
 
+// clearSession forgets the selected user and removes the saved session
+func clearSession() error {
+	currentSession = nil
+
+	sessionFile, err := sessionFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(sessionFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
 func getCurrentUserID() uint {
 	if currentSession == nil {
 		return 0
@@ -43,10 +91,18 @@ func getCurrentUserID() uint {
 	return currentSession.CurrentUserID
 }
 
-func setCurrentUserID(userID uint) error {
+func getCurrentUserName() string {
+	if currentSession == nil {
+		return ""
+	}
+	return currentSession.CurrentUserName
+}
+
+func setCurrentUser(userID uint, name string) error {
 	if currentSession == nil {
 		currentSession = &Session{}
 	}
 	currentSession.CurrentUserID = userID
+	currentSession.CurrentUserName = name
 	return saveSession()
 }
@@ -2,31 +2,139 @@ package cli
 
 import (
 	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/client"
 )
 
+// The types below are the CLI's output schema, i.e. what --output json and
+// csv produce. They are converted from the client types so that scripts
+// relying on them are not affected by changes of the API's encoding.
+
 type User struct {
 	ID            uint    `json:"id"`
 	FirstName     string  `json:"firstName"`
 	LastName      string  `json:"lastName"`
-	Name          string  `json:"name"`
 	Age           int     `json:"age"`
 	Weight        float64 `json:"weight"`
 	Height        int     `json:"height"`
-	Gender        string  `json:"gender"`
 	Goal          string  `json:"goal"`
 	Sex           int     `json:"sex"`
 	ActivityLevel int     `json:"activityLevel"`
 }
 
+func newUser(u client.User) User {
+	return User{
+		ID:            u.ID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Age:           u.Age,
+		Weight:        u.Weight,
+		Height:        u.Height,
+		Goal:          u.Goal,
+		Sex:           u.Sex,
+		ActivityLevel: u.ActivityLevel,
+	}
+}
+
 type Food struct {
 	FdcID       string  `json:"fdcId"`
 	Name        string  `json:"name"`
+	DataType    string  `json:"dataType"`
+	BrandOwner  string  `json:"brandOwner"`
 	Protein     float64 `json:"protein"`
 	Carbs       float64 `json:"carbs"`
 	Fat         float64 `json:"fat"`
 	Calories    float64 `json:"calories"`
 	Fiber       float64 `json:"fiber"`
 	ServingSize float64 `json:"servingSize"`
+	// Favorite is set for the foods starred by the selected user, Overridden
+	// for those whose values are the user's own
+	Favorite   bool `json:"favorite"`
+	Overridden bool `json:"overridden"`
+	// Grams and Measure are the quantity eaten, for the foods of a meal
+	Grams   float64 `json:"grams,omitempty"`
+	Measure string  `json:"measure,omitempty"`
+}
+
+func newFood(f client.Food) Food {
+	return Food{
+		FdcID:       f.FdcID,
+		Name:        f.Name,
+		DataType:    f.DataType,
+		BrandOwner:  f.BrandOwner,
+		Protein:     f.Protein,
+		Carbs:       f.Carbs,
+		Fat:         f.Fat,
+		Calories:    f.Calories,
+		Fiber:       f.Fiber,
+		ServingSize: f.ServingSize,
+		Favorite:    f.Favorite,
+		Overridden:  f.Overridden,
+		Grams:       f.Grams,
+		Measure:     f.Measure,
+	}
+}
+
+// FoodDetail is a food with the household measures it can be logged in
+type FoodDetail struct {
+	Food
+	ServingSizeUnit  string        `json:"servingSizeUnit"`
+	HouseholdServing string        `json:"householdServing"`
+	Portions         []FoodPortion `json:"portions"`
+}
+
+type FoodPortion struct {
+	Description string  `json:"description"`
+	GramWeight  float64 `json:"gramWeight"`
+}
+
+func newFoodDetail(f client.Food) FoodDetail {
+	detail := FoodDetail{
+		Food:             newFood(f),
+		ServingSizeUnit:  f.ServingSizeUnit,
+		HouseholdServing: f.HouseholdServing,
+		Portions:         make([]FoodPortion, len(f.Portions)),
+	}
+	for i, portion := range f.Portions {
+		detail.Portions[i] = FoodPortion{Description: portion.Description, GramWeight: portion.GramWeight}
+	}
+	return detail
+}
+
+// FoodOverride is a user's own values for a shared food. Null values keep
+// the shared ones.
+type FoodOverride struct {
+	FdcID       string   `json:"fdcId"`
+	SharedName  string   `json:"sharedName"`
+	Name        *string  `json:"name"`
+	Protein     *float64 `json:"protein"`
+	Carbs       *float64 `json:"carbs"`
+	Fat         *float64 `json:"fat"`
+	Calories    *float64 `json:"calories"`
+	Fiber       *float64 `json:"fiber"`
+	ServingSize *float64 `json:"servingSize"`
+}
+
+func newFoodOverride(o client.FoodOverride) FoodOverride {
+	return FoodOverride{
+		FdcID:       o.Food.FdcID,
+		SharedName:  o.Food.Name,
+		Name:        o.Name,
+		Protein:     o.Protein,
+		Carbs:       o.Carbs,
+		Fat:         o.Fat,
+		Calories:    o.Calories,
+		Fiber:       o.Fiber,
+		ServingSize: o.ServingSize,
+	}
+}
+
+func newFoodList(foods []client.Food) foodList {
+	list := make(foodList, len(foods))
+	for i, food := range foods {
+		list[i] = newFood(food)
+	}
+	return list
 }
 
 type Target struct {
@@ -37,9 +145,120 @@ type Target struct {
 	Fiber    float64 `json:"fiber"`
 }
 
+func newTarget(t client.Target) Target {
+	return Target{
+		Calories: t.Calories,
+		Protein:  t.Protein,
+		Carbs:    t.Carbs,
+		Fat:      t.Fat,
+		Fiber:    t.Fiber,
+	}
+}
+
 type WeightRecord struct {
 	ID     uint      `json:"id"`
 	Weight float64   `json:"weight"`
 	Date   time.Time `json:"date"`
 	Note   string    `json:"note,omitempty"`
 }
+
+func newWeightRecord(r client.WeightRecord) WeightRecord {
+	return WeightRecord{
+		ID:     r.ID,
+		Weight: r.Weight,
+		Date:   r.Date,
+		Note:   r.Note,
+	}
+}
+
+type Meal struct {
+	ID     uint      `json:"id"`
+	Type   string    `json:"type"`
+	Date   time.Time `json:"date"`
+	UserID uint      `json:"userId"`
+	Foods  []Food    `json:"foods"`
+}
+
+func newMeal(m client.Meal) Meal {
+	return Meal{
+		ID:     m.ID,
+		Type:   string(m.Type),
+		Date:   m.Date,
+		UserID: m.UserID,
+		Foods:  newFoodList(m.Foods),
+	}
+}
+
+func newMealList(meals []client.Meal) mealList {
+	list := make(mealList, len(meals))
+	for i, meal := range meals {
+		list[i] = newMeal(meal)
+	}
+	return list
+}
+
+type Nutrients struct {
+	Calories float64 `json:"calories"`
+	Protein  float64 `json:"protein"`
+	Carbs    float64 `json:"carbs"`
+	Fat      float64 `json:"fat"`
+	Fiber    float64 `json:"fiber"`
+}
+
+func (n *Nutrients) add(food Food) {
+	eaten := food.eaten()
+	n.Calories += eaten.Calories
+	n.Protein += eaten.Protein
+	n.Carbs += eaten.Carbs
+	n.Fat += eaten.Fat
+	n.Fiber += eaten.Fiber
+}
+
+func (n Nutrients) plus(o Nutrients) Nutrients {
+	return Nutrients{
+		Calories: n.Calories + o.Calories,
+		Protein:  n.Protein + o.Protein,
+		Carbs:    n.Carbs + o.Carbs,
+		Fat:      n.Fat + o.Fat,
+		Fiber:    n.Fiber + o.Fiber,
+	}
+}
+
+func (n Nutrients) times(factor float64) Nutrients {
+	return Nutrients{
+		Calories: n.Calories * factor,
+		Protein:  n.Protein * factor,
+		Carbs:    n.Carbs * factor,
+		Fat:      n.Fat * factor,
+		Fiber:    n.Fiber * factor,
+	}
+}
+
+// eaten returns the nutrients of the quantity of a meal's food, those of
+// the food being given for 100 g
+func (f Food) eaten() Nutrients {
+	factor := 1.0
+	if f.Grams > 0 {
+		factor = f.Grams / 100
+	}
+	return Nutrients{
+		Calories: f.Calories * factor,
+		Protein:  f.Protein * factor,
+		Carbs:    f.Carbs * factor,
+		Fat:      f.Fat * factor,
+		Fiber:    f.Fiber * factor,
+	}
+}
+
+type UserStats struct {
+	Height int     `json:"height"`
+	Weight float64 `json:"weight"`
+	BMI    float64 `json:"bmi"`
+	BFP    float64 `json:"bfp"`
+	IMG    float64 `json:"img"`
+	BMR    float64 `json:"bmr"`
+}
+
+func newUserStats(s client.UserStats) UserStats {
+	return UserStats(s)
+}
@@ -0,0 +1,210 @@
+// Package client is a typed Go client for the My Body Tracker API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the API at a base URL, retrying idempotent requests on network
+// errors and temporary server failures.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	retryWait  time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times an idempotent request is retried and the
+// wait before the first retry, doubled after each attempt
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// New creates a client for the API served at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 2,
+		retryWait:  200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the URL of the API the client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Error is returned when the API answers with an unexpected status. It
+// carries the API's error body when there is one.
+type Error struct {
+	StatusCode int
+	Code       ErrorCode
+	Message    string
+	Details    []FieldError
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	for _, detail := range e.Details {
+		msg += fmt.Sprintf("; %s %s", detail.Field, detail.Message)
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request %s)", e.RequestID)
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is an API error with status 404
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response
+// into out when it is not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	_, err := c.doWithHeader(ctx, method, path, query, body, out)
+	return err
+}
+
+// doWithHeader is do for callers that need the response headers
+func (c *Client) doWithHeader(ctx context.Context, method, path string, query url.Values, body, out any) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	retries := 0
+	if method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete {
+		retries = c.maxRetries
+	}
+
+	wait := c.retryWait
+	for attempt := 0; ; attempt++ {
+		header, err := c.send(ctx, method, target, payload, "application/json", out)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return header, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// send sends a request once, with payload of the given content type when
+// it is not nil. The response is decoded into out, or copied to it when it
+// is an io.Writer.
+func (c *Client) send(ctx context.Context, method, target string, payload []byte, contentType string, out any) (http.Header, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, decodeError(resp)
+	}
+
+	if out == nil {
+		return resp.Header, nil
+	}
+	// Bodies other than JSON, e.g. archives, are copied to an io.Writer
+	if w, ok := out.(io.Writer); ok {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return nil, fmt.Errorf("reading response: %w", err)
+		}
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return resp.Header, nil
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+
+	var body ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		apiErr.Code = body.Code
+		apiErr.Message = body.Message
+		apiErr.Details = body.Details
+		if body.RequestID != "" {
+			apiErr.RequestID = body.RequestID
+		}
+	}
+	return apiErr
+}
+
+// retryable reports whether a failed request may succeed when sent again
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// ExportUser writes all the data of a user to w, as JSON or, with format
+// "zip", as a ZIP archive of the JSON export and of CSV files. It returns
+// the file name the API suggests for the export.
+func (c *Client) ExportUser(ctx context.Context, userID uint, format string, w io.Writer) (string, error) {
+	target := fmt.Sprintf("%s/users/%d/export?%s", c.baseURL, userID, url.Values{"format": {format}}.Encode())
+	header, err := c.send(ctx, http.MethodGet, target, nil, "", w)
+	if err != nil {
+		return "", err
+	}
+	return attachmentName(header), nil
+}
+
+// attachmentName returns the file name of a response sent as an attachment
+func attachmentName(header http.Header) string {
+	_, params, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
+}
+
+// CSV exports of a user's data, for ExportCSV
+const (
+	// MealsCSV has a row per food eaten
+	MealsCSV = "meals"
+	// DailyCSV has a row per day with the nutrients eaten against the targets
+	DailyCSV = "daily"
+	// WeightCSV has a row per weight record
+	WeightCSV = "weight"
+)
+
+// ExportCSV writes a CSV export of a user's data, in dates when given, to w.
+// It returns the file name the API suggests for it.
+func (c *Client) ExportCSV(ctx context.Context, userID uint, export string, dates DateRange, w io.Writer) (string, error) {
+	target := fmt.Sprintf("%s/users/%d/export/%s", c.baseURL, userID, url.PathEscape(export))
+	if params := dates.values(nil); len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+	header, err := c.send(ctx, http.MethodGet, target, nil, "", w)
+	if err != nil {
+		return "", err
+	}
+	return attachmentName(header), nil
+}
+
+// ImportUser restores an export of ExportUser, JSON or ZIP, as a new user
+// and returns it
+func (c *Client) ImportUser(ctx context.Context, export io.Reader) (*User, error) {
+	payload, err := io.ReadAll(export)
+	if err != nil {
+		return nil, fmt.Errorf("reading export: %w", err)
+	}
+	contentType := "application/json"
+	if bytes.HasPrefix(payload, []byte("PK")) {
+		contentType = "application/zip"
+	}
+
+	var user User
+	if _, err := c.send(ctx, http.MethodPost, c.baseURL+"/users/import", payload, contentType, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
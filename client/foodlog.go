@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// FoodLogImportOptions tunes a food log import. Zero fields are ignored.
+type FoodLogImportOptions struct {
+	// Format is mfp or cronometer, detected from the file's columns when
+	// empty
+	Format string
+	// DryRun reports what would be imported without saving anything
+	DryRun bool
+}
+
+// ImportFoodLog logs the foods of a CSV food log exported by MyFitnessPal or
+// Cronometer in the user's meals, and returns the report of the import
+func (c *Client) ImportFoodLog(ctx context.Context, userID uint, log io.Reader, opts FoodLogImportOptions) (*FoodLogImport, error) {
+	payload, err := io.ReadAll(log)
+	if err != nil {
+		return nil, fmt.Errorf("reading food log: %w", err)
+	}
+
+	params := url.Values{}
+	if opts.Format != "" {
+		params.Set("format", opts.Format)
+	}
+	if opts.DryRun {
+		params.Set("dryRun", "true")
+	}
+	target := fmt.Sprintf("%s/users/%d/import", c.baseURL, userID)
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+
+	var report FoodLogImport
+	if _, err := c.send(ctx, http.MethodPost, target, payload, "text/csv", &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// QuickLog logs the foods of a sentence such as "150g chicken breast and 1
+// cup rice for lunch yesterday" in the user's meal, and returns how it was
+// read. With dryRun, nothing is saved. The days and the meal guessed from
+// the time of day are relative to the local time.
+func (c *Client) QuickLog(ctx context.Context, userID uint, text string, dryRun bool) (*QuickLog, error) {
+	var query url.Values
+	if dryRun {
+		query = url.Values{"dryRun": {"true"}}
+	}
+	now := time.Now()
+	var log QuickLog
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/users/%d/quicklog", userID), query, QuickLogRequest{Text: text, Now: &now}, &log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
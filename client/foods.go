@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FoodSearchOptions tunes a food search. Zero fields are ignored.
+type FoodSearchOptions struct {
+	// Page is the page of results, starting at 1
+	Page int
+	// Limit is the number of foods per page, 10 by default
+	Limit int
+	// DataTypes restricts the search to these data types: foundation,
+	// sr-legacy, branded or survey
+	DataTypes []string
+	// BrandOwner keeps the foods of this brand owner only
+	BrandOwner string
+	// Sort is relevance, the default, or name or dataType prefixed with "-"
+	// for a descending order
+	Sort string
+	// UserID ranks the foods the user logs often higher
+	UserID uint
+	// Refresh queries the food provider even if the query was sent recently
+	Refresh bool
+}
+
+// SearchFoods searches the food database. The API answers from its local
+// cache when it can and from the food provider otherwise.
+func (c *Client) SearchFoods(ctx context.Context, query string, opts FoodSearchOptions) (*FoodSearchResponse, error) {
+	params := url.Values{"q": {query}}
+	if opts.Page > 0 {
+		params.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if len(opts.DataTypes) > 0 {
+		params.Set("dataType", strings.Join(opts.DataTypes, ","))
+	}
+	if opts.BrandOwner != "" {
+		params.Set("brandOwner", opts.BrandOwner)
+	}
+	if opts.Sort != "" {
+		params.Set("sort", opts.Sort)
+	}
+	if opts.UserID != 0 {
+		params.Set("userId", strconv.FormatUint(uint64(opts.UserID), 10))
+	}
+	if opts.Refresh {
+		params.Set("refresh", "true")
+	}
+
+	var resp FoodSearchResponse
+	if err := c.do(ctx, http.MethodGet, "/foods/search", params, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetFood returns a food with its portions, the household measures it can be
+// logged in. The API fetches them from the food provider when needed. userID
+// applies the user's favourites and overrides when not 0.
+func (c *Client) GetFood(ctx context.Context, fdcID string, userID uint) (*Food, error) {
+	params := url.Values{}
+	if userID != 0 {
+		params.Set("userId", strconv.FormatUint(uint64(userID), 10))
+	}
+
+	var food Food
+	if err := c.do(ctx, http.MethodGet, "/foods/"+url.PathEscape(fdcID), params, nil, &food); err != nil {
+		return nil, err
+	}
+	return &food, nil
+}
+
+// FoodUsageFilter narrows down the meals RecentFoods and FrequentFoods look
+// at. Zero fields are ignored.
+type FoodUsageFilter struct {
+	Type MealType
+	// Hour keeps the meals eaten within 2 hours of this hour of the day
+	Hour *int
+	// Days is the number of days of history, 90 by default
+	Days int
+	// Limit is the maximum number of foods, 10 by default
+	Limit int
+}
+
+func (f FoodUsageFilter) values() url.Values {
+	params := url.Values{}
+	if f.Type != "" {
+		params.Set("type", string(f.Type))
+	}
+	if f.Hour != nil {
+		params.Set("hour", strconv.Itoa(*f.Hour))
+	}
+	if f.Days > 0 {
+		params.Set("days", strconv.Itoa(f.Days))
+	}
+	if f.Limit > 0 {
+		params.Set("limit", strconv.Itoa(f.Limit))
+	}
+	return params
+}
+
+// RecentFoods returns the foods a user logged, most recently eaten first
+func (c *Client) RecentFoods(ctx context.Context, userID uint, filter FoodUsageFilter) ([]FoodUsage, error) {
+	var foods []FoodUsage
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d/foods/recent", userID), filter.values(), nil, &foods)
+	return foods, err
+}
+
+// FrequentFoods returns the foods a user logged, most often eaten first
+func (c *Client) FrequentFoods(ctx context.Context, userID uint, filter FoodUsageFilter) ([]FoodUsage, error) {
+	var foods []FoodUsage
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d/foods/frequent", userID), filter.values(), nil, &foods)
+	return foods, err
+}
+
+// ListFavoriteFoods returns the foods starred by a user, last starred first
+func (c *Client) ListFavoriteFoods(ctx context.Context, userID uint) ([]Food, error) {
+	var foods []Food
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d/foods/favorites", userID), nil, nil, &foods)
+	return foods, err
+}
+
+// AddFavoriteFood stars a food, identified by its FDC ID, for a user
+func (c *Client) AddFavoriteFood(ctx context.Context, userID uint, fdcID string) (*Food, error) {
+	var food Food
+	path := fmt.Sprintf("/users/%d/foods/favorites/%s", userID, url.PathEscape(fdcID))
+	if err := c.do(ctx, http.MethodPut, path, nil, nil, &food); err != nil {
+		return nil, err
+	}
+	return &food, nil
+}
+
+// RemoveFavoriteFood unstars a food for a user
+func (c *Client) RemoveFavoriteFood(ctx context.Context, userID uint, fdcID string) error {
+	path := fmt.Sprintf("/users/%d/foods/favorites/%s", userID, url.PathEscape(fdcID))
+	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// ListFoodOverrides returns a user's own values for shared foods
+func (c *Client) ListFoodOverrides(ctx context.Context, userID uint) ([]FoodOverride, error) {
+	var overrides []FoodOverride
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d/foods/overrides", userID), nil, nil, &overrides)
+	return overrides, err
+}
+
+// SetFoodOverride creates or replaces a user's own values for a shared food.
+// They apply wherever the API returns the food for that user.
+func (c *Client) SetFoodOverride(ctx context.Context, userID uint, fdcID string, override FoodOverride) (*FoodOverride, error) {
+	var saved FoodOverride
+	path := fmt.Sprintf("/users/%d/foods/overrides/%s", userID, url.PathEscape(fdcID))
+	if err := c.do(ctx, http.MethodPut, path, nil, override, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteFoodOverride brings a food back to its shared values for a user
+func (c *Client) DeleteFoodOverride(ctx context.Context, userID uint, fdcID string) error {
+	path := fmt.Sprintf("/users/%d/foods/overrides/%s", userID, url.PathEscape(fdcID))
+	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
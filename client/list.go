@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ListOptions selects a page of a list endpoint and its order. Zero fields
+// leave the server defaults: first page, 50 items, endpoint's default order.
+type ListOptions struct {
+	Page  int
+	Limit int
+	// Sort is one of the endpoint's sort keys, prefixed with "-" for a
+	// descending order, e.g. "-date"
+	Sort string
+}
+
+// DateRange narrows a list down to the items dated between From and To, both
+// inclusive days. Zero bounds are ignored.
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Page is one page of a list endpoint's items
+type Page[T any] struct {
+	Items []T
+	// Total is the number of items across all pages
+	Total int
+	// Next is the number of the next page, 0 on the last page
+	Next int
+}
+
+// All fetches the pages of a list one after another, starting from
+// opts.Page, and returns their items
+func All[T any](ctx context.Context, opts ListOptions, fetch func(context.Context, ListOptions) (*Page[T], error)) ([]T, error) {
+	var items []T
+	for {
+		page, err := fetch(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.Next == 0 {
+			return items, nil
+		}
+		opts.Page = page.Next
+	}
+}
+
+func (o ListOptions) values(params url.Values) url.Values {
+	if params == nil {
+		params = url.Values{}
+	}
+	if o.Page > 0 {
+		params.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.Limit > 0 {
+		params.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Sort != "" {
+		params.Set("sort", o.Sort)
+	}
+	return params
+}
+
+func (r DateRange) values(params url.Values) url.Values {
+	if params == nil {
+		params = url.Values{}
+	}
+	if !r.From.IsZero() {
+		params.Set("from", r.From.Format("2006-01-02"))
+	}
+	if !r.To.IsZero() {
+		params.Set("to", r.To.Format("2006-01-02"))
+	}
+	return params
+}
+
+// getPage fetches a page of a list endpoint, reading the total and the next
+// page from the X-Total-Count and Link headers
+func getPage[T any](ctx context.Context, c *Client, path string, params url.Values) (*Page[T], error) {
+	page := &Page[T]{}
+	header, err := c.doWithHeader(ctx, http.MethodGet, path, params, nil, &page.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Total, _ = strconv.Atoi(header.Get("X-Total-Count"))
+	page.Next = nextPage(header.Get("Link"))
+	return page, nil
+}
+
+var nextLink = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="?next"?`)
+
+// nextPage returns the page number of the Link header's next link, or 0
+func nextPage(link string) int {
+	m := nextLink.FindStringSubmatch(link)
+	if m == nil {
+		return 0
+	}
+	u, err := url.Parse(m[1])
+	if err != nil {
+		return 0
+	}
+	page, _ := strconv.Atoi(u.Query().Get("page"))
+	return page
+}
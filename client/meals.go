@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MealFilter narrows down the meals returned by GetUserMeals. Zero fields are
+// ignored.
+type MealFilter struct {
+	Date time.Time
+	DateRange
+	Type MealType
+}
+
+// CreateMeal creates a meal, dated now when meal.Date is zero
+func (c *Client) CreateMeal(ctx context.Context, meal Meal) (*Meal, error) {
+	var created Meal
+	if err := c.do(ctx, http.MethodPost, "/meals/", nil, meal, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetUserMeals returns a page of a user's meals with their foods, most
+// recent first unless sorted by "date" or "type"
+func (c *Client) GetUserMeals(ctx context.Context, userID uint, filter MealFilter, opts ListOptions) (*Page[Meal], error) {
+	params := opts.values(filter.DateRange.values(nil))
+	if !filter.Date.IsZero() {
+		params.Set("date", filter.Date.Format("2006-01-02"))
+	}
+	if filter.Type != "" {
+		params.Set("type", string(filter.Type))
+	}
+
+	return getPage[Meal](ctx, c, fmt.Sprintf("/meals/user/%d", userID), params)
+}
+
+// AddFoodToMeal adds a food, identified by its FDC ID in req, to a meal and
+// returns the updated meal. The food must have been returned by a search or
+// GetFood first. The quantity is 100 g unless req gives Grams, or a PortionID
+// returned by GetFood and optionally a number of Servings.
+func (c *Client) AddFoodToMeal(ctx context.Context, mealID uint, req AddFoodRequest) (*Meal, error) {
+	var meal Meal
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/meals/%d/foods", mealID), nil, req, &meal); err != nil {
+		return nil, err
+	}
+	return &meal, nil
+}
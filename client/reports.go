@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/models"
+)
+
+// Report periods, for GetReport
+const (
+	WeeklyReport  = models.ReportWeekly
+	MonthlyReport = models.ReportMonthly
+)
+
+// ReportOptions picks the period of a report. Zero fields are ignored.
+type ReportOptions struct {
+	// Period is an ISO week such as 2024-W10 for a weekly report, or a month
+	// such as 2024-03 for a monthly one, the current one when empty
+	Period string
+	// Tolerance is the percentage of a target a day may miss it by and still
+	// adhere to it, 10 by default
+	Tolerance *float64
+}
+
+// GetReport returns a user's weekly or monthly nutrition report
+func (c *Client) GetReport(ctx context.Context, userID uint, period string, opts ReportOptions) (*NutritionReport, error) {
+	params := url.Values{}
+	if opts.Period != "" {
+		name := "week"
+		if period == MonthlyReport {
+			name = "month"
+		}
+		params.Set(name, opts.Period)
+	}
+	if opts.Tolerance != nil {
+		params.Set("tolerance", strconv.FormatFloat(*opts.Tolerance, 'f', -1, 64))
+	}
+
+	var report NutritionReport
+	path := fmt.Sprintf("/users/%d/reports/%s", userID, url.PathEscape(period))
+	if err := c.do(ctx, http.MethodGet, path, params, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Formats of the printable report, for PrintableReport
+const (
+	PrintableHTML = "html"
+	PrintablePDF  = "pdf"
+)
+
+// PrintableReport writes a user's printable report of the days in dates, 30
+// days when a bound is missing, to w as an HTML page or a PDF. It returns
+// the file name the API suggests for it.
+func (c *Client) PrintableReport(ctx context.Context, userID uint, format string, dates DateRange, w io.Writer) (string, error) {
+	params := dates.values(url.Values{"format": {format}})
+	target := fmt.Sprintf("%s/users/%d/reports/print?%s", c.baseURL, userID, params.Encode())
+	header, err := c.send(ctx, http.MethodGet, target, nil, "", w)
+	if err != nil {
+		return "", err
+	}
+	return attachmentName(header), nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import "github.com/ZUHOWKS/my-body-tracker/api/models"
+
+// The client shares its request and response types with the API so that both
+// sides always agree on the JSON they exchange.
+type (
+	User               = models.User
+	UserStats          = models.UserStats
+	UserDeletion       = models.UserDeletion
+	Target             = models.Target
+	WeightRecord       = models.WeightRecord
+	Food               = models.Food
+	FoodSearchResponse = models.FoodSearchResponse
+	FoodUsage          = models.FoodUsage
+	FoodOverride       = models.FoodOverride
+	FoodPortion        = models.FoodPortion
+	Meal               = models.Meal
+	MealType           = models.MealType
+	AddFoodRequest     = models.AddFoodRequest
+	FoodLogImport      = models.FoodLogImport
+	FoodLogLine        = models.FoodLogLine
+	QuickLogRequest    = models.QuickLogRequest
+	QuickLog           = models.QuickLog
+	QuickLogEntry      = models.QuickLogEntry
+	UserExport         = models.UserExport
+	Nutrients          = models.Nutrients
+	NutritionReport    = models.NutritionReport
+	ReportDay          = models.ReportDay
+	ErrorResponse      = models.ErrorResponse
+	ErrorCode          = models.ErrorCode
+	FieldError         = models.FieldError
+)
+
+// Meal types accepted by the API
+const (
+	Breakfast = models.Breakfast
+	Lunch     = models.Lunch
+	Break     = models.Break
+	Dinner    = models.Dinner
+)
+
+// MealTypes are the meal types in the order of the day
+var MealTypes = models.MealTypes
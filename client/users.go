@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListUsers returns a page of user profiles, sortable by "id", "name" or
+// "created"
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) (*Page[User], error) {
+	return getPage[User](ctx, c, "/users/", opts.values(nil))
+}
+
+// CreateUser creates a user profile and returns it with its ID
+func (c *Client) CreateUser(ctx context.Context, user User) (*User, error) {
+	var created User
+	if err := c.do(ctx, http.MethodPost, "/users/", nil, user, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetUser returns a user profile
+func (c *Client) GetUser(ctx context.Context, id uint) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d", id), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser replaces the fields of a user profile
+func (c *Client) UpdateUser(ctx context.Context, id uint, user User) (*User, error) {
+	var updated User
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/users/%d", id), nil, user, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteUser deletes a user with their data. They can be restored with
+// RestoreUser until the returned deletion's RestoreUntil.
+func (c *Client) DeleteUser(ctx context.Context, id uint) (*UserDeletion, error) {
+	var deletion UserDeletion
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/users/%d", id), nil, nil, &deletion); err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// RestoreUser brings back a deleted user with their data
+func (c *Client) RestoreUser(ctx context.Context, id uint) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/users/%d/restore", id), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserStats returns the health indicators computed from a user's profile
+func (c *Client) GetUserStats(ctx context.Context, id uint) (*UserStats, error) {
+	var stats UserStats
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d/stats", id), nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetTargets returns a user's daily nutrition targets. The error satisfies
+// IsNotFound when none were set.
+func (c *Client) GetTargets(ctx context.Context, userID uint) (*Target, error) {
+	var target Target
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d/targets", userID), nil, nil, &target); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// SetTargets creates or replaces a user's daily nutrition targets
+func (c *Client) SetTargets(ctx context.Context, userID uint, target Target) (*Target, error) {
+	var saved Target
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/users/%d/targets", userID), nil, target, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// RecordWeight records a weight measure, which also becomes the user's
+// current weight
+func (c *Client) RecordWeight(ctx context.Context, userID uint, record WeightRecord) (*WeightRecord, error) {
+	var saved WeightRecord
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/users/%d/weight", userID), nil, record, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetWeightHistory returns a page of a user's weight records in the date
+// range, most recent first unless sorted by "date" or "weight"
+func (c *Client) GetWeightHistory(ctx context.Context, userID uint, dates DateRange, opts ListOptions) (*Page[WeightRecord], error) {
+	params := opts.values(dates.values(nil))
+	return getPage[WeightRecord](ctx, c, fmt.Sprintf("/users/%d/weight/history", userID), params)
+}
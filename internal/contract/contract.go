@@ -0,0 +1,301 @@
+// Package contract checks that the API behaves as its OpenAPI document
+// describes: every route is documented, and the responses to a scripted
+// sequence of requests have documented statuses and bodies matching their
+// schemas.
+package contract
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// Step is a request sent to the API and the status expected in return
+type Step struct {
+	Name string
+	// Op is the documented operation the request exercises
+	Op Operation
+	// URL and Body may reference values saved by earlier steps as {name}
+	URL  string
+	Body string
+	// ContentType is the type of Body, JSON when empty
+	ContentType string
+	Status      int
+	// Headers are response headers expected to have the given values
+	Headers map[string]string
+	// Fields are fields of the response body expected to have the given
+	// values, as dotted paths with array indexes, e.g. "0.foods.0.name".
+	// Values, like those of Headers, may reference saved values as {name}.
+	Fields map[string]string
+	// Save stores fields of the response body under a name, given as dotted
+	// paths like Fields
+	Save map[string]string
+}
+
+// FDCTransport answers requests to FoodData Central with recorded responses,
+// so that the check needs no network access nor real API key. Requests
+// without key get a 403 and with RateLimitedKey a 429. Searches get the
+// recorded page of their pageNumber, restricted to their dataType filter, and
+// food details the recorded food of their ID; other requests get a 404.
+// Searching for "outage" or getting the food 503 gets a 503, searching for
+// "broken" or getting the food 500 a 500, and searching for "slow" or getting
+// the food 504 no answer at all.
+func FDCTransport() http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.Header.Get("X-Api-Key") {
+		case "":
+			return fdcError(req, http.StatusForbidden), nil
+		case RateLimitedKey:
+			return fdcError(req, http.StatusTooManyRequests), nil
+		}
+
+		params := req.URL.Query()
+		id, detail := strings.CutPrefix(req.URL.Path, "/fdc/v1/food/")
+		switch {
+		case params.Get("query") == "outage" || id == "503":
+			return fdcError(req, http.StatusServiceUnavailable), nil
+		case params.Get("query") == "broken" || id == "500":
+			return fdcError(req, http.StatusInternalServerError), nil
+		case params.Get("query") == "slow" || id == "504":
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		name := "fixtures/fdc_search_rice.json"
+		if page := params.Get("pageNumber"); page != "" && page != "1" {
+			name = "fixtures/fdc_search_rice_page" + page + ".json"
+		}
+		if detail {
+			name = "fixtures/fdc_food_" + id + ".json"
+		}
+		data, err := fixtures.ReadFile(name)
+		if err != nil {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       io.NopCloser(strings.NewReader("{}")),
+				Request:    req,
+			}, nil
+		}
+		if dataTypes := params.Get("dataType"); dataTypes != "" {
+			if data, err = filterDataTypes(data, strings.Split(dataTypes, ",")); err != nil {
+				return nil, err
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})
+}
+
+// filterDataTypes keeps the foods of a recorded search response whose data
+// type is one of dataTypes
+func filterDataTypes(data []byte, dataTypes []string) ([]byte, error) {
+	var response map[string]any
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	foods, _ := response["foods"].([]any)
+	kept := []any{}
+	for _, food := range foods {
+		if dataType, _ := lookup(food, "dataType").(string); slices.Contains(dataTypes, dataType) {
+			kept = append(kept, food)
+		}
+	}
+	response["foods"] = kept
+	response["totalHits"] = len(kept)
+	response["totalPages"] = 1
+	return json.Marshal(response)
+}
+
+// RateLimitedKey is an FDC API key FDCTransport always rate limits
+const RateLimitedKey = "rate-limited"
+
+// fdcError is an error page of FDC's gateway
+func fdcError(req *http.Request, code int) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		Body:       io.NopCloser(strings.NewReader("<html>" + http.StatusText(code) + "</html>")),
+		Request:    req,
+	}
+}
+
+// FDCClient is the food provider client of the check: FDCTransport behind
+// the API's key rotation, starting with a rate-limited key, and retries and
+// timeouts, shortened so that failures are fast
+func FDCClient() *http.Client {
+	keys := services.NewFDCKeys([]string{RateLimitedKey, "contract"})
+	return services.NewHTTPClient(keys.Transport(FDCTransport()), services.HTTPOptions{
+		Timeout:          time.Second,
+		AttemptTimeout:   50 * time.Millisecond,
+		MaxRetries:       1,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		FailureThreshold: 5,
+		Cooldown:         time.Second,
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+// checkRoutes returns the routes of the router which are not documented and
+// the documented operations which are not routed
+func checkRoutes(router *gin.Engine, spec *Spec) []string {
+	var errs []string
+	documented := map[string]bool{}
+	for _, op := range spec.Operations() {
+		documented[op.String()] = true
+	}
+	routed := map[string]bool{}
+	for _, route := range router.Routes() {
+		op := Operation{Method: route.Method, Path: ginParam.ReplaceAllString(route.Path, "{$1}")}
+		routed[op.String()] = true
+		if !documented[op.String()] {
+			errs = append(errs, fmt.Sprintf("route %s is not documented", op))
+		}
+	}
+	for _, op := range spec.Operations() {
+		if !routed[op.String()] {
+			errs = append(errs, fmt.Sprintf("documented operation %s is not routed", op))
+		}
+	}
+	return errs
+}
+
+// unexercised returns the documented operations no step exercises
+func unexercised(spec *Spec, steps []Step) []string {
+	exercised := map[string]bool{}
+	for _, step := range steps {
+		exercised[step.Op.String()] = true
+	}
+	var errs []string
+	for _, op := range spec.Operations() {
+		if !exercised[op.String()] {
+			errs = append(errs, fmt.Sprintf("documented operation %s is not exercised by any step", op))
+		}
+	}
+	return errs
+}
+
+// runStep sends the request of a step to the router and returns how the
+// response differs from the step and the document. Fields of the response
+// saved by the step are stored in saved.
+func runStep(router *gin.Engine, spec *Spec, step Step, saved map[string]string) []string {
+	expand := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := saved[m[1:len(m)-1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+
+	var body io.Reader
+	if step.Body != "" {
+		body = strings.NewReader(expand(step.Body))
+	}
+	req := httptest.NewRequest(step.Op.Method, expand(step.URL), body)
+	if step.Body != "" {
+		contentType := step.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var errs []string
+	if rec.Code != step.Status {
+		errs = append(errs, fmt.Sprintf("got status %d, want %d (body %s)", rec.Code, step.Status, rec.Body.String()))
+	}
+	if rec.Header().Get("X-Request-ID") == "" {
+		errs = append(errs, "missing X-Request-ID header")
+	}
+	for _, name := range spec.RequiredHeaders(step.Op, rec.Code) {
+		if rec.Header().Get(name) == "" {
+			errs = append(errs, fmt.Sprintf("missing documented %s header", name))
+		}
+	}
+	for name, want := range step.Headers {
+		if got, want := rec.Header().Get(name), expand(want); got != want {
+			errs = append(errs, fmt.Sprintf("got %s header %q, want %q", name, got, want))
+		}
+	}
+
+	schema, ok := spec.ResponseSchema(step.Op, rec.Code)
+	if !ok {
+		return append(errs, fmt.Sprintf("status %d is not documented", rec.Code))
+	}
+	// Bodies other than JSON, e.g. archives, are only checked to be documented
+	if mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type")); rec.Body.Len() > 0 && mediaType != "application/json" {
+		if !spec.HasMediaType(step.Op, rec.Code, mediaType) {
+			errs = append(errs, fmt.Sprintf("media type %s is not documented", mediaType))
+		}
+		return errs
+	}
+	if schema == nil {
+		return errs
+	}
+
+	var value any
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		return append(errs, fmt.Sprintf("body is not JSON: %v", err))
+	}
+	errs = append(errs, spec.Validate(value, schema)...)
+
+	for field, want := range step.Fields {
+		if got, want := fmt.Sprint(lookup(value, field)), expand(want); got != want {
+			errs = append(errs, fmt.Sprintf("got %s %q, want %q", field, got, want))
+		}
+	}
+	for name, field := range step.Save {
+		saved[name] = fmt.Sprint(lookup(value, field))
+	}
+	return errs
+}
+
+// lookup returns the value at a dotted path in a decoded JSON value, or nil
+func lookup(value any, path string) any {
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[part]
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+	return value
+}
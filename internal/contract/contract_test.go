@@ -0,0 +1,57 @@
+package contract
+
+import (
+	"io"
+	"testing"
+
+	"github.com/ZUHOWKS/my-body-tracker/api"
+	"github.com/ZUHOWKS/my-body-tracker/api/openapi"
+	"github.com/ZUHOWKS/my-body-tracker/api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestContract runs the scenario against an API backed by an in-memory
+// database and recorded FoodData Central responses, each step as a subtest
+// depending on the values saved by the previous ones
+func TestContract(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open the in-memory database: %v", err)
+	}
+	if err := api.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	spec, err := LoadSpec(openapi.Spec)
+	if err != nil {
+		t.Fatalf("Failed to load the OpenAPI document: %v", err)
+	}
+	router := api.NewRouter(db, services.NewFDCProvider(FDCClient()))
+	steps := Scenario()
+
+	t.Run("routes are documented", func(t *testing.T) {
+		for _, err := range checkRoutes(router, spec) {
+			t.Error(err)
+		}
+	})
+	saved := map[string]string{}
+	for _, step := range steps {
+		t.Run(step.Name, func(t *testing.T) {
+			for _, err := range runStep(router, spec, step, saved) {
+				t.Errorf("%s: %s", step.Op, err)
+			}
+		})
+	}
+	t.Run("operations are exercised", func(t *testing.T) {
+		for _, err := range unexercised(spec, steps) {
+			t.Error(err)
+		}
+	})
+}
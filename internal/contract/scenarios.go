@@ -0,0 +1,289 @@
+package contract
+
+import (
+	"net/http"
+	"strings"
+)
+
+func op(method, path string) Operation {
+	return Operation{Method: method, Path: path}
+}
+
+const validUser = `{"firstName":"Ada","lastName":"Lovelace","age":36,"weight":60.5,"height":165,"sex":0,"activityLevel":3,"goal":"stay fit"}`
+
+// Scenario is the sequence of requests run by TestContract. It starts from
+// an empty database and covers every documented operation.
+func Scenario() []Step {
+	return []Step{
+		{Name: "serve the OpenAPI document", Op: op("GET", "/openapi.json"), URL: "/openapi.json", Status: http.StatusOK},
+
+		{Name: "create a user", Op: op("POST", "/users/"), URL: "/users/", Body: validUser, Status: http.StatusCreated, Save: map[string]string{"user": "ID"}},
+		{Name: "reject an invalid user", Op: op("POST", "/users/"), URL: "/users/", Body: `{"firstName":"","age":0,"weight":-1,"height":10,"sex":3}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject a malformed body", Op: op("POST", "/users/"), URL: "/users/", Body: `{"firstName":`, Status: http.StatusBadRequest},
+		{Name: "create a second user", Op: op("POST", "/users/"), URL: "/users/", Body: `{"firstName":"Alan","lastName":"Turing","age":41,"weight":70,"height":178,"sex":1}`, Status: http.StatusCreated},
+		{Name: "list users", Op: op("GET", "/users/"), URL: "/users/", Status: http.StatusOK, Headers: map[string]string{"X-Total-Count": "2"}},
+		{Name: "list the first page of users", Op: op("GET", "/users/"), URL: "/users/?limit=1&sort=-name", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "2", "Link": `</users/?limit=1&page=2&sort=-name>; rel="next"`}},
+		{Name: "list the last page of users", Op: op("GET", "/users/"), URL: "/users/?limit=1&page=2", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "2", "Link": `</users/?limit=1&page=1>; rel="prev"`}},
+		{Name: "reject a limit too large", Op: op("GET", "/users/"), URL: "/users/?limit=1000", Status: http.StatusBadRequest},
+		{Name: "reject an unknown sort", Op: op("GET", "/users/"), URL: "/users/?sort=age", Status: http.StatusBadRequest},
+		{Name: "get a user", Op: op("GET", "/users/{id}"), URL: "/users/{user}", Status: http.StatusOK},
+		{Name: "get an unknown user", Op: op("GET", "/users/{id}"), URL: "/users/999999", Status: http.StatusNotFound},
+		{Name: "reject a bad user ID", Op: op("GET", "/users/{id}"), URL: "/users/abc", Status: http.StatusBadRequest},
+		{Name: "update a user", Op: op("PUT", "/users/{id}"), URL: "/users/{user}", Body: validUser, Status: http.StatusOK},
+		{Name: "reject an invalid update", Op: op("PUT", "/users/{id}"), URL: "/users/{user}", Body: `{"firstName":"Ada","lastName":"Lovelace","age":500,"weight":60,"height":165}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject a bad user ID to update", Op: op("PUT", "/users/{id}"), URL: "/users/abc", Body: validUser, Status: http.StatusBadRequest},
+		{Name: "update an unknown user", Op: op("PUT", "/users/{id}"), URL: "/users/999999", Body: validUser, Status: http.StatusNotFound},
+		{Name: "get user stats", Op: op("GET", "/users/{id}/stats"), URL: "/users/{user}/stats", Status: http.StatusOK},
+
+		{Name: "reject a bad user ID for stats", Op: op("GET", "/users/{id}/stats"), URL: "/users/abc/stats", Status: http.StatusBadRequest},
+		{Name: "get stats of an unknown user", Op: op("GET", "/users/{id}/stats"), URL: "/users/999999/stats", Status: http.StatusNotFound},
+		{Name: "get missing targets", Op: op("GET", "/users/{id}/targets"), URL: "/users/{user}/targets", Status: http.StatusNotFound},
+		{Name: "set targets", Op: op("POST", "/users/{id}/targets"), URL: "/users/{user}/targets", Body: `{"calories":2000,"protein":120,"carbs":250,"fat":70,"fiber":30}`, Status: http.StatusOK},
+		{Name: "reject invalid targets", Op: op("POST", "/users/{id}/targets"), URL: "/users/{user}/targets", Body: `{"calories":-5}`, Status: http.StatusUnprocessableEntity},
+		{Name: "get targets", Op: op("GET", "/users/{id}/targets"), URL: "/users/{user}/targets", Status: http.StatusOK},
+
+		{Name: "reject a bad user ID for targets", Op: op("GET", "/users/{id}/targets"), URL: "/users/abc/targets", Status: http.StatusBadRequest},
+		{Name: "reject a bad user ID to set targets", Op: op("POST", "/users/{id}/targets"), URL: "/users/abc/targets", Body: `{"calories":2000}`, Status: http.StatusBadRequest},
+		{Name: "set targets of an unknown user with an invalid body", Op: op("POST", "/users/{id}/targets"), URL: "/users/999999/targets", Body: `{"calories":-5}`, Status: http.StatusNotFound},
+		{Name: "record a weight", Op: op("POST", "/users/{id}/weight"), URL: "/users/{user}/weight", Body: `{"weight":61.2,"note":"morning"}`, Status: http.StatusCreated},
+		{Name: "reject an invalid weight", Op: op("POST", "/users/{id}/weight"), URL: "/users/{user}/weight", Body: `{"weight":0}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject a bad user ID to record a weight", Op: op("POST", "/users/{id}/weight"), URL: "/users/abc/weight", Body: `{"weight":61}`, Status: http.StatusBadRequest},
+		{Name: "record a weight of an unknown user with an invalid body", Op: op("POST", "/users/{id}/weight"), URL: "/users/999999/weight", Body: `{"weight":`, Status: http.StatusNotFound},
+		{Name: "record a past weight", Op: op("POST", "/users/{id}/weight"), URL: "/users/{user}/weight", Body: `{"weight":63,"date":"2024-01-15T08:00:00Z"}`, Status: http.StatusCreated},
+		{Name: "get weight history", Op: op("GET", "/users/{id}/weight/history"), URL: "/users/{user}/weight/history", Status: http.StatusOK, Headers: map[string]string{"X-Total-Count": "2"}},
+		{Name: "get weight history in a date range", Op: op("GET", "/users/{id}/weight/history"), URL: "/users/{user}/weight/history?from=2024-01-01&to=2024-01-15&sort=weight", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "1"}},
+		{Name: "reject a reversed date range", Op: op("GET", "/users/{id}/weight/history"), URL: "/users/{user}/weight/history?from=2024-02-01&to=2024-01-01", Status: http.StatusBadRequest},
+		{Name: "get weight history of an unknown user", Op: op("GET", "/users/{id}/weight/history"), URL: "/users/999999/weight/history", Status: http.StatusNotFound},
+
+		{Name: "search foods", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice", Status: http.StatusOK, Fields: map[string]string{"source": "provider"}},
+		{Name: "search foods again from the local index", Op: op("GET", "/foods/search"), URL: "/foods/search?q=Rice&userId={user}", Status: http.StatusOK, Fields: map[string]string{"source": "local"}},
+		{Name: "search enough local foods", Op: op("GET", "/foods/search"), URL: "/foods/search?q=cooked+ric&limit=1", Status: http.StatusOK, Fields: map[string]string{"source": "local"}},
+		{Name: "force a provider search", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&refresh=true", Status: http.StatusOK, Fields: map[string]string{"source": "provider"}},
+		{Name: "search the next page of foods", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&limit=2&page=2", Status: http.StatusOK, Fields: map[string]string{"source": "provider", "page": "2", "totalHits": "3", "foods.0.dataType": "SR Legacy"}},
+		{Name: "filter foods by data type", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&dataType=branded", Status: http.StatusOK, Fields: map[string]string{"source": "provider", "totalHits": "1", "foods.0.brandOwner": "Example Foods Inc."}},
+		{Name: "filter local foods by brand owner", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&brandOwner=example+foods+inc.&limit=1", Status: http.StatusOK, Fields: map[string]string{"source": "local", "foods.0.fdcId": "2047249"}},
+		{Name: "sort local foods by name", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&sort=-name&limit=3", Status: http.StatusOK, Fields: map[string]string{"source": "local", "totalPages": "1", "foods.2.name": "BROWN RICE"}},
+		{Name: "reject an unknown data type", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&dataType=homemade", Status: http.StatusBadRequest},
+		{Name: "reject an invalid search sort", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&sort=-relevance", Status: http.StatusBadRequest},
+		{Name: "reject an invalid search limit", Op: op("GET", "/foods/search"), URL: "/foods/search?q=rice&limit=0", Status: http.StatusBadRequest},
+		{Name: "report an unavailable food provider", Op: op("GET", "/foods/search"), URL: "/foods/search?q=outage", Status: http.StatusServiceUnavailable, Fields: map[string]string{"code": "upstream_unavailable"}},
+		{Name: "report a food provider timeout", Op: op("GET", "/foods/search"), URL: "/foods/search?q=slow", Status: http.StatusGatewayTimeout, Fields: map[string]string{"code": "upstream_timeout"}},
+		{Name: "report a food provider error", Op: op("GET", "/foods/search"), URL: "/foods/search?q=broken", Status: http.StatusBadGateway, Fields: map[string]string{"code": "upstream_error"}},
+		{Name: "reject a search without query", Op: op("GET", "/foods/search"), URL: "/foods/search", Status: http.StatusBadRequest},
+
+		{Name: "get a food with its portions", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/169756", Status: http.StatusOK,
+			Fields: map[string]string{"calories": "365", "portions.0.description": "1 cup", "portions.0.gramWeight": "185"}, Save: map[string]string{"portion": "portions.0.id"}},
+		{Name: "get a survey food's portions", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/2512381?userId={user}", Status: http.StatusOK,
+			Fields: map[string]string{"portions.1.description": "1 cup, packed", "portions.2": "<nil>"}},
+		{Name: "get a branded food's household serving", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/2047249", Status: http.StatusOK,
+			Fields: map[string]string{"servingSizeUnit": "g", "portions.0.description": "1/4 cup", "portions.0.gramWeight": "45"}},
+		{Name: "reject an unknown food detail", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/1", Status: http.StatusNotFound},
+
+		{Name: "reject a bad user ID for a food detail", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/169756?userId=abc", Status: http.StatusBadRequest},
+		{Name: "report a food provider error for a food detail", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/500", Status: http.StatusBadGateway},
+		{Name: "report an unavailable food provider for a food detail", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/503", Status: http.StatusServiceUnavailable},
+		{Name: "report a food provider timeout for a food detail", Op: op("GET", "/foods/{fdcId}"), URL: "/foods/504", Status: http.StatusGatewayTimeout},
+		{Name: "create a meal", Op: op("POST", "/meals/"), URL: "/meals/", Body: `{"type":"lunch","userId":{user}}`, Status: http.StatusCreated, Save: map[string]string{"meal": "ID"}},
+		{Name: "reject an invalid meal type", Op: op("POST", "/meals/"), URL: "/meals/", Body: `{"type":"brunch","userId":{user}}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject a malformed meal", Op: op("POST", "/meals/"), URL: "/meals/", Body: `{"type":`, Status: http.StatusBadRequest},
+		{Name: "reject a meal for an unknown user", Op: op("POST", "/meals/"), URL: "/meals/", Body: `{"type":"lunch","userId":999999}`, Status: http.StatusUnprocessableEntity,
+			Fields: map[string]string{"code": "validation_failed", "details.0.field": "userId"}},
+		{Name: "add a food to a meal", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":"2512381"}`, Status: http.StatusOK,
+			Fields: map[string]string{"foods.0.grams": "100", "foods.0.measure": "<nil>"}},
+		{Name: "add a food in household measures", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":"169756","portionId":{portion},"servings":2}`, Status: http.StatusOK,
+			Fields: map[string]string{"foods.1.grams": "370", "foods.1.measure": "2 x 1 cup"}},
+		{Name: "reject a quantity in grams and portions", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":"2047249","grams":50,"portionId":{portion}}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject another food's portion", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":"2047249","portionId":{portion}}`, Status: http.StatusUnprocessableEntity},
+		{Name: "add a food in grams", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":"2047249","grams":45}`, Status: http.StatusOK,
+			Fields: map[string]string{"foods.1.grams": "45", "foods.2.measure": "2 x 1 cup"}},
+		{Name: "reject a food already in the meal", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":"2512381"}`, Status: http.StatusConflict},
+		{Name: "reject an unknown food", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":"1"}`, Status: http.StatusNotFound},
+		{Name: "reject a malformed food to add", Op: op("POST", "/meals/{id}/foods"), URL: "/meals/{meal}/foods", Body: `{"foodId":`, Status: http.StatusBadRequest},
+		{Name: "list no favourite foods", Op: op("GET", "/users/{id}/foods/favorites"), URL: "/users/{user}/foods/favorites", Status: http.StatusOK},
+		{Name: "star a food", Op: op("PUT", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/{user}/foods/favorites/2512381", Status: http.StatusOK, Fields: map[string]string{"favorite": "true"}},
+		{Name: "star a food twice", Op: op("PUT", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/{user}/foods/favorites/2512381", Status: http.StatusOK},
+		{Name: "reject starring an unknown food", Op: op("PUT", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/{user}/foods/favorites/1", Status: http.StatusNotFound},
+		{Name: "reject a bad user ID to star a food", Op: op("PUT", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/abc/foods/favorites/2512381", Status: http.StatusBadRequest},
+		{Name: "reject a bad user ID for favourite foods", Op: op("GET", "/users/{id}/foods/favorites"), URL: "/users/abc/foods/favorites", Status: http.StatusBadRequest},
+		{Name: "list favourite foods of an unknown user", Op: op("GET", "/users/{id}/foods/favorites"), URL: "/users/999999/foods/favorites", Status: http.StatusNotFound},
+		{Name: "list favourite foods", Op: op("GET", "/users/{id}/foods/favorites"), URL: "/users/{user}/foods/favorites", Status: http.StatusOK, Fields: map[string]string{"0.fdcId": "2512381"}},
+		{Name: "override a food", Op: op("PUT", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/2512381", Body: `{"name":"My rice","calories":99}`, Status: http.StatusOK,
+			Fields: map[string]string{"name": "My rice", "food.calories": "130"}},
+		{Name: "replace a food override", Op: op("PUT", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/2512381", Body: `{"calories":99,"protein":3}`, Status: http.StatusOK,
+			Fields: map[string]string{"name": "<nil>", "protein": "3"}},
+		{Name: "reject an empty override", Op: op("PUT", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/2512381", Body: `{}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject an invalid override", Op: op("PUT", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/2512381", Body: `{"fat":-1}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject a bad user ID to override a food", Op: op("PUT", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/abc/foods/overrides/2512381", Body: `{"calories":99}`, Status: http.StatusBadRequest},
+		{Name: "reject overriding an unknown food", Op: op("PUT", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/1", Body: `{"calories":99}`, Status: http.StatusNotFound},
+		{Name: "list food overrides", Op: op("GET", "/users/{id}/foods/overrides"), URL: "/users/{user}/foods/overrides", Status: http.StatusOK, Fields: map[string]string{"0.food.fdcId": "2512381"}},
+		{Name: "reject a bad user ID for food overrides", Op: op("GET", "/users/{id}/foods/overrides"), URL: "/users/abc/foods/overrides", Status: http.StatusBadRequest},
+		{Name: "list food overrides of an unknown user", Op: op("GET", "/users/{id}/foods/overrides"), URL: "/users/999999/foods/overrides", Status: http.StatusNotFound},
+		{Name: "apply overrides to meals", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}", Status: http.StatusOK,
+			Fields: map[string]string{"0.foods.0.calories": "99", "0.foods.0.overridden": "true", "0.foods.0.favorite": "true"}},
+		{Name: "get recent foods", Op: op("GET", "/users/{id}/foods/recent"), URL: "/users/{user}/foods/recent?type=lunch", Status: http.StatusOK},
+		{Name: "get recent foods of another meal type", Op: op("GET", "/users/{id}/foods/recent"), URL: "/users/{user}/foods/recent?type=dinner&days=7", Status: http.StatusOK},
+		{Name: "reject an invalid hour", Op: op("GET", "/users/{id}/foods/recent"), URL: "/users/{user}/foods/recent?hour=24", Status: http.StatusBadRequest},
+		{Name: "get recent foods of an unknown user", Op: op("GET", "/users/{id}/foods/recent"), URL: "/users/999999/foods/recent", Status: http.StatusNotFound},
+		{Name: "get frequent foods", Op: op("GET", "/users/{id}/foods/frequent"), URL: "/users/{user}/foods/frequent?limit=5", Status: http.StatusOK},
+		{Name: "get frequent foods of an unknown user", Op: op("GET", "/users/{id}/foods/frequent"), URL: "/users/999999/foods/frequent", Status: http.StatusNotFound},
+		{Name: "reject an invalid frequent foods limit", Op: op("GET", "/users/{id}/foods/frequent"), URL: "/users/{user}/foods/frequent?limit=0", Status: http.StatusBadRequest},
+		{Name: "delete a food override", Op: op("DELETE", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/2512381", Status: http.StatusNoContent},
+		{Name: "delete a missing food override", Op: op("DELETE", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/2512381", Status: http.StatusNotFound},
+		{Name: "reject a bad user ID to delete a food override", Op: op("DELETE", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/abc/foods/overrides/2512381", Status: http.StatusBadRequest},
+		{Name: "unstar a food", Op: op("DELETE", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/{user}/foods/favorites/2512381", Status: http.StatusNoContent},
+		{Name: "unstar an unknown food", Op: op("DELETE", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/{user}/foods/favorites/1", Status: http.StatusNotFound},
+		{Name: "reject a bad user ID to unstar a food", Op: op("DELETE", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/abc/foods/favorites/2512381", Status: http.StatusBadRequest},
+		{Name: "list user meals", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}", Status: http.StatusOK, Headers: map[string]string{"X-Total-Count": "1"},
+			Fields: map[string]string{"0.foods.0.calories": "130", "0.foods.0.overridden": "<nil>"}},
+		{Name: "list user meals in a date range", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}?from=2000-01-01&to=2000-12-31&sort=type", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "0"}},
+		{Name: "reject a bad date filter", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}?date=yesterday", Status: http.StatusBadRequest},
+
+		{Name: "dry run a Cronometer import", Op: op("POST", "/users/{id}/import"), URL: "/users/{user}/import?dryRun=true", Body: cronometerLog, ContentType: "text/csv", Status: http.StatusOK,
+			Fields: map[string]string{"format": "cronometer", "dryRun": "true", "entries": "3", "meals": "2", "customFoods": "1", "unmatched.0.line": "3", "skipped.0.line": "4"}},
+		{Name: "save nothing on a dry run", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}?from=2024-03-01&to=2024-03-01", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "0"}},
+		{Name: "import a Cronometer log", Op: op("POST", "/users/{id}/import"), URL: "/users/{user}/import", Body: cronometerLog, ContentType: "text/csv", Status: http.StatusOK,
+			Fields: map[string]string{"dryRun": "false", "entries": "3", "meals": "2"}},
+		{Name: "list imported meals", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}?from=2024-03-01&to=2024-03-01&sort=type", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "2"}, Fields: map[string]string{"0.type": "break", "0.foods.0.grams": "50", "1.foods.0.grams": "50", "1.foods.1.dataType": "Custom"}},
+		{Name: "skip foods already imported", Op: op("POST", "/users/{id}/import"), URL: "/users/{user}/import?format=cronometer", Body: cronometerLog, ContentType: "text/csv", Status: http.StatusOK,
+			Fields: map[string]string{"entries": "0", "meals": "0", "customFoods": "0", "skipped.3.reason": "food already in the meal", "skipped.4": "<nil>"}},
+		{Name: "import a MyFitnessPal log", Op: op("POST", "/users/{id}/import"), URL: "/users/{user}/import", Body: myFitnessPalLog, ContentType: "text/csv", Status: http.StatusOK,
+			Fields: map[string]string{"format": "mfp", "entries": "1", "unmatched.0.food": "Dinner (MyFitnessPal)"}},
+		{Name: "reject an unknown import format", Op: op("POST", "/users/{id}/import"), URL: "/users/{user}/import?format=fitbit", Body: myFitnessPalLog, ContentType: "text/csv", Status: http.StatusBadRequest},
+		{Name: "reject a file which is not a food log", Op: op("POST", "/users/{id}/import"), URL: "/users/{user}/import", Body: "name,age\nAnn,30\n", ContentType: "text/csv", Status: http.StatusBadRequest},
+		{Name: "reject an import for an unknown user", Op: op("POST", "/users/{id}/import"), URL: "/users/999999/import", Body: myFitnessPalLog, ContentType: "text/csv", Status: http.StatusNotFound},
+		{Name: "reject a food log too large", Op: op("POST", "/users/{id}/import"), URL: "/users/{user}/import", Body: strings.Repeat("a", 10<<20+1), ContentType: "text/csv", Status: http.StatusRequestEntityTooLarge},
+		{Name: "dry run a quick log", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog?dryRun=true",
+			Body: `{"text":"1 cup brown rice and 150g white rice for dinner on 2024-04-02"}`, Status: http.StatusOK,
+			Fields: map[string]string{"date": "2024-04-02T00:00:00Z", "mealType": "dinner", "mealGuessed": "false", "dryRun": "true", "mealId": "0", "logged": "2",
+				"entries.0.unit": "cup", "entries.0.fdcId": "2047249", "entries.0.grams": "180", "entries.0.measure": "4 x 1/4 cup", "entries.1.grams": "150"}},
+		{Name: "save nothing on a quick log dry run", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}?date=2024-04-02", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "0"}},
+		{Name: "quick log a meal", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog",
+			Body: `{"text":"1 cup brown rice and 150g white rice for dinner on 2024-04-02"}`, Status: http.StatusOK,
+			Fields: map[string]string{"dryRun": "false", "logged": "2"}, Save: map[string]string{"quickMeal": "mealId"}},
+		{Name: "add to a food quick logged twice", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog",
+			Body: `{"text":"45 g brown rice","now":"2024-04-02T20:30:00+02:00"}`, Status: http.StatusOK,
+			Fields: map[string]string{"date": "2024-04-02T00:00:00Z", "mealType": "dinner", "mealGuessed": "true", "mealId": "{quickMeal}", "entries.0.measure": ""}},
+		{Name: "list the quick logged meal", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{user}?date=2024-04-02", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "1"}},
+		{Name: "report a quick logged food matching none", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog?dryRun=true",
+			Body: `{"text":"2 slices of dragonfruit bread yesterday","now":"2024-04-03T08:00:00Z"}`, Status: http.StatusOK,
+			Fields: map[string]string{"date": "2024-04-02T00:00:00Z", "mealType": "breakfast", "logged": "0", "entries.0.quantity": "2", "entries.0.unit": "slice", "entries.0.fdcId": "<nil>"}},
+		{Name: "skip a quick logged food of 0 g", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog?dryRun=true",
+			Body: `{"text":"0g white rice and 1 cup brown rice for dinner"}`, Status: http.StatusOK,
+			Fields: map[string]string{"logged": "1", "entries.0.quantity": "0", "entries.0.grams": "0", "entries.0.note": "quantity of 0 g, not logged", "entries.1.grams": "180"}},
+		{Name: "reject a quick log naming no food", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog", Body: `{"text":"for lunch yesterday"}`, Status: http.StatusUnprocessableEntity,
+			Fields: map[string]string{"details.0.field": "text"}},
+		{Name: "reject a quick log without text", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog", Body: `{}`, Status: http.StatusUnprocessableEntity},
+		{Name: "reject a quick log for an unknown user", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/999999/quicklog", Body: `{"text":"1 banana"}`, Status: http.StatusNotFound},
+
+		{Name: "reject an invalid quick log dry run", Op: op("POST", "/users/{id}/quicklog"), URL: "/users/{user}/quicklog?dryRun=maybe", Body: `{"text":"1 banana"}`, Status: http.StatusBadRequest},
+		{Name: "export a user", Op: op("GET", "/users/{id}/export"), URL: "/users/{user}/export", Status: http.StatusOK,
+			Fields: map[string]string{"version": "1", "profile.firstName": "Ada", "targets.calories": "2000", "weightRecords.0.date": "2024-01-15T08:00:00Z", "meals.0.date": "2024-03-01T00:00:00Z", "meals.0.foods.0.grams": "50"}},
+		{Name: "export a user as an archive", Op: op("GET", "/users/{id}/export"), URL: "/users/{user}/export?format=zip", Status: http.StatusOK,
+			Headers: map[string]string{"Content-Type": "application/zip"}},
+		{Name: "reject an unknown export format", Op: op("GET", "/users/{id}/export"), URL: "/users/{user}/export?format=xml", Status: http.StatusBadRequest},
+		{Name: "export an unknown user", Op: op("GET", "/users/{id}/export"), URL: "/users/999999/export", Status: http.StatusNotFound},
+		{Name: "export the meal log as CSV", Op: op("GET", "/users/{id}/export/meals"), URL: "/users/{user}/export/meals?from=2024-03-01&to=2024-03-02", Status: http.StatusOK,
+			Headers: map[string]string{"Content-Type": "text/csv; charset=utf-8", "Content-Disposition": `attachment; filename="meals-{user}-2024-03-01-2024-03-02.csv"`}},
+		{Name: "export daily totals as CSV", Op: op("GET", "/users/{id}/export/daily"), URL: "/users/{user}/export/daily?from=2024-02-28&to=2024-03-02", Status: http.StatusOK,
+			Headers: map[string]string{"Content-Type": "text/csv; charset=utf-8"}},
+		{Name: "reject a daily export range too long", Op: op("GET", "/users/{id}/export/daily"), URL: "/users/{user}/export/daily?from=2000-01-01&to=2024-01-01", Status: http.StatusBadRequest,
+			Fields: map[string]string{"details.0.field": "from"}},
+		{Name: "reject a daily export starting too long before the meals", Op: op("GET", "/users/{id}/export/daily"), URL: "/users/{user}/export/daily?from=1900-01-01", Status: http.StatusBadRequest,
+			Fields: map[string]string{"details.0.field": "from"}},
+		{Name: "reject a daily export ending too long after the meals", Op: op("GET", "/users/{id}/export/daily"), URL: "/users/{user}/export/daily?to=2100-01-01", Status: http.StatusBadRequest,
+			Fields: map[string]string{"details.0.field": "to"}},
+		{Name: "export the daily totals of an unknown user", Op: op("GET", "/users/{id}/export/daily"), URL: "/users/999999/export/daily", Status: http.StatusNotFound},
+		{Name: "export the weight history as CSV", Op: op("GET", "/users/{id}/export/weight"), URL: "/users/{user}/export/weight", Status: http.StatusOK,
+			Headers: map[string]string{"Content-Type": "text/csv; charset=utf-8"}},
+		{Name: "reject a reversed CSV export range", Op: op("GET", "/users/{id}/export/weight"), URL: "/users/{user}/export/weight?from=2024-02-01&to=2024-01-01", Status: http.StatusBadRequest},
+		{Name: "export the weight history of an unknown user", Op: op("GET", "/users/{id}/export/weight"), URL: "/users/999999/export/weight", Status: http.StatusNotFound},
+		{Name: "reject a bad meal export date", Op: op("GET", "/users/{id}/export/meals"), URL: "/users/{user}/export/meals?from=yesterday", Status: http.StatusBadRequest},
+		{Name: "get a weekly report", Op: op("GET", "/users/{id}/reports/weekly"), URL: "/users/{user}/reports/weekly?week=2024-W09&tolerance=15", Status: http.StatusOK,
+			Fields: map[string]string{"label": "2024-W09", "from": "2024-02-26T00:00:00Z", "days.6.date": "2024-03-03T00:00:00Z", "loggedDays": "2", "meals": "3", "days.4.meals": "2",
+				"targets.calories": "2000", "tolerance": "15", "adherence.all": "0", "weight": "<nil>"}},
+		{Name: "get a monthly report", Op: op("GET", "/users/{id}/reports/monthly"), URL: "/users/{user}/reports/monthly?month=2024-01", Status: http.StatusOK,
+			Fields: map[string]string{"to": "2024-01-31T00:00:00Z", "days.30.date": "2024-01-31T00:00:00Z", "loggedDays": "0", "bestDay": "<nil>", "weight.records": "1"}},
+		{Name: "reject a malformed week", Op: op("GET", "/users/{id}/reports/weekly"), URL: "/users/{user}/reports/weekly?week=2024-W54", Status: http.StatusBadRequest,
+			Fields: map[string]string{"details.0.field": "week"}},
+		{Name: "reject a tolerance above 100", Op: op("GET", "/users/{id}/reports/monthly"), URL: "/users/{user}/reports/monthly?tolerance=150", Status: http.StatusBadRequest},
+		{Name: "get the report of an unknown user", Op: op("GET", "/users/{id}/reports/weekly"), URL: "/users/999999/reports/weekly", Status: http.StatusNotFound},
+		{Name: "get the monthly report of an unknown user", Op: op("GET", "/users/{id}/reports/monthly"), URL: "/users/999999/reports/monthly", Status: http.StatusNotFound},
+		{Name: "get a printable report", Op: op("GET", "/users/{id}/reports/print"), URL: "/users/{user}/reports/print?from=2024-01-01&to=2024-03-31", Status: http.StatusOK,
+			Headers: map[string]string{"Content-Type": "text/html; charset=utf-8", "Content-Disposition": `inline; filename="report-{user}-2024-01-01-2024-03-31.html"`}},
+		{Name: "get a printable report as PDF", Op: op("GET", "/users/{id}/reports/print"), URL: "/users/{user}/reports/print?format=pdf&from=2024-03-01", Status: http.StatusOK,
+			Headers: map[string]string{"Content-Type": "application/pdf"}},
+		{Name: "reject a printable report range too long", Op: op("GET", "/users/{id}/reports/print"), URL: "/users/{user}/reports/print?from=2022-01-01&to=2024-01-01", Status: http.StatusBadRequest},
+		{Name: "reject an unknown printable format", Op: op("GET", "/users/{id}/reports/print"), URL: "/users/{user}/reports/print?format=docx", Status: http.StatusBadRequest},
+		{Name: "get the printable report of an unknown user", Op: op("GET", "/users/{id}/reports/print"), URL: "/users/999999/reports/print", Status: http.StatusNotFound},
+		{Name: "export the meals of an unknown user", Op: op("GET", "/users/{id}/export/meals"), URL: "/users/999999/export/meals", Status: http.StatusNotFound},
+		{Name: "import a user export", Op: op("POST", "/users/import"), URL: "/users/import", Body: userExport, Status: http.StatusCreated,
+			Fields: map[string]string{"firstName": "Grace", "CreatedAt": "2023-05-01T09:00:00Z"}, Save: map[string]string{"imported": "ID"}},
+		{Name: "export an imported user", Op: op("GET", "/users/{id}/export"), URL: "/users/{imported}/export", Status: http.StatusOK,
+			Fields: map[string]string{"profile.updatedAt": "2024-02-01T10:00:00Z", "targets.createdAt": "2023-05-02T09:00:00Z", "weightRecords.1.note": "after holidays",
+				"meals.0.createdAt": "2024-03-05T12:30:00Z", "meals.0.foods.1.measure": "1 bowl", "foods.1.custom": "true", "foods.1.portions.0.gramWeight": "250",
+				"favorites.0.fdcId": "2047249", "overrides.0.name": "My rice", "overrides.0.protein": "<nil>"},
+			Save: map[string]string{"importedFood": "foods.1.fdcId"}},
+		{Name: "reject starring another user's custom food", Op: op("PUT", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/{user}/foods/favorites/{importedFood}", Status: http.StatusNotFound},
+		{Name: "reject overriding another user's custom food", Op: op("PUT", "/users/{id}/foods/overrides/{fdcId}"), URL: "/users/{user}/foods/overrides/{importedFood}", Body: `{"calories":99}`, Status: http.StatusNotFound},
+		{Name: "star one's own custom food", Op: op("PUT", "/users/{id}/foods/favorites/{fdcId}"), URL: "/users/{imported}/foods/favorites/{importedFood}", Status: http.StatusOK,
+			Fields: map[string]string{"name": "Grandma's soup", "favorite": "true"}},
+		{Name: "reject an export with an invalid meal", Op: op("POST", "/users/import"), URL: "/users/import", Body: strings.Replace(userExport, `"lunch"`, `"brunch"`, 1), Status: http.StatusUnprocessableEntity,
+			Fields: map[string]string{"details.0.field": "meals[0].type"}},
+		{Name: "reject an export eating an unlisted food", Op: op("POST", "/users/import"), URL: "/users/import", Body: strings.Replace(userExport, `{"fdcId":"2047249","grams":80}`, `{"fdcId":"1","grams":80}`, 1), Status: http.StatusUnprocessableEntity,
+			Fields: map[string]string{"details.0.field": "meals[0].foods[0].fdcId"}},
+		{Name: "reject an export of a newer version", Op: op("POST", "/users/import"), URL: "/users/import", Body: strings.Replace(userExport, `"version":1`, `"version":2`, 1), Status: http.StatusUnprocessableEntity,
+			Fields: map[string]string{"details.0.field": "version"}},
+		{Name: "reject a malformed export", Op: op("POST", "/users/import"), URL: "/users/import", Body: `{"version":`, Status: http.StatusBadRequest},
+
+		{Name: "reject an export too large", Op: op("POST", "/users/import"), URL: "/users/import", Body: strings.Repeat(" ", 50<<20+1), Status: http.StatusRequestEntityTooLarge},
+		{Name: "delete a user", Op: op("DELETE", "/users/{id}"), URL: "/users/{imported}", Status: http.StatusOK, Fields: map[string]string{"userId": "{imported}"}},
+		{Name: "hide a deleted user", Op: op("GET", "/users/{id}"), URL: "/users/{imported}", Status: http.StatusNotFound},
+		{Name: "hide the meals of a deleted user", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{imported}", Status: http.StatusNotFound},
+		{Name: "delete a deleted user", Op: op("DELETE", "/users/{id}"), URL: "/users/{imported}", Status: http.StatusNotFound},
+		{Name: "restore a deleted user", Op: op("POST", "/users/{id}/restore"), URL: "/users/{imported}/restore", Status: http.StatusOK, Fields: map[string]string{"firstName": "Grace", "DeletedAt": "<nil>"}},
+		{Name: "restore the meals of a deleted user", Op: op("GET", "/meals/user/{userId}"), URL: "/meals/user/{imported}", Status: http.StatusOK,
+			Headers: map[string]string{"X-Total-Count": "1"}, Fields: map[string]string{"0.date": "2024-03-05T00:00:00Z", "0.foods.0.fdcId": "2047249", "0.foods.1.dataType": "Custom", "0.foods.1.grams": "250"}},
+		{Name: "restore the data of a deleted user", Op: op("GET", "/users/{id}/export"), URL: "/users/{imported}/export", Status: http.StatusOK,
+			Fields: map[string]string{"targets.calories": "1800", "weightRecords.1.note": "after holidays", "meals.0.foods.1.grams": "250", "foods.1.custom": "true", "overrides.0.name": "My rice"}},
+		{Name: "reject restoring a user which is not deleted", Op: op("POST", "/users/{id}/restore"), URL: "/users/{imported}/restore", Status: http.StatusConflict},
+		{Name: "restore an unknown user", Op: op("POST", "/users/{id}/restore"), URL: "/users/999999/restore", Status: http.StatusNotFound},
+		{Name: "reject a bad ID to restore", Op: op("POST", "/users/{id}/restore"), URL: "/users/abc/restore", Status: http.StatusBadRequest},
+		{Name: "reject a bad ID to delete", Op: op("DELETE", "/users/{id}"), URL: "/users/abc", Status: http.StatusBadRequest},
+	}
+}
+
+// cronometerLog is a Cronometer servings export: a food matching a stored
+// one by name with its weight, one matching nothing, a line with an invalid
+// date and a food matching by name whose weight comes from its calories
+const cronometerLog = `Day,Time,Group,Food Name,Amount,Category,Energy (kcal),Carbs (g),Fiber (g),Fat (g),Protein (g)
+2024-03-01,08:00,Breakfast,"Rice, white, long-grain, regular, raw, enriched",50.00 g,Grains,182.5,40,0.7,0.3,3.6
+2024-03-01,08:00,Breakfast,Grandma's pancakes,2.00 pancake,Baked,300,40,1,12,8
+yesterday,08:00,Breakfast,Toast,1.00 slice,Baked,80,15,1,1,3
+2024-03-01,16:00,Snacks,BROWN RICE,,Grains,178,38,2,1.1,4.4
+`
+
+// myFitnessPalLog is a MyFitnessPal nutrition export, a line per meal
+const myFitnessPalLog = `Date,Meal,Calories,Fat (g),Carbohydrates (g),Fiber,Protein (g),Note
+2024-03-02,Dinner,650,20,80,6,35,
+`
+
+// userExport is an export from another server: a meal eating a shared food
+// stored here and a custom food, a favourite and an override of the shared
+// food
+const userExport = `{"version":1,"exportedAt":"2024-03-06T00:00:00Z",
+"profile":{"firstName":"Grace","lastName":"Hopper","age":45,"weight":58,"height":160,"goal":"","sex":0,"activityLevel":2,"createdAt":"2023-05-01T09:00:00Z","updatedAt":"2024-02-01T10:00:00Z"},
+"targets":{"calories":1800,"protein":100,"carbs":200,"fat":60,"fiber":25,"createdAt":"2023-05-02T09:00:00Z","updatedAt":"2023-05-02T09:00:00Z"},
+"weightRecords":[{"weight":59,"date":"2023-12-20T08:00:00Z","createdAt":"2023-12-20T08:00:00Z"},{"weight":58,"date":"2024-01-10T08:00:00Z","note":"after holidays","createdAt":"2024-01-10T08:05:00Z"}],
+"meals":[{"type":"lunch","date":"2024-03-05T00:00:00Z","createdAt":"2024-03-05T12:30:00Z","foods":[{"fdcId":"2047249","grams":80},{"fdcId":"custom-7-a1b2c3d4e5f6","grams":250,"measure":"1 bowl"}]}],
+"foods":[{"fdcId":"2047249","name":"RICE","dataType":"Branded","protein":7,"carbs":80,"fat":1,"calories":360,"fiber":1,"servingSize":45},
+{"fdcId":"custom-7-a1b2c3d4e5f6","name":"Grandma's soup","dataType":"Custom","custom":true,"protein":3,"carbs":8,"fat":2,"calories":62,"fiber":1.5,"servingSize":0,"portions":[{"description":"1 bowl","gramWeight":250}]}],
+"favorites":[{"fdcId":"2047249","createdAt":"2024-01-05T10:00:00Z"}],
+"overrides":[{"fdcId":"2047249","name":"My rice","protein":null,"carbs":null,"fat":null,"calories":null,"fiber":null,"servingSize":null,"createdAt":"2024-01-06T10:00:00Z","updatedAt":"2024-01-06T10:00:00Z"}]}`
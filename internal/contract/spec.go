@@ -0,0 +1,276 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is a parsed OpenAPI 3 document able to validate JSON values against
+// its schemas. It supports the subset of JSON Schema used by the API's
+// document: $ref, allOf, type, nullable, enum, properties, required,
+// additionalProperties, items, minimum, maximum and the date-time format.
+// Objects are closed unless additionalProperties says otherwise, so that
+// undocumented fields are reported.
+type Spec struct {
+	doc map[string]any
+}
+
+// Operation identifies an operation of the document
+type Operation struct {
+	Method string
+	Path   string
+}
+
+func (o Operation) String() string {
+	return o.Method + " " + o.Path
+}
+
+func LoadSpec(data []byte) (*Spec, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+	if _, ok := doc["paths"].(map[string]any); !ok {
+		return nil, fmt.Errorf("OpenAPI document has no paths")
+	}
+	return &Spec{doc: doc}, nil
+}
+
+var methods = []string{"get", "put", "post", "delete", "patch"}
+
+// Operations lists the operations of the document, sorted
+func (s *Spec) Operations() []Operation {
+	var ops []Operation
+	for path, item := range s.doc["paths"].(map[string]any) {
+		for _, method := range methods {
+			if _, ok := item.(map[string]any)[method]; ok {
+				ops = append(ops, Operation{Method: strings.ToUpper(method), Path: path})
+			}
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].String() < ops[j].String()
+	})
+	return ops
+}
+
+// ResponseSchema returns the JSON schema of an operation's response with the
+// given status, and whether that status is documented at all
+func (s *Spec) ResponseSchema(op Operation, status int) (map[string]any, bool) {
+	response, ok := s.response(op, status)
+	if !ok {
+		return nil, false
+	}
+
+	content, _ := response["content"].(map[string]any)
+	media, _ := content["application/json"].(map[string]any)
+	schema, _ := media["schema"].(map[string]any)
+	return schema, true
+}
+
+// HasMediaType tells whether an operation's response with the given status
+// is documented with a body of the media type
+func (s *Spec) HasMediaType(op Operation, status int, mediaType string) bool {
+	response, _ := s.response(op, status)
+	content, _ := response["content"].(map[string]any)
+	_, ok := content[mediaType]
+	return ok
+}
+
+// RequiredHeaders returns the names of the headers an operation's response
+// with the given status must carry
+func (s *Spec) RequiredHeaders(op Operation, status int) []string {
+	response, _ := s.response(op, status)
+	headers, _ := response["headers"].(map[string]any)
+
+	var names []string
+	for name, header := range headers {
+		if required, _ := s.resolve(mapOf(header))["required"].(bool); required {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Spec) response(op Operation, status int) (map[string]any, bool) {
+	item, _ := s.doc["paths"].(map[string]any)[op.Path].(map[string]any)
+	operation, _ := item[strings.ToLower(op.Method)].(map[string]any)
+	responses, _ := operation["responses"].(map[string]any)
+	response, ok := responses[strconv.Itoa(status)].(map[string]any)
+	return response, ok
+}
+
+// Validate checks a decoded JSON value against a schema and returns the
+// violations found, each prefixed with the location of the offending value
+func (s *Spec) Validate(value any, schema map[string]any) []string {
+	var errs []string
+	s.validate("$", value, schema, &errs)
+	return errs
+}
+
+func (s *Spec) resolve(schema map[string]any) map[string]any {
+	for {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			break
+		}
+		schema = s.lookup(ref)
+	}
+
+	allOf, ok := schema["allOf"].([]any)
+	if !ok {
+		return schema
+	}
+
+	// Merge the parts into a single object schema
+	merged := map[string]any{"type": "object"}
+	properties := map[string]any{}
+	var required []any
+	for _, part := range allOf {
+		resolved := s.resolve(part.(map[string]any))
+		for name, prop := range mapOf(resolved["properties"]) {
+			properties[name] = prop
+		}
+		if req, ok := resolved["required"].([]any); ok {
+			required = append(required, req...)
+		}
+		if ap, ok := resolved["additionalProperties"]; ok {
+			merged["additionalProperties"] = ap
+		}
+	}
+	for key, v := range schema {
+		if key != "allOf" {
+			merged[key] = v
+		}
+	}
+	merged["properties"] = properties
+	merged["required"] = required
+	return merged
+}
+
+func (s *Spec) lookup(ref string) map[string]any {
+	node := any(s.doc)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node = mapOf(node)[part]
+	}
+	schema, ok := node.(map[string]any)
+	if !ok {
+		panic("contract: unresolved reference " + ref)
+	}
+	return schema
+}
+
+func mapOf(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func (s *Spec) validate(at string, value any, schema map[string]any, errs *[]string) {
+	if schema == nil {
+		return
+	}
+	schema = s.resolve(schema)
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); !nullable {
+			fail("null is not allowed")
+		}
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("%v is not one of %v", value, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("expected an object, got %T", value)
+			return
+		}
+		properties := mapOf(schema["properties"])
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for name, v := range obj {
+			if prop, ok := properties[name]; ok {
+				s.validate(at+"."+name, v, prop.(map[string]any), errs)
+				continue
+			}
+			switch ap := schema["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					fail("undocumented property %q", name)
+				}
+			case map[string]any:
+				s.validate(at+"."+name, v, ap, errs)
+			default:
+				if properties != nil {
+					fail("undocumented property %q", name)
+				}
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("expected an array, got %T", value)
+			return
+		}
+		items := mapOf(schema["items"])
+		for i, v := range arr {
+			s.validate(fmt.Sprintf("%s[%d]", at, i), v, items, errs)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected a string, got %T", value)
+			return
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				fail("%q is not a date-time", str)
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			fail("expected a number, got %T", value)
+			return
+		}
+		if schema["type"] == "integer" && n != math.Trunc(n) {
+			fail("%v is not an integer", n)
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			fail("%v is less than %v", n, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && n > max {
+			fail("%v is greater than %v", n, max)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected a boolean, got %T", value)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package pdf writes simple PDF documents: pages of text in the standard
+// Helvetica fonts, lines and rectangles. It needs no font file nor external
+// tool, the standard fonts being built into every PDF reader.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page size, in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Font is one of the standard fonts of the documents
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+// fontNames are the base font names of the fonts, in the order of their
+// resources F1, F2…
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+// Color is an RGB color, each component between 0 and 1
+type Color struct{ R, G, B float64 }
+
+// Black is the default color of the pages
+var Black = Color{}
+
+// Document is a PDF document being built page by page
+type Document struct {
+	title string
+	pages []*Page
+}
+
+// New returns an empty document titled title
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// AddPage appends a blank A4 page to the document and returns it
+func (d *Document) AddPage() *Page {
+	page := &Page{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Page is a page of a document. Its coordinates are in points from the top
+// left corner, y growing downwards.
+type Page struct {
+	content bytes.Buffer
+}
+
+// Text writes s with its baseline starting at x, y
+func (p *Page) Text(x, y float64, font Font, size float64, color Color, s string) {
+	fmt.Fprintf(&p.content, "BT %s rg /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		color.components(), font+1, number(size), number(x), number(PageHeight-y), escape(s))
+}
+
+// Line draws a line from x1, y1 to x2, y2
+func (p *Page) Line(x1, y1, x2, y2, width float64, color Color) {
+	p.Polyline([][2]float64{{x1, y1}, {x2, y2}}, width, color)
+}
+
+// Polyline draws lines joining the points, each an x, y pair
+func (p *Page) Polyline(points [][2]float64, width float64, color Color) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(&p.content, "%s RG %s w", color.components(), number(width))
+	for i, point := range points {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&p.content, " %s %s %s", number(point[0]), number(PageHeight-point[1]), op)
+	}
+	p.content.WriteString(" S\n")
+}
+
+// Rect fills the rectangle whose top left corner is x, y
+func (p *Page) Rect(x, y, width, height float64, color Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n",
+		color.components(), number(x), number(PageHeight-y-height), number(width), number(height))
+}
+
+func (c Color) components() string {
+	return number(c.R) + " " + number(c.G) + " " + number(c.B)
+}
+
+func number(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// escape encodes s as the content of a PDF string in WinAnsiEncoding, the
+// encoding of the fonts. Characters it lacks are replaced with '?'.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := winAnsi(r)
+		if !ok {
+			c = '?'
+		}
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 32 || c > 126:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// winAnsiSpecials are the characters of WinAnsiEncoding outside of Latin-1
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, '‰': 0x89, 'Š': 0x8A,
+	'‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+func winAnsi(r rune) (byte, bool) {
+	if r < 0x80 || (r >= 0xA0 && r <= 0xFF) {
+		return byte(r), true
+	}
+	c, ok := winAnsiSpecials[r]
+	return c, ok
+}
+
+// WriteTo writes the document to w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) int {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+	// Objects are numbered from 1 in the order they are written: the
+	// catalog, the page tree, the info, the fonts, then each page and its
+	// content
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	pagesID, fontsID := 2, 4
+	pageIDs := make([]string, len(d.pages))
+	for i := range d.pages {
+		pageIDs[i] = fmt.Sprintf("%d 0 R", fontsID+len(fontNames)+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageIDs, " "), len(d.pages)))
+	object(fmt.Sprintf("<< /Title (%s) /Producer (my-body-tracker) >>", escape(d.title)))
+	var fonts []string
+	for i, name := range fontNames {
+		id := object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, id))
+	}
+	for _, page := range d.pages {
+		var content bytes.Buffer
+		zw := zlib.NewWriter(&content)
+		if _, err := zw.Write(page.content.Bytes()); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pagesID, number(PageWidth), number(PageHeight), strings.Join(fonts, " "), len(offsets)+2))
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.WriteTo(w)
+}